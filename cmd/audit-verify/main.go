@@ -0,0 +1,55 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+)
+
+// audit-verify walks a service's audit log and exits with status 1 if the hash chain has gaps or tampered entries.
+// It prints the head of the chain; passing that to -head on a later run also catches a log rewritten with its head.
+func main() {
+	configPath := flag.String("config", "config/auth-config.yaml", "config file of the service whose audit log to verify")
+	headFlag := flag.String("head", "", "head printed by an earlier run, as sequence:hash")
+	flag.Parse()
+
+	var published audit.Head
+	if *headFlag != "" {
+		sequence, hash, _ := strings.Cut(*headFlag, ":")
+		value, err := strconv.ParseUint(sequence, 10, 64)
+		if err != nil || hash == "" {
+			fmt.Fprintln(os.Stderr, "-head must be sequence:hash")
+			os.Exit(2)
+		}
+		published = audit.Head{Sequence: value, Hash: hash}
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		panic("Could not load config file")
+	}
+
+	database := db.InitializeDatabase(cfg.DatabaseDriver, cfg.Database)
+
+	report, err := audit.NewLog(database).VerifyAgainst(published)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not verify audit log: %v\n", err)
+		os.Exit(2)
+	}
+
+	for _, problem := range report.Problems {
+		fmt.Printf("sequence %d: %s: %s\n", problem.Sequence, problem.Kind, problem.Message)
+	}
+
+	if !report.OK() {
+		fmt.Printf("audit log is NOT intact: %d problems in %d entries\n", len(report.Problems), report.Entries)
+		os.Exit(1)
+	}
+	fmt.Printf("audit log is intact: %d entries verified, head %d:%s\n", report.Entries, report.Head.Sequence, report.Head.Hash)
+}
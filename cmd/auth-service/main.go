@@ -3,17 +3,20 @@ package main
 import (
 	"fmt"
 
-	"github.com/golang-jwt/jwt/v5"
-	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	customMiddleware "github.com/mwelwankuta/facebook-notes/pkg/middleware"
-	"github.com/mwelwankuta/facebook-notes/pkg/models"
-	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/tokens"
+	"github.com/mwelwankuta/facebook-notes/pkg/vault"
 
 	"github.com/mwelwankuta/facebook-notes/internal/auth"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
 	"github.com/mwelwankuta/facebook-notes/pkg/config"
 	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/jwks"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
 )
 
 func main() {
@@ -22,41 +25,59 @@ func main() {
 		panic("Could not load config file")
 	}
 
-	database := db.InitializeDatabase(cfg.Database)
+	database := db.InitializeDatabase(cfg.DatabaseDriver, cfg.Database)
+
+	migrations, err := auth.Migrations(db.Dialect(database))
+	if err != nil {
+		panic(fmt.Sprintf("Could not load migrations: %v", err))
+	}
+	auditMigrations, err := audit.Migrations(db.Dialect(database))
+	if err != nil {
+		panic(fmt.Sprintf("Could not load migrations: %v", err))
+	}
+	if err := migrate.New(database, auth.MigrationsName, migrations).Check(); err != nil {
+		panic(fmt.Sprintf("%v, run migrate -service auth up", err))
+	}
+	if err := migrate.New(database, audit.MigrationsName, auditMigrations).Check(); err != nil {
+		panic(fmt.Sprintf("%v, run migrate -service auth up", err))
+	}
+
+	redisClient := adapters.NewRedisClient(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+
+	auditLog := audit.NewLog(database)
+	tokenStore := tokens.NewStore(redisClient)
+
+	keySet, err := jwks.LoadKeySet(*cfg)
+	if err != nil {
+		panic(fmt.Sprintf("Could not load jwt keys: %v", err))
+	}
+
+	tokenVault, err := vault.NewCipher(cfg.TokenVaultKey)
+	if err != nil {
+		panic(fmt.Sprintf("Could not load token vault key: %v", err))
+	}
+
+	policy, err := permissions.NewPolicy(cfg.Roles)
+	if err != nil {
+		panic(fmt.Sprintf("Could not load roles: %v", err))
+	}
 
 	authRepository := auth.NewAuthRepository(database)
-	authUseCase := auth.NewAuthUseCase(*authRepository, *cfg)
+	authUseCase := auth.NewAuthUseCase(*authRepository, *cfg, redisClient, auditLog, keySet, tokenVault, policy)
 	authHandler := auth.NewAuthHandler(*authUseCase, cfg.OpenGraphClientID)
+	auditHandler := audit.NewHandler(auditLog)
+	jwksHandler := jwks.NewHandler(keySet)
 
 	e := echo.New()
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
-	config := echojwt.Config{
-		NewClaimsFunc: func(c echo.Context) jwt.Claims {
-			return new(utils.JwtCustomClaims)
-		},
-		SigningKey: []byte(cfg.JwtSecret),
-	}
-
-	// Public routes
-	e.POST("/api/auth/login/callback", authHandler.AuthenticateUserHandler)
-	e.GET("/api/auth/login", authHandler.LoginWithFacebook)
-
-	// Protected routes
-	api := e.Group("/api")
-	api.Use(echojwt.WithConfig(config))
-
-	// User routes
-	api.GET("/auth/users/me", authHandler.GetCurrentUser)
-	api.GET("/auth/users", authHandler.GetAllUsersHandler)
-	api.GET("/auth/users/:id", authHandler.GetUserByIDHandler)
+	authMiddleware := customMiddleware.Authenticate(customMiddleware.AuthConfig{
+		Keys:   keySet.Keyfunc,
+		Tokens: tokenStore,
+	})
 
-	// Moderator routes
-	moderator := api.Group("/admin")
-	moderator.Use(customMiddleware.RequireRole(models.RoleModerator, models.RoleAdmin))
-	moderator.PUT("/users/:id/role", authHandler.UpdateUserRole)
-	moderator.PUT("/users/:id/status", authHandler.UpdateUserStatus)
+	auth.RegisterRoutes(e, authHandler, auditHandler, jwksHandler, authMiddleware, policy)
 
 	e.Logger.Fatal(e.Start(fmt.Sprintf(":%s", cfg.Port)))
 }
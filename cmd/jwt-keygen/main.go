@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+)
+
+// jwt-keygen writes a new Ed25519 private key for the auth service's jwt_keys.
+// To rotate, add the new key to jwt_keys and set retired_at on the key it replaces.
+func main() {
+	out := flag.String("out", "", "file to write the PEM encoded private key to")
+	flag.Parse()
+
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "usage: jwt-keygen -out config/keys/<kid>.pem")
+		os.Exit(2)
+	}
+
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not generate key: %v\n", err)
+		os.Exit(1)
+	}
+
+	encoded, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "could not encode key: %v\n", err)
+		os.Exit(1)
+	}
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: encoded})
+	if err := os.WriteFile(*out, content, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "could not write key: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("wrote %s\n", *out)
+}
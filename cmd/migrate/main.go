@@ -0,0 +1,139 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/internal/auth"
+	"github.com/mwelwankuta/facebook-notes/internal/summaries"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+)
+
+const usage = `usage: migrate -service auth|summaries [-config file] <command>
+
+commands:
+  up        apply every pending migration
+  down      revert the newest applied migration
+  status    list the migrations and whether they are applied
+  to N      migrate up or down to version N
+
+The audit log's table has migrations of its own. up applies them as well and status lists them, down
+and to only move the service's migrations.
+`
+
+func main() {
+	service := flag.String("service", "", "the service whose database to migrate: auth or summaries")
+	configFile := flag.String("config", "", "config file, by default config/<service>-config.yaml")
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+
+	if *service != "auth" && *service != "summaries" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if *configFile == "" {
+		*configFile = fmt.Sprintf("config/%s-config.yaml", *service)
+	}
+	cfg, err := config.LoadConfig(*configFile)
+	if err != nil {
+		fail(fmt.Errorf("could not load config file %s: %w", *configFile, err))
+	}
+
+	database, err := db.Open(cfg.DatabaseDriver, cfg.Database)
+	if err != nil {
+		fail(err)
+	}
+
+	var migrator *migrate.Migrator
+	if *service == "auth" {
+		migrations, err := auth.Migrations(db.Dialect(database))
+		if err != nil {
+			fail(err)
+		}
+		migrator = migrate.New(database, auth.MigrationsName, migrations)
+	} else {
+		migrations, err := summaries.Migrations(db.Dialect(database))
+		if err != nil {
+			fail(err)
+		}
+		migrator = migrate.New(database, summaries.MigrationsName, migrations)
+	}
+
+	auditMigrations, err := audit.Migrations(db.Dialect(database))
+	if err != nil {
+		fail(err)
+	}
+	auditMigrator := migrate.New(database, audit.MigrationsName, auditMigrations)
+
+	args := flag.Args()
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	switch args[0] {
+	case "up":
+		if err = auditMigrator.Up(); err == nil {
+			err = migrator.Up()
+		}
+	case "down":
+		err = migrator.Down()
+	case "to":
+		if len(args) != 2 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		version, convErr := strconv.Atoi(args[1])
+		if convErr != nil {
+			fail(fmt.Errorf("version must be a number: %s", args[1]))
+		}
+		err = migrator.To(version)
+	case "status":
+		if err = printStatus(audit.MigrationsName, auditMigrator); err == nil {
+			err = printStatus(*service, migrator)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	if args[0] != "status" {
+		version, err := migrator.Version()
+		if err != nil {
+			fail(err)
+		}
+		fmt.Printf("%s database is at version %d of %d\n", *service, version, migrator.Latest())
+	}
+}
+
+func printStatus(name string, migrator *migrate.Migrator) error {
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s migrations:\n", name)
+	for _, status := range statuses {
+		applied := "pending"
+		if status.AppliedAt != nil {
+			applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%04d %-40s %s\n", status.Version, status.Name, applied)
+	}
+	return nil
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/internal/summaries"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+	"github.com/mwelwankuta/facebook-notes/pkg/scoring"
+)
+
+func main() {
+	interval := flag.Duration("interval", 0, "rescore every interval; runs once when 0")
+	flag.Parse()
+
+	params := scoring.DefaultParams()
+
+	cfg, err := config.LoadConfig("config/summaries-config.yaml")
+	if err != nil {
+		panic("Could not load config file")
+	}
+
+	database := db.InitializeDatabase(cfg.DatabaseDriver, cfg.Database)
+
+	migrations, err := summaries.Migrations(db.Dialect(database))
+	if err != nil {
+		log.Fatalf("could not load migrations: %v", err)
+	}
+	if err := migrate.New(database, summaries.MigrationsName, migrations).Check(); err != nil {
+		log.Fatalf("%v, run migrate -service summaries up", err)
+	}
+	summariesRepository := summaries.NewSummariesRepository(database)
+	scorer := summaries.NewHelpfulnessScorer(*summariesRepository, params)
+
+	run := func() {
+		start := time.Now()
+		result, err := scorer.Run()
+		if err != nil {
+			log.Printf("scoring failed: %v", err)
+			return
+		}
+		log.Printf("scored %d summaries from %d raters in %s: %v", len(result.Notes), len(result.Raters), time.Since(start), countStatuses(result))
+	}
+
+	run()
+	if *interval <= 0 {
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			run()
+		}
+	}
+}
+
+func countStatuses(result scoring.Result) map[string]int {
+	counts := map[string]int{}
+	for _, note := range result.Notes {
+		counts[note.Status]++
+	}
+	return counts
+}
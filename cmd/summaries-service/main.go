@@ -1,15 +1,26 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"time"
 
-	echojwt "github.com/labstack/echo-jwt/v4"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 
 	"github.com/mwelwankuta/facebook-notes/internal/summaries"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
 	"github.com/mwelwankuta/facebook-notes/pkg/config"
 	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/jwks"
+	customMiddleware "github.com/mwelwankuta/facebook-notes/pkg/middleware"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/queue"
+	"github.com/mwelwankuta/facebook-notes/pkg/search"
+	"github.com/mwelwankuta/facebook-notes/pkg/tokens"
 )
 
 func main() {
@@ -18,39 +29,79 @@ func main() {
 		panic("Could not load config file")
 	}
 
-	database := db.InitializeDatabase(cfg.Database)
+	database := db.InitializeDatabase(cfg.DatabaseDriver, cfg.Database)
+
+	migrations, err := summaries.Migrations(db.Dialect(database))
+	if err != nil {
+		panic(fmt.Sprintf("Could not load migrations: %v", err))
+	}
+	auditMigrations, err := audit.Migrations(db.Dialect(database))
+	if err != nil {
+		panic(fmt.Sprintf("Could not load migrations: %v", err))
+	}
+	if err := migrate.New(database, summaries.MigrationsName, migrations).Check(); err != nil {
+		panic(fmt.Sprintf("%v, run migrate -service summaries up", err))
+	}
+	if err := migrate.New(database, audit.MigrationsName, auditMigrations).Check(); err != nil {
+		panic(fmt.Sprintf("%v, run migrate -service summaries up", err))
+	}
+
+	redisClient := adapters.NewRedisClient(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	summarizer := summaries.NewSummarizer(*cfg)
+	jobs := queue.NewQueue(redisClient, summaries.QueueName(*cfg), queue.Options{
+		VisibilityTimeout: cfg.Queue.VisibilityTimeout,
+		MaxAttempts:       cfg.Queue.MaxAttempts,
+		BaseBackoff:       cfg.Queue.BaseBackoff,
+		MaxBackoff:        cfg.Queue.MaxBackoff,
+	})
+
+	auditLog := audit.NewLog(database)
+	tokenStore := tokens.NewStore(redisClient)
+	if cfg.JwksURL == "" {
+		panic("jwks_url must point at the auth service's /.well-known/jwks.json")
+	}
+	keySet := jwks.NewRemoteKeySet(cfg.JwksURL, cfg.JwksRefreshInterval)
+
+	policy, err := permissions.NewPolicy(cfg.Roles)
+	if err != nil {
+		panic(fmt.Sprintf("Could not load roles: %v", err))
+	}
 
 	summariesRepository := summaries.NewSummariesRepository(database)
-	summariesUseCase := summaries.NewSummariesUseCase(*summariesRepository, *cfg)
+	searchIndex := summaries.NewSearchIndex(*cfg, database)
+	summariesUseCase := summaries.NewSummariesUseCase(*summariesRepository, *cfg, redisClient, summarizer, jobs, auditLog, policy, searchIndex)
 	summariesHandler := summaries.NewSummariesHandler(*summariesUseCase, cfg.OpenGraphClientID)
+	auditHandler := audit.NewHandler(auditLog)
+
+	// A memory index only sees this process's changes, so it is rebuilt from the database periodically
+	if _, ok := searchIndex.(*search.MemoryIndex); ok {
+		go refreshSearchIndex(summariesUseCase, cfg.Search.RefreshInterval)
+	}
 
 	e := echo.New()
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 
-	jwtMiddleware := echojwt.WithConfig(echojwt.Config{
-		SigningKey:  []byte(cfg.JwtSecret),
-		TokenLookup: "header:Authorization",
+	authMiddleware := customMiddleware.Authenticate(customMiddleware.AuthConfig{
+		Keys:   keySet.Keyfunc,
+		Tokens: tokenStore,
 	})
 
-	// Protected routes requiring authentication
-	protected := e.Group("")
-	protected.Use(jwtMiddleware)
-
-	// User routes
-	protected.POST("/api/summaries/requests", summariesHandler.CreateSummaryRequestHandler)
-	protected.POST("/api/summaries/:id/rate", summariesHandler.RateSummaryHandler)
+	summaries.RegisterRoutes(e, summariesHandler, auditHandler, authMiddleware, policy)
 
-	// Moderator routes
-	protected.POST("/api/summaries/:id/moderate", summariesHandler.ModerateSummaryHandler)
-	protected.PUT("/api/summaries/:id/edit", summariesHandler.EditSummaryHandler)
-	protected.POST("/api/summaries/:id/resources", summariesHandler.AddResourceLinkHandler)
-	protected.DELETE("/api/summaries/:id/resources/:linkId", summariesHandler.RemoveResourceLinkHandler)
+	e.Logger.Fatal(e.Start(fmt.Sprintf(":%s", cfg.Port)))
+}
 
-	// Public routes
-	e.GET("/api/summaries", summariesHandler.GetAllSummariesHandler)
-	e.GET("/api/summaries/requests", summariesHandler.GetAllRequestsHandler)
-	e.GET("/api/summaries/:id", summariesHandler.GetSummaryByIDHandler)
+// refreshSearchIndex rebuilds the search index now and then every interval
+func refreshSearchIndex(useCase *summaries.SummariesUseCase, interval time.Duration) {
+	if interval <= 0 {
+		interval = summaries.DefaultSearchRefreshInterval
+	}
 
-	e.Logger.Fatal(e.Start(fmt.Sprintf(":%s", cfg.Port)))
+	for {
+		if err := useCase.RebuildSearchIndex(context.Background()); err != nil {
+			log.Printf("could not rebuild search index: %v", err)
+		}
+		time.Sleep(interval)
+	}
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mwelwankuta/facebook-notes/internal/summaries"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/queue"
+)
+
+func main() {
+	cfg, err := config.LoadConfig("config/summaries-config.yaml")
+	if err != nil {
+		panic("Could not load config file")
+	}
+
+	database := db.InitializeDatabase(cfg.DatabaseDriver, cfg.Database)
+
+	migrations, err := summaries.Migrations(db.Dialect(database))
+	if err != nil {
+		log.Fatalf("could not load migrations: %v", err)
+	}
+	auditMigrations, err := audit.Migrations(db.Dialect(database))
+	if err != nil {
+		log.Fatalf("could not load migrations: %v", err)
+	}
+	if err := migrate.New(database, summaries.MigrationsName, migrations).Check(); err != nil {
+		log.Fatalf("%v, run migrate -service summaries up", err)
+	}
+	if err := migrate.New(database, audit.MigrationsName, auditMigrations).Check(); err != nil {
+		log.Fatalf("%v, run migrate -service summaries up", err)
+	}
+
+	redisClient := adapters.NewRedisClient(cfg.Redis.Addr, cfg.Redis.Password, cfg.Redis.DB)
+	summarizer := summaries.NewSummarizer(*cfg)
+	jobs := queue.NewQueue(redisClient, summaries.QueueName(*cfg), queue.Options{
+		VisibilityTimeout: cfg.Queue.VisibilityTimeout,
+		MaxAttempts:       cfg.Queue.MaxAttempts,
+		BaseBackoff:       cfg.Queue.BaseBackoff,
+		MaxBackoff:        cfg.Queue.MaxBackoff,
+	})
+
+	auditLog := audit.NewLog(database)
+
+	policy, err := permissions.NewPolicy(cfg.Roles)
+	if err != nil {
+		log.Fatalf("could not load roles: %v", err)
+	}
+
+	summariesRepository := summaries.NewSummariesRepository(database)
+	summariesUseCase := summaries.NewSummariesUseCase(*summariesRepository, *cfg, redisClient, summarizer, jobs, auditLog, policy, summaries.NewSearchIndex(*cfg, database))
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	pool := queue.NewWorkerPool(jobs, summariesUseCase.HandleJob, cfg.Queue.Workers)
+
+	log.Printf("summaries worker started with %d workers", max(cfg.Queue.Workers, 1))
+	pool.Run(ctx)
+	log.Println("summaries worker stopped")
+}
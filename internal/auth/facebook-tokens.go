@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"gorm.io/gorm"
+)
+
+// facebookTokenRefreshWindow is how long before expiry a stored token is exchanged for a fresh one
+const facebookTokenRefreshWindow = 7 * 24 * time.Hour
+
+var ErrFacebookTokenExpired = errors.New("facebook token has expired, the user has to log in again")
+
+// storeFacebookToken exchanges a user access token for a long-lived one and stores it encrypted in the vault.
+// If the exchange fails the short-lived token is stored instead.
+func (a *AuthUseCase) storeFacebookToken(userID string, token models.FacebookAccessToken) error {
+	longLived, err := adapters.ExchangeLongLivedToken(a.config.FacebookTokenURL, token.AccessToken, a.config.OpenGraphClientID, a.config.OpenGraphClientSecret)
+	if err != nil {
+		log.Printf("could not exchange facebook token of user %s for a long-lived token: %v", userID, err)
+	} else {
+		token = longLived
+	}
+
+	return a.saveFacebookToken(userID, token)
+}
+
+func (a *AuthUseCase) saveFacebookToken(userID string, token models.FacebookAccessToken) error {
+	ciphertext, err := a.vault.Seal([]byte(token.AccessToken), []byte(userID))
+	if err != nil {
+		return err
+	}
+
+	stored := FacebookToken{UserID: userID, Ciphertext: ciphertext}
+	if token.ExpiresIn > 0 {
+		stored.ExpiresAt = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return a.repo.SaveFacebookToken(stored)
+}
+
+// FacebookAccessToken returns a valid Facebook access token for Graph calls on the user's behalf.
+// Tokens close to expiry are exchanged for a fresh long-lived token first.
+func (a *AuthUseCase) FacebookAccessToken(ctx context.Context, userID string) (string, error) {
+	stored, err := a.repo.GetFacebookToken(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", ErrFacebookTokenExpired
+	}
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := a.vault.Open(stored.Ciphertext, []byte(userID))
+	if err != nil {
+		return "", err
+	}
+	accessToken := string(plaintext)
+
+	// A zero expiry means the token doesn't expire
+	if stored.ExpiresAt.IsZero() || time.Until(stored.ExpiresAt) > facebookTokenRefreshWindow {
+		return accessToken, nil
+	}
+
+	if time.Now().After(stored.ExpiresAt) {
+		return "", ErrFacebookTokenExpired
+	}
+
+	refreshed, err := adapters.ExchangeLongLivedToken(a.config.FacebookTokenURL, accessToken, a.config.OpenGraphClientID, a.config.OpenGraphClientSecret)
+	if err != nil {
+		// The stored token is still valid, so the refresh is retried on the next call
+		log.Printf("could not refresh facebook token of user %s: %v", userID, err)
+		return accessToken, nil
+	}
+
+	if err := a.saveFacebookToken(userID, refreshed); err != nil {
+		return "", err
+	}
+
+	return refreshed.AccessToken, nil
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+)
+
+// newTokenServer stubs the Graph API token endpoint, answering every exchange with response
+func newTokenServer(t *testing.T, status int, response string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var exchanges atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges.Add(1)
+		if got := r.URL.Query().Get("fb_exchange_token"); got != "long-lived" {
+			t.Errorf("exchanged %q, want the stored token", got)
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+	return server, &exchanges
+}
+
+// saveExpiringToken stores the token "long-lived" for user, expiring after expiresIn
+func saveExpiringToken(t *testing.T, uc *AuthUseCase, expiresIn time.Duration) {
+	t.Helper()
+	if err := uc.saveFacebookToken("user", models.FacebookAccessToken{AccessToken: "long-lived", ExpiresIn: int64(expiresIn / time.Second)}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFacebookAccessToken(t *testing.T) {
+	uc, repo := newTestUseCase(t)
+	ctx := context.Background()
+
+	if _, err := uc.FacebookAccessToken(ctx, "user"); !errors.Is(err, ErrFacebookTokenExpired) {
+		t.Errorf("a user without a token returned %v, want ErrFacebookTokenExpired", err)
+	}
+
+	if err := uc.saveFacebookToken("user", models.FacebookAccessToken{AccessToken: "long-lived"}); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.GetFacebookToken("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(stored.Ciphertext, "long-lived") {
+		t.Error("the token is stored in plain text")
+	}
+	token, err := uc.FacebookAccessToken(ctx, "user")
+	if err != nil || token != "long-lived" {
+		t.Errorf("FacebookAccessToken = %q, %v", token, err)
+	}
+
+	// The ciphertext is bound to the user it was stored for
+	if err := repo.SaveFacebookToken(FacebookToken{UserID: "other", Ciphertext: stored.Ciphertext}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.FacebookAccessToken(ctx, "other"); err == nil {
+		t.Error("another user's ciphertext was decrypted")
+	}
+}
+
+func TestFacebookAccessTokenRefresh(t *testing.T) {
+	server, exchanges := newTokenServer(t, http.StatusOK, `{"access_token": "refreshed", "expires_in": 5184000}`)
+	uc, repo := newTestUseCaseWithConfig(t, config.Config{FacebookTokenURL: server.URL})
+	ctx := context.Background()
+
+	// Outside the refresh window the stored token is used as it is
+	saveExpiringToken(t, uc, 30*24*time.Hour)
+	if token, err := uc.FacebookAccessToken(ctx, "user"); err != nil || token != "long-lived" {
+		t.Fatalf("FacebookAccessToken = %q, %v", token, err)
+	}
+	if got := exchanges.Load(); got != 0 {
+		t.Fatalf("%d exchanges outside the refresh window, want none", got)
+	}
+
+	saveExpiringToken(t, uc, 3*24*time.Hour)
+	token, err := uc.FacebookAccessToken(ctx, "user")
+	if err != nil || token != "refreshed" {
+		t.Fatalf("FacebookAccessToken = %q, %v, want the refreshed token", token, err)
+	}
+	if got := exchanges.Load(); got != 1 {
+		t.Errorf("%d exchanges, want 1", got)
+	}
+
+	stored, err := repo.GetFacebookToken("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if until := time.Until(stored.ExpiresAt); until < 59*24*time.Hour {
+		t.Errorf("the refreshed token expires in %s, want about 60 days", until)
+	}
+	if strings.Contains(stored.Ciphertext, "refreshed") {
+		t.Error("the refreshed token is stored in plain text")
+	}
+	if token, err := uc.FacebookAccessToken(ctx, "user"); err != nil || token != "refreshed" || exchanges.Load() != 1 {
+		t.Errorf("FacebookAccessToken = %q, %v after %d exchanges, want the stored refreshed token", token, err, exchanges.Load())
+	}
+}
+
+func TestFacebookAccessTokenRefreshFails(t *testing.T) {
+	server, exchanges := newTokenServer(t, http.StatusBadRequest, `{"error": {"message": "Service unavailable", "code": 2}}`)
+	uc, repo := newTestUseCaseWithConfig(t, config.Config{FacebookTokenURL: server.URL})
+	ctx := context.Background()
+
+	saveExpiringToken(t, uc, 3*24*time.Hour)
+	before, err := repo.GetFacebookToken("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The stored token hasn't expired yet, so it is used until a refresh succeeds
+	if token, err := uc.FacebookAccessToken(ctx, "user"); err != nil || token != "long-lived" {
+		t.Errorf("FacebookAccessToken = %q, %v, want the stored token", token, err)
+	}
+	if got := exchanges.Load(); got != 1 {
+		t.Errorf("%d exchanges, want 1", got)
+	}
+	after, err := repo.GetFacebookToken("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Ciphertext != before.Ciphertext || !after.ExpiresAt.Equal(before.ExpiresAt) {
+		t.Error("a failed refresh changed the stored token")
+	}
+}
+
+func TestFacebookAccessTokenExpired(t *testing.T) {
+	server, exchanges := newTokenServer(t, http.StatusOK, `{"access_token": "refreshed", "expires_in": 5184000}`)
+	uc, repo := newTestUseCaseWithConfig(t, config.Config{FacebookTokenURL: server.URL})
+
+	saveExpiringToken(t, uc, time.Hour)
+	stored, err := repo.GetFacebookToken("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.ExpiresAt = time.Now().Add(-time.Minute)
+	if err := repo.SaveFacebookToken(stored); err != nil {
+		t.Fatal(err)
+	}
+
+	// An expired token can't be exchanged, the user has to log in again
+	if _, err := uc.FacebookAccessToken(context.Background(), "user"); !errors.Is(err, ErrFacebookTokenExpired) {
+		t.Errorf("an expired token returned %v, want ErrFacebookTokenExpired", err)
+	}
+	if got := exchanges.Load(); got != 0 {
+		t.Errorf("%d exchanges of an expired token, want none", got)
+	}
+}
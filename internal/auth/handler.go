@@ -1,12 +1,13 @@
 package auth
 
 import (
-	"fmt"
+	"errors"
 	"net/http"
-	"net/url"
 
 	"github.com/labstack/echo/v4"
-	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/tokens"
 	"github.com/mwelwankuta/facebook-notes/pkg/utils"
 )
 
@@ -30,18 +31,85 @@ func (a *AuthHandler) AuthenticateUserHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Code not found"))
 	}
 
-	user, err := a.useCase.AuthenticateUser(code)
+	state := c.QueryParam("state")
+	if state == "" {
+		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse("State not found"))
+	}
+
+	var stateCookie string
+	if cookie, err := c.Cookie(LoginStateCookie); err == nil {
+		stateCookie = cookie.Value
+	}
+	// The state can only be used once, whatever the outcome
+	c.SetCookie(loginStateCookie("", -1))
+
+	user, err := a.useCase.AuthenticateUser(code, state, stateCookie)
 	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidState):
+			return c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		case errors.Is(err, ErrUserInactive):
+			return c.JSON(http.StatusForbidden, utils.NewErrorResponse(err.Error()))
+		}
 		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal server error"))
 	}
 
 	return c.JSON(http.StatusOK, user)
 }
 
+// RefreshTokenHandler exchanges a refresh token for a new access token and refresh token
+func (a *AuthHandler) RefreshTokenHandler(c echo.Context) error {
+	var req RefreshTokenRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request"))
+	}
+
+	if err := utils.Validate(req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+	}
+
+	response, err := a.useCase.RefreshTokens(c.Request().Context(), req.RefreshToken)
+	if err != nil {
+		switch {
+		case errors.Is(err, tokens.ErrInvalidRefreshToken), errors.Is(err, tokens.ErrRefreshTokenReused), errors.Is(err, ErrUserInactive):
+			return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse(err.Error()))
+		}
+		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal server error"))
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// LogoutHandler revokes the access token of the request and the refresh token in the body, if any
+func (a *AuthHandler) LogoutHandler(c echo.Context) error {
+	principal, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Unauthorized"))
+	}
+
+	var req LogoutRequest
+	if err := c.Bind(&req); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse("Invalid request"))
+	}
+
+	if err := a.useCase.Logout(c.Request().Context(), principal, req.RefreshToken); err != nil {
+		if errors.Is(err, tokens.ErrRefreshTokenNotOwned) {
+			return c.JSON(http.StatusForbidden, utils.NewErrorResponse(err.Error()))
+		}
+		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal server error"))
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
 // GetAllUsersHandler returns all users
 func (a *AuthHandler) GetAllUsersHandler(c echo.Context) error {
-	dto := utils.GetPaginationFromQuery(c)
-	users, err := a.useCase.GetAllUsers(dto)
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+	}
+
+	users, err := a.useCase.GetAllUsers(params)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal server error"))
 	}
@@ -64,12 +132,31 @@ func (a *AuthHandler) GetUserByIDHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, user)
 }
 
-// LoginWithFacebook initiates the Facebook OAuth flow
+// LoginWithFacebook initiates the Facebook OAuth flow with a single use state and a PKCE challenge
 func (a *AuthHandler) LoginWithFacebook(c echo.Context) error {
-	authURL := fmt.Sprintf("%s?client_id=%s&redirect_uri=%s&scope=email", config.FbAuthURL, a.OpenGraphClientID, url.QueryEscape(config.RedirectURI))
+	authURL, stateCookie, err := a.useCase.BeginLogin(c.Request().Context())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("Internal server error"))
+	}
+
+	c.SetCookie(loginStateCookie(stateCookie, int(loginStateTTL.Seconds())))
 	return c.String(http.StatusOK, authURL)
 }
 
+// loginStateCookie binds a login to the browser that started it. SameSite=Lax still sends it on the
+// top-level redirect back from Facebook.
+func loginStateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     LoginStateCookie,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
 func (a *AuthHandler) GetCurrentUser(c echo.Context) error {
 	user, err := utils.GetUserFromContext(c)
 	if err != nil {
@@ -79,6 +166,11 @@ func (a *AuthHandler) GetCurrentUser(c echo.Context) error {
 }
 
 func (a *AuthHandler) UpdateUserRole(c echo.Context) error {
+	actor, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Unauthorized"))
+	}
+
 	userId := c.Param("id")
 	var req UpdateRoleRequest
 	if err := c.Bind(&req); err != nil {
@@ -89,8 +181,14 @@ func (a *AuthHandler) UpdateUserRole(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
 	}
 
-	user, err := a.useCase.UpdateUserRole(userId, req.Role)
+	user, err := a.useCase.UpdateUserRole(actor, userId, req.Role)
 	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.NewErrorResponse(err.Error()))
+		case errors.Is(err, ErrInvalidRole):
+			return c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+		}
 		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse(err.Error()))
 	}
 
@@ -98,6 +196,11 @@ func (a *AuthHandler) UpdateUserRole(c echo.Context) error {
 }
 
 func (a *AuthHandler) UpdateUserStatus(c echo.Context) error {
+	actor, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("Unauthorized"))
+	}
+
 	userId := c.Param("id")
 	var req UpdateStatusRequest
 	if err := c.Bind(&req); err != nil {
@@ -108,8 +211,11 @@ func (a *AuthHandler) UpdateUserStatus(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
 	}
 
-	user, err := a.useCase.UpdateUserStatus(userId, req.IsActive)
+	user, err := a.useCase.UpdateUserStatus(actor, userId, *req.IsActive)
 	if err != nil {
+		if errors.Is(err, permissions.ErrForbidden) {
+			return c.JSON(http.StatusForbidden, utils.NewErrorResponse(err.Error()))
+		}
 		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse(err.Error()))
 	}
 
@@ -0,0 +1,19 @@
+package auth
+
+import (
+	"embed"
+	"path"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+)
+
+// MigrationsName is the name the auth service's migrations are recorded under
+const MigrationsName = "auth"
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrations returns the schema migrations of the auth service database for the given db dialect
+func Migrations(dialect string) ([]migrate.Migration, error) {
+	return migrate.Load(migrationFiles, path.Join("migrations", dialect))
+}
@@ -0,0 +1,25 @@
+CREATE TABLE users (
+  id VARCHAR(191) NOT NULL,
+  created_at DATETIME(3) NULL,
+  updated_at DATETIME(3) NULL,
+  deleted_at DATETIME(3) NULL,
+  facebook_id VARCHAR(191) NOT NULL,
+  name LONGTEXT,
+  picture LONGTEXT,
+  role VARCHAR(64) NOT NULL DEFAULT 'user',
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  PRIMARY KEY (id),
+  UNIQUE KEY idx_users_facebook_id (facebook_id),
+  KEY idx_users_deleted_at (deleted_at),
+  KEY idx_users_created_at_id (created_at, id)
+);
+
+-- Facebook access tokens, encrypted with the token vault key
+CREATE TABLE facebook_tokens (
+  user_id VARCHAR(191) NOT NULL,
+  ciphertext TEXT NOT NULL,
+  expires_at DATETIME(3) NULL,
+  created_at DATETIME(3) NULL,
+  updated_at DATETIME(3) NULL,
+  PRIMARY KEY (user_id)
+);
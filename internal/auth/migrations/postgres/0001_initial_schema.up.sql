@@ -0,0 +1,25 @@
+CREATE TABLE users (
+  id VARCHAR(191) NOT NULL,
+  created_at TIMESTAMPTZ NULL,
+  updated_at TIMESTAMPTZ NULL,
+  deleted_at TIMESTAMPTZ NULL,
+  facebook_id VARCHAR(191) NOT NULL,
+  name TEXT,
+  picture TEXT,
+  role VARCHAR(64) NOT NULL DEFAULT 'user',
+  is_active BOOLEAN NOT NULL DEFAULT TRUE,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_users_facebook_id ON users (facebook_id);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE INDEX idx_users_created_at_id ON users (created_at, id);
+
+-- Facebook access tokens, encrypted with the token vault key
+CREATE TABLE facebook_tokens (
+  user_id VARCHAR(191) NOT NULL,
+  ciphertext TEXT NOT NULL,
+  expires_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NULL,
+  updated_at TIMESTAMPTZ NULL,
+  PRIMARY KEY (user_id)
+);
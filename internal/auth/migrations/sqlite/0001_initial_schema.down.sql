@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS facebook_tokens;
+DROP TABLE IF EXISTS users;
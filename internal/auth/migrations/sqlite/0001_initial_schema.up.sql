@@ -0,0 +1,25 @@
+CREATE TABLE users (
+  id TEXT NOT NULL,
+  created_at DATETIME NULL,
+  updated_at DATETIME NULL,
+  deleted_at DATETIME NULL,
+  facebook_id TEXT NOT NULL,
+  name TEXT,
+  picture TEXT,
+  role TEXT NOT NULL DEFAULT 'user',
+  is_active NUMERIC NOT NULL DEFAULT TRUE,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_users_facebook_id ON users (facebook_id);
+CREATE INDEX idx_users_deleted_at ON users (deleted_at);
+CREATE INDEX idx_users_created_at_id ON users (created_at, id);
+
+-- Facebook access tokens, encrypted with the token vault key
+CREATE TABLE facebook_tokens (
+  user_id TEXT NOT NULL,
+  ciphertext TEXT NOT NULL,
+  expires_at DATETIME NULL,
+  created_at DATETIME NULL,
+  updated_at DATETIME NULL,
+  PRIMARY KEY (user_id)
+);
@@ -1,11 +1,28 @@
 package auth
 
-import "github.com/mwelwankuta/facebook-notes/pkg/models"
+import (
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+)
 
 // AuthenticateUserResponse is a struct that represents the data that is returned when a user is authenticated
 type AuthenticateUserResponse struct {
-	User  models.User `json:"user"`
-	Token string      `json:"token"`
+	User         models.User `json:"user"`
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refresh_token"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int `json:"expires_in"`
+}
+
+// RefreshTokenRequest exchanges a refresh token for a new access and refresh token
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// LogoutRequest optionally carries the refresh token to revoke along with the access token
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // GetUserByIDDto is a struct that represents the data that is required to get a user by ID
@@ -14,9 +31,20 @@ type GetUserByIDDto struct {
 }
 
 type UpdateRoleRequest struct {
-	Role string `json:"role" validate:"required,oneof=user moderator admin"`
+	Role string `json:"role" validate:"required"`
 }
 
+// UpdateStatusRequest activates or deactivates a user. IsActive is a pointer so a missing field can be told
+// apart from false.
 type UpdateStatusRequest struct {
-	IsActive bool `json:"is_active" validate:"required"`
+	IsActive *bool `json:"is_active" validate:"required"`
+}
+
+// FacebookToken is a user's Facebook access token, encrypted with the token vault key
+type FacebookToken struct {
+	UserID     string    `json:"user_id" gorm:"primarykey"`
+	Ciphertext string    `json:"-" gorm:"type:text"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
 }
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// loginStateTTL is how long a user has to complete the Facebook login dialog
+const loginStateTTL = 10 * time.Minute
+
+// LoginStateCookie holds the hash of the login state in the browser that started the login, so a callback
+// with a state from someone else's login is rejected
+const LoginStateCookie = "fb_login_state"
+
+var ErrInvalidState = errors.New("login state is invalid, expired or already used")
+
+// loginState is stored in redis under the state parameter for the duration of a login
+type loginState struct {
+	CodeVerifier string    `json:"code_verifier"`
+	RedirectURI  string    `json:"redirect_uri"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func loginStateKey(state string) string {
+	return fmt.Sprintf("oauth:state:%s", state)
+}
+
+// randomToken returns n random bytes encoded as unpadded base64url
+func randomToken(n int) (string, error) {
+	buf := make([]byte, n)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// pkceChallenge derives the S256 code challenge from a code verifier
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// hashState returns the value of the login state cookie for a state
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// BeginLogin creates a state and PKCE verifier for a new login. It returns the Facebook login dialog url
+// and the value of the LoginStateCookie to set in the browser.
+func (a *AuthUseCase) BeginLogin(ctx context.Context) (string, string, error) {
+	state, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 32 random bytes give a 43 character verifier, the minimum length allowed by RFC 7636
+	verifier, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = a.redis.Set(ctx, loginStateKey(state), loginState{
+		CodeVerifier: verifier,
+		RedirectURI:  a.config.RedirectURI,
+		CreatedAt:    time.Now(),
+	}, loginStateTTL)
+	if err != nil {
+		return "", "", err
+	}
+
+	query := url.Values{}
+	query.Set("client_id", a.config.OpenGraphClientID)
+	query.Set("redirect_uri", a.config.RedirectURI)
+	query.Set("response_type", "code")
+	query.Set("scope", "email")
+	query.Set("state", state)
+	query.Set("code_challenge", pkceChallenge(verifier))
+	query.Set("code_challenge_method", "S256")
+
+	return fmt.Sprintf("%s?%s", config.FbAuthURL, query.Encode()), hashState(state), nil
+}
+
+// consumeLoginState returns the login started with state and deletes it, so a state can only be used once.
+// cookie is the LoginStateCookie of the browser completing the login, which must belong to the same state.
+func (a *AuthUseCase) consumeLoginState(ctx context.Context, state string, cookie string) (loginState, error) {
+	var login loginState
+	if state == "" || subtle.ConstantTimeCompare([]byte(hashState(state)), []byte(cookie)) != 1 {
+		return login, ErrInvalidState
+	}
+
+	if err := a.redis.GetDel(ctx, loginStateKey(state), &login); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return login, ErrInvalidState
+		}
+		return login, err
+	}
+
+	return login, nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestConsumeLoginStateRequiresCookie(t *testing.T) {
+	// No redis client: a state that doesn't match the cookie must be rejected before it is looked up
+	uc := &AuthUseCase{}
+
+	tests := []struct {
+		name   string
+		state  string
+		cookie string
+	}{
+		{"no state", "", hashState("")},
+		{"no cookie", "state", ""},
+		{"cookie of another state", "state", hashState("other")},
+		{"raw state as cookie", "state", "state"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := uc.consumeLoginState(context.Background(), tt.state, tt.cookie); !errors.Is(err, ErrInvalidState) {
+				t.Errorf("consumeLoginState returned %v, want ErrInvalidState", err)
+			}
+		})
+	}
+}
+
+func TestLoginStateCookie(t *testing.T) {
+	cookie := loginStateCookie(hashState("state"), 600)
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie = %+v", cookie)
+	}
+}
@@ -1,8 +1,11 @@
 package auth
 
 import (
+	"github.com/google/uuid"
 	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type AuthRepository struct {
@@ -15,16 +18,21 @@ func NewAuthRepository(db *gorm.DB) *AuthRepository {
 	}
 }
 
-// GetAllUsers returns all users
-func (a *AuthRepository) GetAllUsers(dto models.PaginateDto) ([]models.User, error) {
-	var users []models.User
+// Transaction runs fn with a repository whose queries all run in one transaction, which is committed when
+// fn returns nil
+func (a *AuthRepository) Transaction(fn func(repo *AuthRepository) error) error {
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&AuthRepository{db: tx})
+	})
+}
 
-	result := a.db.Find(&users).Offset(dto.Offset).Limit(dto.Limit)
-	if result.Error != nil {
-		return users, result.Error
-	}
+// GetAllUsers returns a page of users, newest first
+func (a *AuthRepository) GetAllUsers(params pagination.Params) (pagination.Page[models.User], error) {
+	return pagination.Find(a.db.Model(&models.User{}), params, userCursor)
+}
 
-	return users, nil
+func userCursor(user models.User) pagination.Cursor {
+	return pagination.Cursor{Time: user.CreatedAt, ID: user.ID}
 }
 
 // GetUserByID returns a user by ID
@@ -39,9 +47,22 @@ func (a *AuthRepository) GetUserByID(userId string) (models.User, error) {
 	return user, nil
 }
 
+// GetUserByFacebookID returns a user by their Facebook ID
+func (a *AuthRepository) GetUserByFacebookID(facebookId string) (models.User, error) {
+	var user models.User
+
+	result := a.db.Where("facebook_id = ?", facebookId).Find(&user)
+	if result.Error != nil {
+		return user, result.Error
+	}
+
+	return user, nil
+}
+
 // CreateUser creates a new user
 func (a *AuthRepository) CreateUser(userDto models.FacebookUser) (models.User, error) {
 	var newUser = models.User{
+		ID:         uuid.New().String(),
 		FacebookID: userDto.ID,
 		Name:       userDto.Name,
 		Picture:    userDto.Picture.Data.Url,
@@ -70,3 +91,18 @@ func (a *AuthRepository) UpdateUserStatus(userId string, isActive bool) (models.
 	}
 	return a.GetUserByID(userId)
 }
+
+// SaveFacebookToken stores the user's Facebook token, replacing the one stored before
+func (a *AuthRepository) SaveFacebookToken(token FacebookToken) error {
+	return a.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"ciphertext", "expires_at", "updated_at"}),
+	}).Create(&token).Error
+}
+
+// GetFacebookToken returns the user's stored Facebook token, or gorm.ErrRecordNotFound
+func (a *AuthRepository) GetFacebookToken(userId string) (FacebookToken, error) {
+	var token FacebookToken
+	result := a.db.Where("user_id = ?", userId).First(&token)
+	return token, result.Error
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+// newTestRepository returns a repository on a new in-memory SQLite database with every migration applied
+func newTestRepository(t *testing.T) *AuthRepository {
+	t.Helper()
+
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrations, err := Migrations(db.Dialect(database))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate.New(database, MigrationsName, migrations).Up(); err != nil {
+		t.Fatal(err)
+	}
+	auditMigrations, err := audit.Migrations(db.Dialect(database))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate.New(database, audit.MigrationsName, auditMigrations).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewAuthRepository(database)
+}
+
+func createTestUser(t *testing.T, repo *AuthRepository, facebookID string) models.User {
+	t.Helper()
+	user, err := repo.CreateUser(models.FacebookUser{ID: facebookID, Name: "User " + facebookID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestCreateUser(t *testing.T) {
+	repo := newTestRepository(t)
+
+	user := createTestUser(t, repo, "1001")
+	if user.ID == "" || user.ID == user.FacebookID {
+		t.Errorf("user ID = %q, want a generated ID", user.ID)
+	}
+	if user.Role != models.RoleUser || !user.IsActive {
+		t.Errorf("new user has role %q and active %v, want an active user", user.Role, user.IsActive)
+	}
+
+	found, err := repo.GetUserByFacebookID("1001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if found.ID != user.ID {
+		t.Errorf("GetUserByFacebookID returned %q, want %q", found.ID, user.ID)
+	}
+
+	if _, err := repo.CreateUser(models.FacebookUser{ID: "1001"}); err == nil {
+		t.Error("a second user with the same Facebook ID was created")
+	}
+}
+
+func TestGetMissingUser(t *testing.T) {
+	repo := newTestRepository(t)
+
+	// Callers tell a missing user by its empty ID
+	user, err := repo.GetUserByFacebookID("missing")
+	if err != nil || user.ID != "" {
+		t.Errorf("GetUserByFacebookID = %+v, %v, want an empty user", user, err)
+	}
+	user, err = repo.GetUserByID("missing")
+	if err != nil || user.ID != "" {
+		t.Errorf("GetUserByID = %+v, %v, want an empty user", user, err)
+	}
+}
+
+func TestGetAllUsers(t *testing.T) {
+	repo := newTestRepository(t)
+	for _, facebookID := range []string{"1", "2", "3"} {
+		createTestUser(t, repo, facebookID)
+	}
+
+	seen := map[string]bool{}
+	params := pagination.Params{Limit: 2, WithTotal: true}
+	for pages := 0; pages < 3; pages++ {
+		page, err := repo.GetAllUsers(params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total == nil || *page.Total != 3 {
+			t.Errorf("total = %v, want 3", page.Total)
+		}
+		for _, user := range page.Items {
+			if seen[user.ID] {
+				t.Errorf("user %s on two pages", user.ID)
+			}
+			seen[user.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor, err := pagination.DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params.After = &cursor
+	}
+
+	if len(seen) != 3 {
+		t.Errorf("paged through %d users, want 3", len(seen))
+	}
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	repo := newTestRepository(t)
+	user := createTestUser(t, repo, "1001")
+
+	failure := errors.New("failure")
+	err := repo.Transaction(func(repo *AuthRepository) error {
+		if _, err := repo.UpdateUserRole(user.ID, models.RoleAdmin); err != nil {
+			return err
+		}
+		return failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Transaction returned %v", err)
+	}
+
+	user, err = repo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleUser {
+		t.Errorf("role = %q after the transaction rolled back", user.Role)
+	}
+}
+
+func TestSaveFacebookToken(t *testing.T) {
+	repo := newTestRepository(t)
+
+	if _, err := repo.GetFacebookToken("user"); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("GetFacebookToken of a user without a token returned %v", err)
+	}
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	if err := repo.SaveFacebookToken(FacebookToken{UserID: "user", Ciphertext: "first"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.SaveFacebookToken(FacebookToken{UserID: "user", Ciphertext: "second", ExpiresAt: expiresAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := repo.GetFacebookToken("user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Ciphertext != "second" || !token.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("token = %+v, want the second one", token)
+	}
+}
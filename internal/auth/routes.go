@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/jwks"
+	customMiddleware "github.com/mwelwankuta/facebook-notes/pkg/middleware"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+)
+
+// RegisterRoutes registers the auth service's routes. authenticate verifies the access token of the
+// protected routes, policy gates the admin routes.
+func RegisterRoutes(e *echo.Echo, authHandler *AuthHandler, auditHandler *audit.Handler, jwksHandler *jwks.Handler, authenticate echo.MiddlewareFunc, policy *permissions.Policy) {
+	// Public routes
+	e.POST("/api/auth/login/callback", authHandler.AuthenticateUserHandler)
+	e.GET("/api/auth/login", authHandler.LoginWithFacebook)
+	e.POST("/api/auth/refresh", authHandler.RefreshTokenHandler)
+	e.GET("/.well-known/jwks.json", jwksHandler.JWKSHandler)
+
+	// Protected routes
+	api := e.Group("/api")
+	api.Use(authenticate)
+
+	api.POST("/auth/logout", authHandler.LogoutHandler)
+
+	// User routes
+	api.GET("/auth/users/me", authHandler.GetCurrentUser)
+	api.GET("/auth/users", authHandler.GetAllUsersHandler)
+	api.GET("/auth/users/:id", authHandler.GetUserByIDHandler)
+
+	// User management routes
+	admin := api.Group("/admin")
+	admin.PUT("/users/:id/role", authHandler.UpdateUserRole, customMiddleware.RequirePermission(policy, permissions.UserSetRole))
+	admin.PUT("/users/:id/status", authHandler.UpdateUserStatus, customMiddleware.RequirePermission(policy, permissions.UserSetStatus))
+
+	// Audit routes
+	auditRoutes := api.Group("/admin/audit")
+	auditRoutes.Use(customMiddleware.RequirePermission(policy, permissions.AuditRead))
+	auditRoutes.GET("", auditHandler.QueryHandler)
+	auditRoutes.GET("/verify", auditHandler.VerifyHandler)
+}
@@ -0,0 +1,158 @@
+package auth
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/jwks"
+	customMiddleware "github.com/mwelwankuta/facebook-notes/pkg/middleware"
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+func newTestKeySet(t *testing.T, kid string) *jwks.KeySet {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := jwks.NewKeySet([]jwks.SigningKey{{Kid: kid, PrivateKey: privateKey}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keySet
+}
+
+// newTestServer returns the auth service's routes on the test use case, verifying tokens the use case signs
+func newTestServer(t *testing.T) (*echo.Echo, *AuthUseCase, *AuthRepository) {
+	t.Helper()
+	uc, repo := newTestUseCase(t)
+	keySet := uc.keys.(*jwks.KeySet)
+
+	e := echo.New()
+	authenticate := customMiddleware.Authenticate(customMiddleware.AuthConfig{Keys: keySet.Keyfunc, Tokens: uc.tokens})
+	RegisterRoutes(e, NewAuthHandler(*uc, ""), audit.NewHandler(uc.audit), jwks.NewHandler(keySet), authenticate, uc.policy)
+	return e, uc, repo
+}
+
+// mint signs an access token for the user with the signer, valid until expiresAt
+func mint(t *testing.T, signer utils.TokenSigner, user models.User, jti string, expiresAt time.Time) string {
+	t.Helper()
+	token, err := utils.GenerateJwtToken(signer, user, jti, 0, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func serve(e *echo.Echo, method string, path string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoutes(t *testing.T) {
+	e, uc, repo := newTestServer(t)
+	admin, err := repo.UpdateUserRole(createTestUser(t, repo, "admin").ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, repo, "user")
+
+	valid := time.Now().Add(time.Minute)
+	userToken := mint(t, uc.keys, user, "user-jti", valid)
+	adminToken := mint(t, uc.keys, admin, "admin-jti", valid)
+	revokedToken := mint(t, uc.keys, user, "revoked-jti", valid)
+	if err := uc.tokens.RevokeAccessToken(context.Background(), "revoked-jti", valid); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"public jwks", http.MethodGet, "/.well-known/jwks.json", "", "", http.StatusOK},
+		{"public refresh", http.MethodPost, "/api/auth/refresh", "", `{"refresh_token":"unknown"}`, http.StatusUnauthorized},
+		{"protected without a token", http.MethodGet, "/api/auth/users/me", "", "", http.StatusUnauthorized},
+		{"protected with a valid token", http.MethodGet, "/api/auth/users/me", userToken, "", http.StatusOK},
+		{"protected with an expired token", http.MethodGet, "/api/auth/users/me", mint(t, uc.keys, user, "expired-jti", time.Now().Add(-time.Minute)), "", http.StatusUnauthorized},
+		{"protected with a revoked token", http.MethodGet, "/api/auth/users/me", revokedToken, "", http.StatusUnauthorized},
+		{"protected with an unknown kid", http.MethodGet, "/api/auth/users/me", mint(t, newTestKeySet(t, "other"), user, "user-jti", valid), "", http.StatusUnauthorized},
+		{"role change as a user", http.MethodPut, "/api/admin/users/" + admin.ID + "/role", userToken, `{"role":"user"}`, http.StatusForbidden},
+		{"audit log as a user", http.MethodGet, "/api/admin/audit", userToken, "", http.StatusForbidden},
+		{"audit log as an admin", http.MethodGet, "/api/admin/audit", adminToken, "", http.StatusOK},
+		{"status change without is_active", http.MethodPut, "/api/admin/users/" + user.ID + "/status", adminToken, `{}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := serve(e, test.method, test.path, test.token, test.body)
+			if rec.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+		})
+	}
+}
+
+func TestDeactivationRevokesAccessTokens(t *testing.T) {
+	e, uc, repo := newTestServer(t)
+	admin, err := repo.UpdateUserRole(createTestUser(t, repo, "admin").ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, repo, "user")
+
+	valid := time.Now().Add(time.Minute)
+	userToken := mint(t, uc.keys, user, "user-jti", valid)
+	adminToken := mint(t, uc.keys, admin, "admin-jti", valid)
+
+	if rec := serve(e, http.MethodGet, "/api/auth/users/me", userToken, ""); rec.Code != http.StatusOK {
+		t.Fatalf("status before the deactivation = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(e, http.MethodPut, "/api/admin/users/"+user.ID+"/status", adminToken, `{"is_active":false}`); rec.Code != http.StatusOK {
+		t.Fatalf("deactivation status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec := serve(e, http.MethodGet, "/api/auth/users/me", userToken, ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status after the deactivation = %d, want 401: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestRoleChangeRevokesAccessTokens(t *testing.T) {
+	e, uc, repo := newTestServer(t)
+	admin, err := repo.UpdateUserRole(createTestUser(t, repo, "admin").ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	moderator, err := repo.UpdateUserRole(createTestUser(t, repo, "moderator").ID, models.RoleModerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	valid := time.Now().Add(time.Minute)
+	moderatorToken := mint(t, uc.keys, moderator, "moderator-jti", valid)
+	adminToken := mint(t, uc.keys, admin, "admin-jti", valid)
+
+	if rec := serve(e, http.MethodPut, "/api/admin/users/"+moderator.ID+"/role", adminToken, `{"role":"user"}`); rec.Code != http.StatusOK {
+		t.Fatalf("role change status = %d: %s", rec.Code, rec.Body)
+	}
+	// The token still says moderator, so it must not be accepted any more
+	if rec := serve(e, http.MethodPut, "/api/admin/users/"+admin.ID+"/status", moderatorToken, `{"is_active":false}`); rec.Code != http.StatusUnauthorized {
+		t.Errorf("status with the demoted moderator's token = %d, want 401: %s", rec.Code, rec.Body)
+	}
+}
@@ -2,26 +2,47 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
 	"github.com/mwelwankuta/facebook-notes/pkg/config"
 	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/tokens"
 	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+	"github.com/mwelwankuta/facebook-notes/pkg/vault"
+)
+
+var (
+	ErrUserInactive = errors.New("user account is inactive")
+	ErrInvalidRole  = errors.New("invalid role")
 )
 
 type AuthUseCase struct {
 	config config.Config
 	repo   AuthRepository
 	redis  *adapters.RedisClient
+	audit  *audit.Log
+	tokens *tokens.Store
+	keys   utils.TokenSigner
+	vault  *vault.Cipher
+	policy *permissions.Policy
 }
 
-func NewAuthUseCase(repo AuthRepository, cfg config.Config, redis *adapters.RedisClient) *AuthUseCase {
+func NewAuthUseCase(repo AuthRepository, cfg config.Config, redis *adapters.RedisClient, auditLog *audit.Log, keys utils.TokenSigner, tokenVault *vault.Cipher, policy *permissions.Policy) *AuthUseCase {
 	return &AuthUseCase{
 		repo:   repo,
 		config: cfg,
 		redis:  redis,
+		audit:  auditLog,
+		tokens: tokens.NewStore(redis),
+		keys:   keys,
+		vault:  tokenVault,
+		policy: policy,
 	}
 }
 
@@ -29,12 +50,18 @@ func (a *AuthUseCase) CreateUser(userDto models.FacebookUser) (models.User, erro
 	return a.repo.CreateUser(userDto)
 }
 
-// AuthenticateUser authenticates a user using the facebook code
-func (a *AuthUseCase) AuthenticateUser(code string) (AuthenticateUserResponse, error) {
+// AuthenticateUser authenticates a user using the facebook code and the state of the login it completes.
+// stateCookie is the LoginStateCookie set when the login was started.
+func (a *AuthUseCase) AuthenticateUser(code string, state string, stateCookie string) (AuthenticateUserResponse, error) {
 	var user models.User
 
+	login, err := a.consumeLoginState(context.Background(), state, stateCookie)
+	if err != nil {
+		return AuthenticateUserResponse{User: user, Token: ""}, err
+	}
+
 	// get facebook access token
-	accessToken, err := adapters.GetFacebookUserAccessToken(code, a.config.OpenGraphClientID, a.config.OpenGraphClientSecret)
+	accessToken, err := adapters.GetFacebookUserAccessToken(a.config.FacebookTokenURL, code, a.config.OpenGraphClientID, a.config.OpenGraphClientSecret, login.RedirectURI, login.CodeVerifier)
 	if err != nil {
 		return AuthenticateUserResponse{
 			User:  user,
@@ -43,13 +70,13 @@ func (a *AuthUseCase) AuthenticateUser(code string) (AuthenticateUserResponse, e
 	}
 
 	// get facebook user profile
-	userDto, err := adapters.FetchUserProfile(accessToken)
+	userDto, err := adapters.FetchUserProfile(accessToken.AccessToken)
 	if err != nil {
 		return AuthenticateUserResponse{User: user, Token: ""}, err
 	}
 
 	// get user from database
-	user, err = a.repo.GetUserByID(userDto.ID)
+	user, err = a.repo.GetUserByFacebookID(userDto.ID)
 	if err != nil {
 		return AuthenticateUserResponse{User: user, Token: ""}, err
 	}
@@ -62,16 +89,86 @@ func (a *AuthUseCase) AuthenticateUser(code string) (AuthenticateUserResponse, e
 		}
 	}
 
-	jwtToken, err := utils.GenerateJwtToken(a.config.OpenGraphClientSecret, user, accessToken)
-	if err != nil {
+	if !user.IsActive {
+		return AuthenticateUserResponse{User: user, Token: ""}, ErrUserInactive
+	}
+
+	// The Facebook token stays server-side, it is never put into our own tokens
+	if err := a.storeFacebookToken(user.ID, accessToken); err != nil {
 		return AuthenticateUserResponse{User: user, Token: ""}, err
 	}
 
-	return AuthenticateUserResponse{User: user, Token: jwtToken}, nil
+	return a.issueTokens(context.Background(), user)
+}
+
+// issueTokens creates a short lived access token and a refresh token for the user
+func (a *AuthUseCase) issueTokens(ctx context.Context, user models.User) (AuthenticateUserResponse, error) {
+	generation, err := a.tokens.Generation(ctx, user.ID)
+	if err != nil {
+		return AuthenticateUserResponse{User: user}, err
+	}
+
+	tokenID, err := tokens.NewTokenID()
+	if err != nil {
+		return AuthenticateUserResponse{User: user}, err
+	}
+
+	jwtToken, err := utils.GenerateJwtToken(a.keys, user, tokenID, generation, time.Now().Add(tokens.AccessTokenTTL))
+	if err != nil {
+		return AuthenticateUserResponse{User: user}, err
+	}
+
+	refreshToken, err := a.tokens.IssueRefreshToken(ctx, user.ID)
+	if err != nil {
+		return AuthenticateUserResponse{User: user}, err
+	}
+
+	return AuthenticateUserResponse{
+		User:         user,
+		Token:        jwtToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int(tokens.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// RefreshTokens exchanges a refresh token for a new access token and refresh token. The user is read
+// from the database so role changes and deactivation take effect on the next refresh.
+func (a *AuthUseCase) RefreshTokens(ctx context.Context, refreshToken string) (AuthenticateUserResponse, error) {
+	session, err := a.tokens.UseRefreshToken(ctx, refreshToken)
+	if err != nil {
+		return AuthenticateUserResponse{}, err
+	}
+
+	user, err := a.repo.GetUserByID(session.UserID)
+	if err != nil {
+		return AuthenticateUserResponse{}, err
+	}
+	if user.ID == "" {
+		return AuthenticateUserResponse{}, tokens.ErrInvalidRefreshToken
+	}
+	if !user.IsActive {
+		return AuthenticateUserResponse{}, ErrUserInactive
+	}
+
+	return a.issueTokens(ctx, user)
 }
 
-func (a *AuthUseCase) GetAllUsers(dto models.PaginateDto) ([]models.User, error) {
-	return a.repo.GetAllUsers(dto)
+// Logout revokes the access token the request was made with and, when given, the refresh token. The
+// refresh token must belong to the principal.
+func (a *AuthUseCase) Logout(ctx context.Context, principal utils.Principal, refreshToken string) error {
+	if err := a.tokens.RevokeAccessToken(ctx, principal.TokenID, principal.ExpiresAt); err != nil {
+		return err
+	}
+
+	if refreshToken != "" {
+		return a.tokens.RevokeRefreshToken(ctx, refreshToken, principal.UserID)
+	}
+
+	return nil
+}
+
+func (a *AuthUseCase) GetAllUsers(params pagination.Params) (pagination.Page[models.User], error) {
+	return a.repo.GetAllUsers(params)
 }
 
 func (a *AuthUseCase) GetUserByID(userId string) (models.User, error) {
@@ -96,22 +193,37 @@ func (a *AuthUseCase) GetUserByID(userId string) (models.User, error) {
 	return user, nil
 }
 
-// UpdateUserRole updates a user's role
-func (a *AuthUseCase) UpdateUserRole(userId string, role string) (models.User, error) {
-	// Validate role
-	validRoles := []string{models.RoleUser, models.RoleModerator, models.RoleAdmin}
-	isValidRole := false
-	for _, r := range validRoles {
-		if r == role {
-			isValidRole = true
-			break
-		}
+// UpdateUserRole updates a user's role and records the change in the audit log. The user's tokens are
+// revoked, so the new role applies from their next refresh instead of when their access token expires.
+func (a *AuthUseCase) UpdateUserRole(actor utils.Principal, userId string, role string) (models.User, error) {
+	if err := a.policy.Authorize(actor, permissions.UserSetRole, permissions.Resource{Type: permissions.ResourceUser, ID: userId}); err != nil {
+		return models.User{}, err
+	}
+
+	// Roles are whatever the permission policy defines
+	if !a.policy.HasRole(role) {
+		return models.User{}, ErrInvalidRole
 	}
-	if !isValidRole {
-		return models.User{}, fmt.Errorf("invalid role: %s", role)
+
+	previous, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.User{}, err
 	}
 
-	user, err := a.repo.UpdateUserRole(userId, role)
+	var user models.User
+	err = a.repo.Transaction(func(repo *AuthRepository) error {
+		var err error
+		user, err = repo.UpdateUserRole(userId, role)
+		if err != nil {
+			return err
+		}
+
+		_, err = a.audit.Append(repo.db, actor.UserID, audit.ActionUserRoleChanged, audit.TargetUser, userId, map[string]string{
+			"from": previous.Role,
+			"to":   role,
+		})
+		return err
+	})
 	if err != nil {
 		return models.User{}, err
 	}
@@ -121,36 +233,56 @@ func (a *AuthUseCase) UpdateUserRole(userId string, role string) (models.User, e
 	cacheKey := fmt.Sprintf("user:%s", userId)
 	a.redis.Delete(ctx, cacheKey)
 
+	if previous.Role != role {
+		if err := a.tokens.RevokeUser(ctx, userId); err != nil {
+			return models.User{}, err
+		}
+	}
+
 	return user, nil
 }
 
-// UpdateUserStatus updates a user's active status
-func (a *AuthUseCase) UpdateUserStatus(userId string, isActive bool) (models.User, error) {
-	return a.repo.UpdateUserStatus(userId, isActive)
-}
+// UpdateUserStatus updates a user's active status and records the change in the audit log
+func (a *AuthUseCase) UpdateUserStatus(actor utils.Principal, userId string, isActive bool) (models.User, error) {
+	if err := a.policy.Authorize(actor, permissions.UserSetStatus, permissions.Resource{Type: permissions.ResourceUser, ID: userId}); err != nil {
+		return models.User{}, err
+	}
 
-// GetUserByFacebookID returns a user by their Facebook ID
-func (a *AuthUseCase) GetUserByFacebookID(facebookId string) (models.User, error) {
 	var user models.User
-	result := a.repo.db.Where("facebook_id = ?", facebookId).First(&user)
-	if result.Error != nil {
-		return models.User{}, result.Error
-	}
-	return user, nil
-}
+	err := a.repo.Transaction(func(repo *AuthRepository) error {
+		var err error
+		user, err = repo.UpdateUserStatus(userId, isActive)
+		if err != nil {
+			return err
+		}
 
-// ValidateUserRole checks if a user has the required role
-func (a *AuthUseCase) ValidateUserRole(userId string, requiredRole string) (bool, error) {
-	user, err := a.GetUserByID(userId)
+		_, err = a.audit.Append(repo.db, actor.UserID, audit.ActionUserStatusChanged, audit.TargetUser, userId, map[string]bool{
+			"is_active": isActive,
+		})
+		return err
+	})
 	if err != nil {
-		return false, err
+		return models.User{}, err
 	}
 
-	if user.Role == models.RoleAdmin {
-		return true, nil
+	// Invalidate cache
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("user:%s", userId)
+	a.redis.Delete(ctx, cacheKey)
+
+	// A deactivated user loses every session they have open
+	if !isActive {
+		if err := a.tokens.RevokeUser(ctx, userId); err != nil {
+			return models.User{}, err
+		}
 	}
 
-	return user.Role == requiredRole, nil
+	return user, nil
+}
+
+// GetUserByFacebookID returns a user by their Facebook ID
+func (a *AuthUseCase) GetUserByFacebookID(facebookId string) (models.User, error) {
+	return a.repo.GetUserByFacebookID(facebookId)
 }
 
 // GetCurrentUserProfile gets the current user's full profile
@@ -161,20 +293,20 @@ func (a *AuthUseCase) GetCurrentUserProfile(userId string) (models.User, error)
 	}
 
 	if !user.IsActive {
-		return models.User{}, fmt.Errorf("user account is inactive")
+		return models.User{}, ErrUserInactive
 	}
 
 	return user, nil
 }
 
 // DeactivateUser deactivates a user account
-func (a *AuthUseCase) DeactivateUser(userId string) error {
-	_, err := a.UpdateUserStatus(userId, false)
+func (a *AuthUseCase) DeactivateUser(actor utils.Principal, userId string) error {
+	_, err := a.UpdateUserStatus(actor, userId, false)
 	return err
 }
 
 // ReactivateUser reactivates a user account
-func (a *AuthUseCase) ReactivateUser(userId string) error {
-	_, err := a.UpdateUserStatus(userId, true)
+func (a *AuthUseCase) ReactivateUser(actor utils.Principal, userId string) error {
+	_, err := a.UpdateUserStatus(actor, userId, true)
 	return err
 }
@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/tokens"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+	"github.com/mwelwankuta/facebook-notes/pkg/vault"
+)
+
+// newTestUseCase returns a use case on a new in-memory database and an in-memory redis
+func newTestUseCase(t *testing.T) (*AuthUseCase, *AuthRepository) {
+	t.Helper()
+	return newTestUseCaseWithConfig(t, config.Config{})
+}
+
+func newTestUseCaseWithConfig(t *testing.T, cfg config.Config) (*AuthUseCase, *AuthRepository) {
+	t.Helper()
+
+	repo := newTestRepository(t)
+	policy, err := permissions.NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenVault, err := vault.NewCipher(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", vault.KeySize))))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redis := adapters.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	return NewAuthUseCase(*repo, cfg, redis, audit.NewLog(repo.db), newTestKeySet(t, "test"), tokenVault, policy), repo
+}
+
+func principal(user models.User) utils.Principal {
+	return utils.Principal{UserID: user.ID, Role: user.Role}
+}
+
+func TestUpdateUserRole(t *testing.T) {
+	uc, repo := newTestUseCase(t)
+	admin := createTestUser(t, repo, "admin")
+	admin, err := repo.UpdateUserRole(admin.ID, models.RoleAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, repo, "user")
+	ctx := context.Background()
+
+	updated, err := uc.UpdateUserRole(principal(admin), user.ID, models.RoleModerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.Role != models.RoleModerator {
+		t.Errorf("role = %q, want moderator", updated.Role)
+	}
+	// Tokens issued with the old role are revoked
+	if revoked, err := uc.tokens.IsRevoked(ctx, user.ID, "", 0); err != nil || !revoked {
+		t.Errorf("a token issued before the role change is revoked: %v, %v", revoked, err)
+	}
+
+	page, err := uc.audit.Query(audit.Filter{TargetID: user.ID}, pagination.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := page.Items
+	if len(entries) != 1 || entries[0].Action != audit.ActionUserRoleChanged || entries[0].ActorID != admin.ID {
+		t.Fatalf("audit entries = %+v, want the role change", entries)
+	}
+	if !strings.Contains(entries[0].Details, `"from":"user"`) || !strings.Contains(entries[0].Details, `"to":"moderator"`) {
+		t.Errorf("audit details = %s", entries[0].Details)
+	}
+	if report, err := uc.audit.Verify(); err != nil || !report.OK() {
+		t.Errorf("audit chain: %+v, %v", report, err)
+	}
+
+	if _, err := uc.UpdateUserRole(principal(admin), user.ID, "owner"); !errors.Is(err, ErrInvalidRole) {
+		t.Errorf("an unknown role returned %v, want ErrInvalidRole", err)
+	}
+	if _, err := uc.UpdateUserRole(principal(admin), admin.ID, models.RoleUser); !errors.Is(err, permissions.ErrForbidden) {
+		t.Errorf("changing one's own role returned %v, want ErrForbidden", err)
+	}
+	if _, err := uc.UpdateUserRole(principal(updated), admin.ID, models.RoleUser); !errors.Is(err, permissions.ErrForbidden) {
+		t.Errorf("a moderator changing a role returned %v, want ErrForbidden", err)
+	}
+
+	// Refused changes leave the user and the audit log alone
+	user, err = repo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleModerator {
+		t.Errorf("role = %q after refused changes", user.Role)
+	}
+	if page, _ := uc.audit.Query(audit.Filter{}, pagination.Params{}); len(page.Items) != 1 {
+		t.Errorf("%d audit entries after refused changes, want 1", len(page.Items))
+	}
+}
+
+func TestDeactivateUser(t *testing.T) {
+	uc, repo := newTestUseCase(t)
+	moderator := createTestUser(t, repo, "moderator")
+	moderator, err := repo.UpdateUserRole(moderator.ID, models.RoleModerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, repo, "user")
+	ctx := context.Background()
+
+	refreshToken, err := uc.tokens.IssueRefreshToken(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := uc.DeactivateUser(principal(moderator), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := uc.tokens.IsRevoked(ctx, user.ID, "", 0); err != nil || !revoked {
+		t.Errorf("a token issued before the deactivation is revoked: %v, %v", revoked, err)
+	}
+	if _, err := uc.RefreshTokens(ctx, refreshToken); !errors.Is(err, tokens.ErrInvalidRefreshToken) {
+		t.Errorf("refreshing after the deactivation returned %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := uc.GetCurrentUserProfile(user.ID); !errors.Is(err, ErrUserInactive) {
+		t.Errorf("profile of a deactivated user returned %v, want ErrUserInactive", err)
+	}
+}
+
+func TestReactivateUser(t *testing.T) {
+	uc, repo := newTestUseCase(t)
+	moderator := createTestUser(t, repo, "moderator")
+	moderator, err := repo.UpdateUserRole(moderator.ID, models.RoleModerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := createTestUser(t, repo, "user")
+	if _, err := repo.UpdateUserStatus(user.ID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := uc.GetCurrentUserProfile(user.ID); !errors.Is(err, ErrUserInactive) {
+		t.Errorf("profile of an inactive user returned %v, want ErrUserInactive", err)
+	}
+
+	if err := uc.ReactivateUser(principal(moderator), user.ID); err != nil {
+		t.Fatal(err)
+	}
+	profile, err := uc.GetCurrentUserProfile(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !profile.IsActive {
+		t.Error("user is still inactive")
+	}
+
+	page, err := uc.audit.Query(audit.Filter{Action: audit.ActionUserStatusChanged}, pagination.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := page.Items
+	if len(entries) != 1 || entries[0].TargetID != user.ID || entries[0].Details != `{"is_active":true}` {
+		t.Errorf("audit entries = %+v, want the reactivation", entries)
+	}
+
+	if err := uc.ReactivateUser(principal(profile), moderator.ID); !errors.Is(err, permissions.ErrForbidden) {
+		t.Errorf("a user changing a status returned %v, want ErrForbidden", err)
+	}
+}
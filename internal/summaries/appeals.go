@@ -0,0 +1,161 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+var (
+	ErrAppealNotFound   = errors.New("appeal not found")
+	ErrAlreadyAppealed  = errors.New("this decision has already been appealed")
+	ErrAppealNotAllowed = errors.New("only a rejected summary can be appealed, and an upheld appeal is final")
+	ErrAppealNotPending = errors.New("appeal has already been resolved")
+	ErrAppealPending    = errors.New("summary is under appeal and must be decided through the appeal")
+	ErrNotAuthor        = errors.New("only the author of the summary request can appeal")
+	ErrReviewerConflict = errors.New("an appeal must be reviewed by a moderator who did not vote on the summary")
+)
+
+// FileAppeal contests the rejection of a summary and reopens it for review. Only the user who requested
+// the summary can appeal, and each rejection can be appealed once.
+func (uc *SummariesUseCase) FileAppeal(summaryID string, dto FileAppealDto, user utils.Principal) (SummaryAppeal, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryAppeal, permissions.Resource{Type: permissions.ResourceSummary, ID: summaryID}); err != nil {
+		return SummaryAppeal{}, err
+	}
+
+	summary, err := uc.repo.GetSummaryByID(summaryID)
+	if err != nil {
+		return SummaryAppeal{}, err
+	}
+
+	if summary.UserID != user.UserID {
+		return SummaryAppeal{}, ErrNotAuthor
+	}
+	if summary.Status != StatusRejected {
+		return SummaryAppeal{}, ErrAppealNotAllowed
+	}
+
+	decision, err := uc.repo.GetLatestTransitionTo(summaryID, StatusRejected)
+	switch {
+	case errors.Is(err, ErrTransitionNotFound):
+		// Summaries rejected before transitions were recorded have no decision row. Their rejection is
+		// the summary's only one, so the summary ID stands in as the decision and it is appealed once.
+		decision = SummaryTransition{ID: summaryID, ToStatus: StatusRejected}
+	case err != nil:
+		return SummaryAppeal{}, err
+	}
+	// A rejection that came out of an appeal is the upheld appeal, it cannot be appealed again
+	if decision.FromStatus == StatusAppealed {
+		return SummaryAppeal{}, ErrAppealNotAllowed
+	}
+
+	originalModerator := decision.ActorID
+	if summary.ModeratorID != nil {
+		originalModerator = *summary.ModeratorID
+	}
+
+	var appeal SummaryAppeal
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		var err error
+		appeal, err = repo.CreateAppeal(SummaryAppeal{
+			SummaryID:           summaryID,
+			DecisionID:          decision.ID,
+			AppellantID:         user.UserID,
+			Justification:       dto.Justification,
+			OriginalModeratorID: originalModerator,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = uc.audit.Append(repo.db, user.UserID, audit.ActionAppealFiled, audit.TargetAppeal, appeal.ID, map[string]string{
+			"summary_id":  summaryID,
+			"decision_id": decision.ID,
+		})
+		return err
+	})
+	if err != nil {
+		return SummaryAppeal{}, err
+	}
+
+	uc.redis.Delete(context.Background(), fmt.Sprintf("summary:%s", summaryID))
+	uc.indexSummary(context.Background(), summaryID)
+
+	return appeal, nil
+}
+
+// ResolveAppeal upholds or overturns the rejection under appeal. The reviewer must be a different
+// moderator from the one who rejected the summary and from everyone who voted on it.
+func (uc *SummariesUseCase) ResolveAppeal(appealID string, dto ResolveAppealDto, user utils.Principal) (SummaryAppeal, error) {
+	appeal, err := uc.repo.GetAppealByID(appealID)
+	if err != nil {
+		return SummaryAppeal{}, err
+	}
+
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{Type: permissions.ResourceSummary, ID: appeal.SummaryID}); err != nil {
+		return SummaryAppeal{}, err
+	}
+
+	if user.UserID == appeal.OriginalModeratorID || user.UserID == appeal.AppellantID {
+		return SummaryAppeal{}, ErrReviewerConflict
+	}
+	voted, err := uc.repo.HasVoted(appeal.SummaryID, user.UserID)
+	if err != nil {
+		return SummaryAppeal{}, err
+	}
+	if voted {
+		return SummaryAppeal{}, ErrReviewerConflict
+	}
+	if appeal.Status != AppealPending {
+		return SummaryAppeal{}, ErrAppealNotPending
+	}
+
+	outcome := AppealUpheld
+	if dto.Outcome == "overturn" {
+		outcome = AppealOverturned
+	}
+
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		var err error
+		appeal, err = repo.ResolveAppeal(appealID, outcome, user.UserID, dto.Notes)
+		if err != nil {
+			return err
+		}
+
+		_, err = uc.audit.Append(repo.db, user.UserID, audit.ActionAppealResolved, audit.TargetAppeal, appeal.ID, map[string]string{
+			"summary_id": appeal.SummaryID,
+			"outcome":    outcome,
+			"notes":      dto.Notes,
+		})
+		return err
+	})
+	if err != nil {
+		return SummaryAppeal{}, err
+	}
+
+	uc.redis.Delete(context.Background(), fmt.Sprintf("summary:%s", appeal.SummaryID))
+	uc.indexSummary(context.Background(), appeal.SummaryID)
+
+	return appeal, nil
+}
+
+// GetSummaryAppeals returns the appeals filed against a summary
+func (uc *SummariesUseCase) GetSummaryAppeals(summaryID string) ([]SummaryAppeal, error) {
+	if _, err := uc.repo.GetSummaryByID(summaryID); err != nil {
+		return nil, err
+	}
+	return uc.repo.GetAppealsBySummaryID(summaryID)
+}
+
+// GetPendingAppeals lists the appeals waiting for a reviewer
+func (uc *SummariesUseCase) GetPendingAppeals(user utils.Principal, params pagination.Params) (pagination.Page[SummaryAppeal], error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{}); err != nil {
+		return pagination.Page[SummaryAppeal]{}, err
+	}
+	return uc.repo.GetPendingAppeals(params)
+}
@@ -1,9 +1,20 @@
 package summaries
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/facebook"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/search"
 	"github.com/mwelwankuta/facebook-notes/pkg/utils"
 )
 
@@ -20,7 +31,7 @@ func NewSummariesHandler(useCase SummariesUseCase, clientId string) *SummariesHa
 }
 
 func (h *SummariesHandler) CreateSummaryRequestHandler(c echo.Context) error {
-	user, err := utils.GetUserFromContext(c)
+	user, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
 	}
@@ -36,9 +47,17 @@ func (h *SummariesHandler) CreateSummaryRequestHandler(c echo.Context) error {
 
 	request, err := h.useCase.CreateSummaryRequest(dto, user)
 	if err != nil {
-		switch err {
-		case ErrUnauthorized:
+		switch {
+		case errors.Is(err, permissions.ErrUnauthenticated):
 			return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrInvalidPostURL), errors.Is(err, ErrEmptyPost):
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrPostNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrPostUnavailable):
+			return c.JSON(http.StatusBadGateway, utils.ErrorResponse{Error: err.Error()})
 		default:
 			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 		}
@@ -48,8 +67,12 @@ func (h *SummariesHandler) CreateSummaryRequestHandler(c echo.Context) error {
 }
 
 func (h *SummariesHandler) GetAllSummariesHandler(c echo.Context) error {
-	dto := utils.GetPaginationFromQuery(c)
-	summaries, err := h.useCase.GetAllSummaries(dto)
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	summaries, err := h.useCase.GetAllSummaries(params)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 	}
@@ -58,8 +81,12 @@ func (h *SummariesHandler) GetAllSummariesHandler(c echo.Context) error {
 }
 
 func (h *SummariesHandler) GetAllRequestsHandler(c echo.Context) error {
-	dto := utils.GetPaginationFromQuery(c)
-	requests, err := h.useCase.GetAllRequests(dto)
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	requests, err := h.useCase.GetAllRequests(params)
 	if err != nil {
 		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 	}
@@ -67,6 +94,27 @@ func (h *SummariesHandler) GetAllRequestsHandler(c echo.Context) error {
 	return c.JSON(http.StatusOK, requests)
 }
 
+// GetSummariesByPostHandler returns the approved summaries of the Facebook post given in the url query param,
+// matched as GetSummariesByPostURL describes
+func (h *SummariesHandler) GetSummariesByPostHandler(c echo.Context) error {
+	postURL := c.QueryParam("url")
+	if postURL == "" {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "url is required"})
+	}
+
+	summaries, err := h.useCase.GetSummariesByPostURL(postURL)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrInvalidPostURL):
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, summaries)
+}
+
 func (h *SummariesHandler) GetSummaryByIDHandler(c echo.Context) error {
 	id := c.Param("id")
 	summary, err := h.useCase.GetSummaryByID(id)
@@ -74,25 +122,45 @@ func (h *SummariesHandler) GetSummaryByIDHandler(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
 	}
 
+	c.Response().Header().Set("ETag", versionETag(summary.CurrentVersion))
 	return c.JSON(http.StatusOK, summary)
 }
 
 func (h *SummariesHandler) RateSummaryHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
 	id := c.Param("id")
 	var dto RateSummaryDto
 	if err := c.Bind(&dto); err != nil {
 		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
 	}
 
-	if err := h.useCase.RateSummary(id, dto); err != nil {
-		return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+	if err := utils.Validate(dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "Rating updated successfully"})
+	ratings, err := h.useCase.RateSummary(id, dto, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrUnauthenticated):
+			return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, ratings)
 }
 
 func (h *SummariesHandler) ModerateSummaryHandler(c echo.Context) error {
-	user, err := utils.GetUserFromContext(c)
+	user, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
 	}
@@ -107,24 +175,44 @@ func (h *SummariesHandler) ModerateSummaryHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
 	}
 
-	err = h.useCase.ModerateSummary(id, dto, user)
+	tally, err := h.useCase.ModerateSummary(id, dto, user)
 	if err != nil {
-		switch err {
-		case ErrNotModerator:
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
 			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
-		case ErrInvalidStatus:
+		case errors.Is(err, ErrInvalidStatus):
 			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrIllegalTransition), errors.Is(err, ErrAlreadyClaimed), errors.Is(err, ErrAppealPending), errors.Is(err, ErrAlreadyVoted):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
 		default:
 			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 		}
 	}
 
-	return c.JSON(http.StatusOK, map[string]string{"message": "Summary moderated successfully"})
+	return c.JSON(http.StatusOK, tally)
+}
+
+// GetSummaryTransitionsHandler returns the status history of a summary
+func (h *SummariesHandler) GetSummaryTransitionsHandler(c echo.Context) error {
+	id := c.Param("id")
+	transitions, err := h.useCase.GetSummaryTransitions(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, transitions)
 }
 
 // EditSummaryHandler handles requests to edit a summary's content
 func (h *SummariesHandler) EditSummaryHandler(c echo.Context) error {
-	user, err := utils.GetUserFromContext(c)
+	user, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
 	}
@@ -139,22 +227,42 @@ func (h *SummariesHandler) EditSummaryHandler(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
 	}
 
-	err = h.useCase.EditSummary(id, dto, user)
+	ifMatch, err := parseIfMatch(c.Request().Header.Get("If-Match"))
 	if err != nil {
-		switch err {
-		case ErrNotModerator:
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+	if ifMatch != 0 {
+		if dto.ExpectedVersion != 0 && dto.ExpectedVersion != ifMatch {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "If-Match and expected_version name different versions"})
+		}
+		dto.ExpectedVersion = ifMatch
+	}
+
+	edit, err := h.useCase.EditSummary(id, dto, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
 			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrVersionRequired):
+			return c.JSON(http.StatusPreconditionRequired, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrVersionConflict):
+			return versionConflict(c, err)
+		case errors.Is(err, ErrNotEditable):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
 		default:
 			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 		}
 	}
 
+	c.Response().Header().Set("ETag", versionETag(edit.Version))
 	return c.JSON(http.StatusOK, map[string]string{"message": "Summary edited successfully"})
 }
 
 // AddResourceLinkHandler handles requests to add a resource link to a summary
 func (h *SummariesHandler) AddResourceLinkHandler(c echo.Context) error {
-	user, err := utils.GetUserFromContext(c)
+	user, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
 	}
@@ -171,10 +279,10 @@ func (h *SummariesHandler) AddResourceLinkHandler(c echo.Context) error {
 
 	err = h.useCase.AddResourceLink(summaryID, dto, user)
 	if err != nil {
-		switch err {
-		case ErrNotModerator:
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
 			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
-		case ErrInvalidResource:
+		case errors.Is(err, ErrInvalidResource):
 			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
 		default:
 			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
@@ -186,7 +294,7 @@ func (h *SummariesHandler) AddResourceLinkHandler(c echo.Context) error {
 
 // RemoveResourceLinkHandler handles requests to remove a resource link from a summary
 func (h *SummariesHandler) RemoveResourceLinkHandler(c echo.Context) error {
-	user, err := utils.GetUserFromContext(c)
+	user, err := utils.GetPrincipal(c)
 	if err != nil {
 		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
 	}
@@ -194,9 +302,11 @@ func (h *SummariesHandler) RemoveResourceLinkHandler(c echo.Context) error {
 	linkID := c.Param("linkId")
 	err = h.useCase.RemoveResourceLink(linkID, user)
 	if err != nil {
-		switch err {
-		case ErrNotModerator:
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
 			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrInvalidResource):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
 		default:
 			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
 		}
@@ -204,3 +314,621 @@ func (h *SummariesHandler) RemoveResourceLinkHandler(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]string{"message": "Resource link removed successfully"})
 }
+
+// versionETag is the ETag of a summary at a version
+func versionETag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseIfMatch returns the version of an If-Match header, or 0 when there is none
+func parseIfMatch(header string) (int, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(header, "W/"), `"`))
+	if err != nil || version < 1 {
+		return 0, ErrInvalidETag
+	}
+	return version, nil
+}
+
+// versionConflict writes the 409 for a VersionConflictError, with the current version in the body and
+// as the ETag
+func versionConflict(c echo.Context, err error) error {
+	var conflict *VersionConflictError
+	if errors.As(err, &conflict) {
+		c.Response().Header().Set("ETag", versionETag(conflict.Current))
+		return c.JSON(http.StatusConflict, VersionConflictResponse{Error: err.Error(), CurrentVersion: conflict.Current})
+	}
+	return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+}
+
+// parseVersion reads a version number from a path or query param
+func parseVersion(value string) (int, error) {
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return 0, ErrInvalidVersion
+	}
+	return version, nil
+}
+
+// GetSummaryVersionsHandler returns the versions of a summary
+func (h *SummariesHandler) GetSummaryVersionsHandler(c echo.Context) error {
+	versions, err := h.useCase.GetSummaryVersions(c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, versions)
+}
+
+// GetSummaryVersionHandler returns one version of a summary
+func (h *SummariesHandler) GetSummaryVersionHandler(c echo.Context) error {
+	version, err := parseVersion(c.Param("v"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	edit, err := h.useCase.GetSummaryVersion(c.Param("id"), version)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSummaryNotFound), errors.Is(err, ErrVersionNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, edit)
+}
+
+// DiffSummaryVersionsHandler compares two versions of a summary, given by the from and to query params
+func (h *SummariesHandler) DiffSummaryVersionsHandler(c echo.Context) error {
+	from, err := parseVersion(c.QueryParam("from"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "from: " + err.Error()})
+	}
+	to, err := parseVersion(c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "to: " + err.Error()})
+	}
+
+	versionDiff, err := h.useCase.DiffSummaryVersions(c.Param("id"), from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSummaryNotFound), errors.Is(err, ErrVersionNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, versionDiff)
+}
+
+// RevertSummaryHandler restores an earlier version of a summary as a new version. The If-Match header must
+// name the summary's current version.
+func (h *SummariesHandler) RevertSummaryHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	version, err := parseVersion(c.Param("v"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	expectedVersion, err := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	edit, err := h.useCase.RevertSummary(c.Param("id"), version, expectedVersion, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrVersionRequired):
+			return c.JSON(http.StatusPreconditionRequired, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrVersionConflict):
+			return versionConflict(c, err)
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound), errors.Is(err, ErrVersionNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrNotEditable), errors.Is(err, ErrRevertUnchanged):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	c.Response().Header().Set("ETag", versionETag(edit.Version))
+	return c.JSON(http.StatusCreated, edit)
+}
+
+// GetModerationQueueHandler lists the moderation queue, sorted by the sort query param (age or priority)
+func (h *SummariesHandler) GetModerationQueueHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	queue, err := h.useCase.GetModerationQueue(user, c.QueryParam("sort"), params)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrInvalidSort):
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, queue)
+}
+
+// ClaimSummaryHandler claims a summary in the moderation queue for the current moderator
+func (h *SummariesHandler) ClaimSummaryHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	claim, err := h.useCase.ClaimSummary(c.Param("id"), user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrAlreadyClaimed), errors.Is(err, ErrNotInQueue), errors.Is(err, ErrAlreadyVoted):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, claim)
+}
+
+// ReleaseSummaryHandler releases the current moderator's claim on a summary
+func (h *SummariesHandler) ReleaseSummaryHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	err = h.useCase.ReleaseSummary(c.Param("id"), user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrClaimNotHeld):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
+
+// GetModerationStatsHandler reports moderator throughput since the since query param (RFC 3339), by default the last 7 days
+func (h *SummariesHandler) GetModerationStatsHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	since := time.Now().Add(-defaultStatsWindow)
+	if value := c.QueryParam("since"); value != "" {
+		since, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "since must be an RFC 3339 time"})
+		}
+	}
+
+	stats, err := h.useCase.GetModerationStats(user, since)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, stats)
+}
+
+// GetSummaryVotesHandler returns the moderators' votes on a summary
+func (h *SummariesHandler) GetSummaryVotesHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	votes, err := h.useCase.GetSummaryVotes(c.Param("id"), user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, votes)
+}
+
+// GetEscalatedSummariesHandler lists the summaries escalated to an admin
+func (h *SummariesHandler) GetEscalatedSummariesHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	escalated, err := h.useCase.GetEscalatedSummaries(user, params)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, escalated)
+}
+
+// FileAppealHandler files an appeal against the rejection of a summary
+func (h *SummariesHandler) FileAppealHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	var dto FileAppealDto
+	if err := c.Bind(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	if err := utils.Validate(dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	appeal, err := h.useCase.FileAppeal(c.Param("id"), dto, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden), errors.Is(err, ErrNotAuthor):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrAlreadyAppealed), errors.Is(err, ErrAppealNotAllowed), errors.Is(err, ErrIllegalTransition):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusCreated, appeal)
+}
+
+// GetSummaryAppealsHandler lists the appeals filed against a summary
+func (h *SummariesHandler) GetSummaryAppealsHandler(c echo.Context) error {
+	appeals, err := h.useCase.GetSummaryAppeals(c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, appeals)
+}
+
+// GetPendingAppealsHandler lists the appeals waiting for a reviewer, oldest first
+func (h *SummariesHandler) GetPendingAppealsHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	appeals, err := h.useCase.GetPendingAppeals(user, params)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, appeals)
+}
+
+// ResolveAppealHandler upholds or overturns an appeal
+func (h *SummariesHandler) ResolveAppealHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	var dto ResolveAppealDto
+	if err := c.Bind(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	if err := utils.Validate(dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	appeal, err := h.useCase.ResolveAppeal(c.Param("id"), dto, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden), errors.Is(err, ErrReviewerConflict):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrAppealNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrAppealNotPending), errors.Is(err, ErrIllegalTransition):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, appeal)
+}
+
+// SuggestEditHandler handles users' suggested edits of a summary
+func (h *SummariesHandler) SuggestEditHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	var dto SuggestEditDto
+	if err := c.Bind(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	if err := utils.Validate(dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	suggestion, err := h.useCase.SuggestEdit(c.Param("id"), dto, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrNotEditable), errors.Is(err, ErrSuggestionUnchanged):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusCreated, suggestion)
+}
+
+// GetPendingSuggestionsHandler lists the suggested edits waiting for a moderator, oldest first. The
+// summary_id query param limits them to one summary.
+func (h *SummariesHandler) GetPendingSuggestionsHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	suggestions, err := h.useCase.GetPendingSuggestions(c.QueryParam("summary_id"), user, params)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, suggestions)
+}
+
+// AcceptSuggestionHandler applies a suggested edit. The If-Match header names the version the moderator
+// reviewed the suggestion against.
+func (h *SummariesHandler) AcceptSuggestionHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	expectedVersion, err := parseIfMatch(c.Request().Header.Get("If-Match"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	edit, err := h.useCase.AcceptSuggestion(c.Param("id"), expectedVersion, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden), errors.Is(err, ErrOwnSuggestion):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSuggestionNotFound), errors.Is(err, ErrSummaryNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrVersionRequired):
+			return c.JSON(http.StatusPreconditionRequired, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrVersionConflict):
+			return versionConflict(c, err)
+		case errors.Is(err, ErrSuggestionNotPending), errors.Is(err, ErrNotEditable):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	c.Response().Header().Set("ETag", versionETag(edit.Version))
+	return c.JSON(http.StatusOK, edit)
+}
+
+// DeclineSuggestionHandler declines a suggested edit with a reason
+func (h *SummariesHandler) DeclineSuggestionHandler(c echo.Context) error {
+	user, err := utils.GetPrincipal(c)
+	if err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: "unauthorized"})
+	}
+
+	var dto DeclineSuggestionDto
+	if err := c.Bind(&dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	if err := utils.Validate(dto); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	edit, err := h.useCase.DeclineSuggestion(c.Param("id"), dto, user)
+	if err != nil {
+		switch {
+		case errors.Is(err, permissions.ErrForbidden), errors.Is(err, ErrOwnSuggestion):
+			return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSuggestionNotFound):
+			return c.JSON(http.StatusNotFound, utils.ErrorResponse{Error: err.Error()})
+		case errors.Is(err, ErrSuggestionNotPending):
+			return c.JSON(http.StatusConflict, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, edit)
+}
+
+// SearchHandler searches summaries, or summary requests with type=request. It takes the query in q and
+// optional status, author, verified, from and to (RFC 3339) filters. Results are paged with the limit,
+// cursor and total query params.
+func (h *SummariesHandler) SearchHandler(c echo.Context) error {
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	query := search.Query{
+		Kind:     c.QueryParam("type"),
+		Text:     c.QueryParam("q"),
+		Status:   c.QueryParam("status"),
+		AuthorID: c.QueryParam("author"),
+	}
+
+	if value := c.QueryParam("verified"); value != "" {
+		verified, err := strconv.ParseBool(value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "verified must be true or false"})
+		}
+		query.Verified = &verified
+	}
+
+	if value := c.QueryParam("from"); value != "" {
+		query.From, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "from must be an RFC 3339 time"})
+		}
+	}
+	if value := c.QueryParam("to"); value != "" {
+		query.To, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: "to must be an RFC 3339 time"})
+		}
+	}
+
+	results, err := h.useCase.Search(c.Request().Context(), query, params)
+	if err != nil {
+		switch {
+		case errors.Is(err, search.ErrEmptyQuery), errors.Is(err, ErrInvalidSearchKind):
+			return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+		default:
+			return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+		}
+	}
+
+	return c.JSON(http.StatusOK, results)
+}
+
+// VerifyWebhookHandler answers Facebook's subscription handshake by echoing hub.challenge
+func (h *SummariesHandler) VerifyWebhookHandler(c echo.Context) error {
+	challenge, err := h.useCase.VerifyWebhookSubscription(c.QueryParam("hub.mode"), c.QueryParam("hub.verify_token"), c.QueryParam("hub.challenge"))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	return c.String(http.StatusOK, challenge)
+}
+
+// ReceiveWebhookHandler receives page feed changes and creates summary requests for new posts on monitored pages
+func (h *SummariesHandler) ReceiveWebhookHandler(c echo.Context) error {
+	body, err := io.ReadAll(c.Request().Body)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	if err := h.useCase.VerifyWebhookSignature(body, c.Request().Header.Get(facebook.SignatureHeader)); err != nil {
+		return c.JSON(http.StatusUnauthorized, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	var event facebook.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return c.JSON(http.StatusBadRequest, utils.ErrorResponse{Error: err.Error()})
+	}
+
+	created := 0
+	if event.Object == "page" {
+		for _, entry := range event.Entry {
+			for _, change := range entry.Changes {
+				if change.Field != facebook.FieldFeed {
+					continue
+				}
+
+				ok, err := h.useCase.HandleFeedChange(c.Request().Context(), entry.ID, change.Value)
+				if err != nil {
+					// A non 200 response makes Facebook deliver the event again
+					return c.JSON(http.StatusInternalServerError, utils.ErrorResponse{Error: err.Error()})
+				}
+				if ok {
+					created++
+				}
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, map[string]int{"created": created})
+}
@@ -0,0 +1,38 @@
+package summaries
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/queue"
+)
+
+const JobSummarizeRequest = "summarize_request"
+
+type summarizeRequestPayload struct {
+	RequestID string `json:"request_id"`
+}
+
+// HandleJob runs a job taken from the summaries queue
+func (uc *SummariesUseCase) HandleJob(ctx context.Context, job queue.Job) error {
+	switch job.Type {
+	case JobSummarizeRequest:
+		var payload summarizeRequestPayload
+		if err := json.Unmarshal(job.Payload, &payload); err != nil {
+			return queue.Permanent(err)
+		}
+		return uc.processAISummarization(ctx, payload.RequestID)
+	default:
+		return queue.Permanent(fmt.Errorf("unknown job type %q", job.Type))
+	}
+}
+
+// QueueName returns the configured name of the summaries queue
+func QueueName(cfg config.Config) string {
+	if cfg.Queue.Name == "" {
+		return "summaries"
+	}
+	return cfg.Queue.Name
+}
@@ -0,0 +1,74 @@
+package summaries
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ActorSummarizer is recorded as the actor of transitions made by the summarization worker
+const ActorSummarizer = "system:summarizer"
+
+var (
+	ErrIllegalTransition  = errors.New("illegal summary status transition")
+	ErrNotEditable        = errors.New("summary can not be edited in its current status")
+	ErrTransitionNotFound = errors.New("summary has no recorded transition into the status")
+)
+
+// summaryTransitions lists the statuses a summary may move to from each status.
+// Approved is final; a rejection can be appealed once, and the appeal ends in approved (overturned)
+// or rejected (upheld).
+var summaryTransitions = map[string][]string{
+	StatusPending:    {StatusAIReviewed},
+	StatusAIReviewed: {StatusApproved, StatusRejected, StatusEscalated},
+	StatusEscalated:  {StatusApproved, StatusRejected},
+	StatusApproved:   {},
+	StatusRejected:   {StatusAppealed},
+	StatusAppealed:   {StatusApproved, StatusRejected},
+}
+
+// editableStatuses are the statuses in which a summary's content may be edited
+var editableStatuses = map[string]bool{
+	StatusAIReviewed: true,
+	StatusApproved:   true,
+}
+
+// IllegalTransitionError is returned when a summary can't move from its current status to the requested one
+type IllegalTransitionError struct {
+	From string
+	To   string
+}
+
+func (e *IllegalTransitionError) Error() string {
+	return fmt.Sprintf("summary can not move from %s to %s", e.From, e.To)
+}
+
+// Is lets callers match any IllegalTransitionError with errors.Is(err, ErrIllegalTransition)
+func (e *IllegalTransitionError) Is(target error) bool {
+	return target == ErrIllegalTransition
+}
+
+// CanTransition reports whether a summary may move from one status to another
+func CanTransition(from string, to string) bool {
+	for _, allowed := range summaryTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// checkTransition returns an IllegalTransitionError when a summary may not move from one status to another
+func checkTransition(from string, to string) error {
+	if !CanTransition(from, to) {
+		return &IllegalTransitionError{From: from, To: to}
+	}
+	return nil
+}
+
+// checkEditable returns ErrNotEditable when a summary's content may not be edited in its status
+func checkEditable(status string) error {
+	if !editableStatuses[status] {
+		return ErrNotEditable
+	}
+	return nil
+}
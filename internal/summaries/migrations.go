@@ -0,0 +1,19 @@
+package summaries
+
+import (
+	"embed"
+	"path"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+)
+
+// MigrationsName is the name the summaries migrations are recorded under
+const MigrationsName = "summaries"
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrations returns the schema migrations of the summaries service, worker and scorer database for the given db dialect
+func Migrations(dialect string) ([]migrate.Migration, error) {
+	return migrate.Load(migrationFiles, path.Join("migrations", dialect))
+}
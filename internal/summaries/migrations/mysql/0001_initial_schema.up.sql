@@ -0,0 +1,132 @@
+CREATE TABLE summary_requests (
+  id VARCHAR(191) NOT NULL,
+  content LONGTEXT,
+  metadata LONGTEXT,
+  post_id VARCHAR(191) NULL,
+  post_url LONGTEXT,
+  user_id VARCHAR(191) NOT NULL,
+  created_at DATETIME(3) NULL,
+  status VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_summary_requests_post_id (post_id),
+  KEY idx_summary_requests_created_at_id (created_at, id),
+  FULLTEXT KEY idx_summary_requests_fulltext (content)
+);
+
+CREATE TABLE summaries (
+  id VARCHAR(191) NOT NULL,
+  request_id VARCHAR(191) NOT NULL,
+  post_id VARCHAR(191) NULL,
+  content LONGTEXT,
+  summary LONGTEXT,
+  is_verified BOOLEAN NOT NULL DEFAULT FALSE,
+  is_summarized_ai BOOLEAN NOT NULL DEFAULT FALSE,
+  rating DOUBLE NOT NULL DEFAULT 0,
+  rating_count BIGINT NOT NULL DEFAULT 0,
+  helpfulness_status VARCHAR(64) NOT NULL DEFAULT 'needs_more_ratings',
+  helpfulness_score DOUBLE NOT NULL DEFAULT 0,
+  scored_at DATETIME(3) NULL,
+  created_at DATETIME(3) NULL,
+  updated_at DATETIME(3) NULL,
+  user_id VARCHAR(191) NOT NULL,
+  status VARCHAR(64) NOT NULL,
+  moderator_id VARCHAR(191) NULL,
+  moderated_at DATETIME(3) NULL,
+  review_ready_at DATETIME(3) NULL,
+  ai_response LONGTEXT,
+  moderator_notes LONGTEXT,
+  current_version BIGINT NOT NULL DEFAULT 1,
+  PRIMARY KEY (id),
+  KEY idx_summaries_request_id (request_id),
+  KEY idx_summaries_post_id (post_id),
+  KEY idx_summaries_status (status),
+  KEY idx_summaries_review_ready_at (review_ready_at),
+  KEY idx_summaries_created_at_id (created_at, id),
+  FULLTEXT KEY idx_summaries_fulltext (content, summary)
+);
+
+CREATE TABLE summary_ratings (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  user_id VARCHAR(191) NOT NULL,
+  rating BIGINT NOT NULL,
+  created_at DATETIME(3) NULL,
+  updated_at DATETIME(3) NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY idx_summary_ratings_summary_user (summary_id, user_id)
+);
+
+CREATE TABLE resource_links (
+  id VARCHAR(191) NOT NULL,
+  url LONGTEXT,
+  title VARCHAR(512) NOT NULL,
+  description LONGTEXT,
+  summary_id VARCHAR(191) NOT NULL,
+  created_at DATETIME(3) NULL,
+  created_by VARCHAR(191) NOT NULL,
+  PRIMARY KEY (id),
+  KEY idx_resource_links_summary_id (summary_id),
+  FULLTEXT KEY idx_resource_links_fulltext (title)
+);
+
+CREATE TABLE summary_edits (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  content LONGTEXT,
+  edited_by VARCHAR(191) NOT NULL,
+  edited_at DATETIME(3) NULL,
+  version BIGINT NOT NULL,
+  edit_message LONGTEXT,
+  PRIMARY KEY (id),
+  KEY idx_summary_edits_summary_id (summary_id)
+);
+
+CREATE TABLE summary_transitions (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  from_status VARCHAR(64) NOT NULL,
+  to_status VARCHAR(64) NOT NULL,
+  actor_id VARCHAR(191) NOT NULL,
+  reason LONGTEXT,
+  created_at DATETIME(3) NULL,
+  PRIMARY KEY (id),
+  KEY idx_summary_transitions_summary_id (summary_id)
+);
+
+CREATE TABLE moderation_claims (
+  summary_id VARCHAR(191) NOT NULL,
+  moderator_id VARCHAR(191) NOT NULL,
+  claimed_at DATETIME(3) NULL,
+  expires_at DATETIME(3) NULL,
+  PRIMARY KEY (summary_id),
+  KEY idx_moderation_claims_moderator_id (moderator_id)
+);
+
+CREATE TABLE moderation_votes (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  moderator_id VARCHAR(191) NOT NULL,
+  decision VARCHAR(16) NOT NULL,
+  notes LONGTEXT,
+  created_at DATETIME(3) NULL,
+  PRIMARY KEY (id),
+  UNIQUE KEY idx_vote_summary_moderator (summary_id, moderator_id)
+);
+
+CREATE TABLE summary_appeals (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  decision_id VARCHAR(191) NOT NULL,
+  appellant_id VARCHAR(191) NOT NULL,
+  justification LONGTEXT,
+  original_moderator_id VARCHAR(191) NOT NULL,
+  status VARCHAR(64) NOT NULL,
+  reviewer_id VARCHAR(191) NULL,
+  review_notes LONGTEXT,
+  created_at DATETIME(3) NULL,
+  resolved_at DATETIME(3) NULL,
+  PRIMARY KEY (id),
+  KEY idx_summary_appeals_summary_id (summary_id),
+  UNIQUE KEY idx_summary_appeals_decision_id (decision_id),
+  KEY idx_summary_appeals_status (status)
+);
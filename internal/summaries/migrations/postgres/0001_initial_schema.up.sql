@@ -0,0 +1,132 @@
+CREATE TABLE summary_requests (
+  id VARCHAR(191) NOT NULL,
+  content TEXT,
+  metadata TEXT,
+  post_id VARCHAR(191) NULL,
+  post_url TEXT,
+  user_id VARCHAR(191) NOT NULL,
+  created_at TIMESTAMPTZ NULL,
+  status VARCHAR(64) NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_requests_post_id ON summary_requests (post_id);
+CREATE INDEX idx_summary_requests_created_at_id ON summary_requests (created_at, id);
+CREATE INDEX idx_summary_requests_fulltext ON summary_requests USING GIN (to_tsvector('simple', coalesce(content, '')));
+
+CREATE TABLE summaries (
+  id VARCHAR(191) NOT NULL,
+  request_id VARCHAR(191) NOT NULL,
+  post_id VARCHAR(191) NULL,
+  content TEXT,
+  summary TEXT,
+  is_verified BOOLEAN NOT NULL DEFAULT FALSE,
+  is_summarized_ai BOOLEAN NOT NULL DEFAULT FALSE,
+  rating DOUBLE PRECISION NOT NULL DEFAULT 0,
+  rating_count BIGINT NOT NULL DEFAULT 0,
+  helpfulness_status VARCHAR(64) NOT NULL DEFAULT 'needs_more_ratings',
+  helpfulness_score DOUBLE PRECISION NOT NULL DEFAULT 0,
+  scored_at TIMESTAMPTZ NULL,
+  created_at TIMESTAMPTZ NULL,
+  updated_at TIMESTAMPTZ NULL,
+  user_id VARCHAR(191) NOT NULL,
+  status VARCHAR(64) NOT NULL,
+  moderator_id VARCHAR(191) NULL,
+  moderated_at TIMESTAMPTZ NULL,
+  review_ready_at TIMESTAMPTZ NULL,
+  ai_response TEXT,
+  moderator_notes TEXT,
+  current_version BIGINT NOT NULL DEFAULT 1,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summaries_request_id ON summaries (request_id);
+CREATE INDEX idx_summaries_post_id ON summaries (post_id);
+CREATE INDEX idx_summaries_status ON summaries (status);
+CREATE INDEX idx_summaries_review_ready_at ON summaries (review_ready_at);
+CREATE INDEX idx_summaries_created_at_id ON summaries (created_at, id);
+CREATE INDEX idx_summaries_fulltext ON summaries USING GIN (to_tsvector('simple', coalesce(content, '') || ' ' || coalesce(summary, '')));
+
+CREATE TABLE summary_ratings (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  user_id VARCHAR(191) NOT NULL,
+  rating BIGINT NOT NULL,
+  created_at TIMESTAMPTZ NULL,
+  updated_at TIMESTAMPTZ NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_summary_ratings_summary_user ON summary_ratings (summary_id, user_id);
+
+CREATE TABLE resource_links (
+  id VARCHAR(191) NOT NULL,
+  url TEXT,
+  title VARCHAR(512) NOT NULL,
+  description TEXT,
+  summary_id VARCHAR(191) NOT NULL,
+  created_at TIMESTAMPTZ NULL,
+  created_by VARCHAR(191) NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_resource_links_summary_id ON resource_links (summary_id);
+CREATE INDEX idx_resource_links_fulltext ON resource_links USING GIN (to_tsvector('simple', coalesce(title, '')));
+
+CREATE TABLE summary_edits (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  content TEXT,
+  edited_by VARCHAR(191) NOT NULL,
+  edited_at TIMESTAMPTZ NULL,
+  version BIGINT NOT NULL,
+  edit_message TEXT,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_edits_summary_id ON summary_edits (summary_id);
+
+CREATE TABLE summary_transitions (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  from_status VARCHAR(64) NOT NULL,
+  to_status VARCHAR(64) NOT NULL,
+  actor_id VARCHAR(191) NOT NULL,
+  reason TEXT,
+  created_at TIMESTAMPTZ NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_transitions_summary_id ON summary_transitions (summary_id);
+
+CREATE TABLE moderation_claims (
+  summary_id VARCHAR(191) NOT NULL,
+  moderator_id VARCHAR(191) NOT NULL,
+  claimed_at TIMESTAMPTZ NULL,
+  expires_at TIMESTAMPTZ NULL,
+  PRIMARY KEY (summary_id)
+);
+CREATE INDEX idx_moderation_claims_moderator_id ON moderation_claims (moderator_id);
+
+CREATE TABLE moderation_votes (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  moderator_id VARCHAR(191) NOT NULL,
+  decision VARCHAR(16) NOT NULL,
+  notes TEXT,
+  created_at TIMESTAMPTZ NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_vote_summary_moderator ON moderation_votes (summary_id, moderator_id);
+
+CREATE TABLE summary_appeals (
+  id VARCHAR(191) NOT NULL,
+  summary_id VARCHAR(191) NOT NULL,
+  decision_id VARCHAR(191) NOT NULL,
+  appellant_id VARCHAR(191) NOT NULL,
+  justification TEXT,
+  original_moderator_id VARCHAR(191) NOT NULL,
+  status VARCHAR(64) NOT NULL,
+  reviewer_id VARCHAR(191) NULL,
+  review_notes TEXT,
+  created_at TIMESTAMPTZ NULL,
+  resolved_at TIMESTAMPTZ NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_appeals_summary_id ON summary_appeals (summary_id);
+CREATE UNIQUE INDEX idx_summary_appeals_decision_id ON summary_appeals (decision_id);
+CREATE INDEX idx_summary_appeals_status ON summary_appeals (status);
@@ -0,0 +1 @@
+DELETE FROM summary_edits WHERE id = summary_id || '-v1';
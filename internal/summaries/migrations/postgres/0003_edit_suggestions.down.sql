@@ -0,0 +1,10 @@
+DELETE FROM summary_edits WHERE status <> 'applied';
+DROP INDEX IF EXISTS idx_summary_edits_status;
+
+ALTER TABLE summary_edits
+  DROP COLUMN diff,
+  DROP COLUMN reviewed_at,
+  DROP COLUMN review_notes,
+  DROP COLUMN reviewer_id,
+  DROP COLUMN base_version,
+  DROP COLUMN status;
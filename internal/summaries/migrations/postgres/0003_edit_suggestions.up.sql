@@ -0,0 +1,10 @@
+-- Suggested edits are pending summary_edits rows without a version until a moderator reviews them
+-- and keep their diff against their base version, so listing them does not diff every one
+ALTER TABLE summary_edits
+  ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'applied',
+  ADD COLUMN base_version BIGINT NOT NULL DEFAULT 0,
+  ADD COLUMN reviewer_id VARCHAR(191) NULL,
+  ADD COLUMN review_notes TEXT,
+  ADD COLUMN reviewed_at TIMESTAMPTZ NULL,
+  ADD COLUMN diff TEXT NULL;
+CREATE INDEX idx_summary_edits_status ON summary_edits (status);
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS summary_appeals;
+DROP TABLE IF EXISTS moderation_votes;
+DROP TABLE IF EXISTS moderation_claims;
+DROP TABLE IF EXISTS summary_transitions;
+DROP TABLE IF EXISTS summary_edits;
+DROP TABLE IF EXISTS resource_links;
+DROP TABLE IF EXISTS summary_ratings;
+DROP TABLE IF EXISTS summaries;
+DROP TABLE IF EXISTS summary_requests;
@@ -0,0 +1,129 @@
+CREATE TABLE summary_requests (
+  id TEXT NOT NULL,
+  content TEXT,
+  metadata TEXT,
+  post_id TEXT NULL,
+  post_url TEXT,
+  user_id TEXT NOT NULL,
+  created_at DATETIME NULL,
+  status TEXT NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_requests_post_id ON summary_requests (post_id);
+CREATE INDEX idx_summary_requests_created_at_id ON summary_requests (created_at, id);
+
+CREATE TABLE summaries (
+  id TEXT NOT NULL,
+  request_id TEXT NOT NULL,
+  post_id TEXT NULL,
+  content TEXT,
+  summary TEXT,
+  is_verified NUMERIC NOT NULL DEFAULT FALSE,
+  is_summarized_ai NUMERIC NOT NULL DEFAULT FALSE,
+  rating REAL NOT NULL DEFAULT 0,
+  rating_count INTEGER NOT NULL DEFAULT 0,
+  helpfulness_status TEXT NOT NULL DEFAULT 'needs_more_ratings',
+  helpfulness_score REAL NOT NULL DEFAULT 0,
+  scored_at DATETIME NULL,
+  created_at DATETIME NULL,
+  updated_at DATETIME NULL,
+  user_id TEXT NOT NULL,
+  status TEXT NOT NULL,
+  moderator_id TEXT NULL,
+  moderated_at DATETIME NULL,
+  review_ready_at DATETIME NULL,
+  ai_response TEXT,
+  moderator_notes TEXT,
+  current_version INTEGER NOT NULL DEFAULT 1,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summaries_request_id ON summaries (request_id);
+CREATE INDEX idx_summaries_post_id ON summaries (post_id);
+CREATE INDEX idx_summaries_status ON summaries (status);
+CREATE INDEX idx_summaries_review_ready_at ON summaries (review_ready_at);
+CREATE INDEX idx_summaries_created_at_id ON summaries (created_at, id);
+
+CREATE TABLE summary_ratings (
+  id TEXT NOT NULL,
+  summary_id TEXT NOT NULL,
+  user_id TEXT NOT NULL,
+  rating INTEGER NOT NULL,
+  created_at DATETIME NULL,
+  updated_at DATETIME NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_summary_ratings_summary_user ON summary_ratings (summary_id, user_id);
+
+CREATE TABLE resource_links (
+  id TEXT NOT NULL,
+  url TEXT,
+  title TEXT NOT NULL,
+  description TEXT,
+  summary_id TEXT NOT NULL,
+  created_at DATETIME NULL,
+  created_by TEXT NOT NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_resource_links_summary_id ON resource_links (summary_id);
+
+CREATE TABLE summary_edits (
+  id TEXT NOT NULL,
+  summary_id TEXT NOT NULL,
+  content TEXT,
+  edited_by TEXT NOT NULL,
+  edited_at DATETIME NULL,
+  version INTEGER NOT NULL,
+  edit_message TEXT,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_edits_summary_id ON summary_edits (summary_id);
+
+CREATE TABLE summary_transitions (
+  id TEXT NOT NULL,
+  summary_id TEXT NOT NULL,
+  from_status TEXT NOT NULL,
+  to_status TEXT NOT NULL,
+  actor_id TEXT NOT NULL,
+  reason TEXT,
+  created_at DATETIME NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_transitions_summary_id ON summary_transitions (summary_id);
+
+CREATE TABLE moderation_claims (
+  summary_id TEXT NOT NULL,
+  moderator_id TEXT NOT NULL,
+  claimed_at DATETIME NULL,
+  expires_at DATETIME NULL,
+  PRIMARY KEY (summary_id)
+);
+CREATE INDEX idx_moderation_claims_moderator_id ON moderation_claims (moderator_id);
+
+CREATE TABLE moderation_votes (
+  id TEXT NOT NULL,
+  summary_id TEXT NOT NULL,
+  moderator_id TEXT NOT NULL,
+  decision TEXT NOT NULL,
+  notes TEXT,
+  created_at DATETIME NULL,
+  PRIMARY KEY (id)
+);
+CREATE UNIQUE INDEX idx_vote_summary_moderator ON moderation_votes (summary_id, moderator_id);
+
+CREATE TABLE summary_appeals (
+  id TEXT NOT NULL,
+  summary_id TEXT NOT NULL,
+  decision_id TEXT NOT NULL,
+  appellant_id TEXT NOT NULL,
+  justification TEXT,
+  original_moderator_id TEXT NOT NULL,
+  status TEXT NOT NULL,
+  reviewer_id TEXT NULL,
+  review_notes TEXT,
+  created_at DATETIME NULL,
+  resolved_at DATETIME NULL,
+  PRIMARY KEY (id)
+);
+CREATE INDEX idx_summary_appeals_summary_id ON summary_appeals (summary_id);
+CREATE UNIQUE INDEX idx_summary_appeals_decision_id ON summary_appeals (decision_id);
+CREATE INDEX idx_summary_appeals_status ON summary_appeals (status);
@@ -0,0 +1,6 @@
+-- Summaries get version 1 of their edit history on creation. Summaries that were never edited still have
+-- their original content, so it is recorded as their version 1.
+INSERT INTO summary_edits (id, summary_id, content, edited_by, edited_at, version, edit_message)
+SELECT summaries.id || '-v1', summaries.id, summaries.content, summaries.user_id, summaries.created_at, 1, 'Initial version'
+FROM summaries
+WHERE NOT EXISTS (SELECT 1 FROM summary_edits WHERE summary_edits.summary_id = summaries.id);
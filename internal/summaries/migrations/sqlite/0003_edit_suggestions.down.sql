@@ -0,0 +1,9 @@
+DELETE FROM summary_edits WHERE status <> 'applied';
+DROP INDEX IF EXISTS idx_summary_edits_status;
+
+ALTER TABLE summary_edits DROP COLUMN diff;
+ALTER TABLE summary_edits DROP COLUMN reviewed_at;
+ALTER TABLE summary_edits DROP COLUMN review_notes;
+ALTER TABLE summary_edits DROP COLUMN reviewer_id;
+ALTER TABLE summary_edits DROP COLUMN base_version;
+ALTER TABLE summary_edits DROP COLUMN status;
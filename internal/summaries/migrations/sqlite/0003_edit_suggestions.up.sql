@@ -0,0 +1,9 @@
+-- Suggested edits are pending summary_edits rows without a version until a moderator reviews them
+-- and keep their diff against their base version, so listing them does not diff every one
+ALTER TABLE summary_edits ADD COLUMN status TEXT NOT NULL DEFAULT 'applied';
+ALTER TABLE summary_edits ADD COLUMN base_version INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE summary_edits ADD COLUMN reviewer_id TEXT NULL;
+ALTER TABLE summary_edits ADD COLUMN review_notes TEXT;
+ALTER TABLE summary_edits ADD COLUMN reviewed_at DATETIME NULL;
+ALTER TABLE summary_edits ADD COLUMN diff TEXT NULL;
+CREATE INDEX idx_summary_edits_status ON summary_edits (status);
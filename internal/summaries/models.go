@@ -3,7 +3,10 @@ package summaries
 import (
 	"time"
 
+	"github.com/mwelwankuta/facebook-notes/pkg/diff"
+	"github.com/mwelwankuta/facebook-notes/pkg/facebook"
 	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
 )
 
 const (
@@ -11,49 +14,95 @@ const (
 	StatusAIReviewed = "ai_reviewed"
 	StatusApproved   = "approved"
 	StatusRejected   = "rejected"
+	StatusAppealed   = "appealed"
+	StatusEscalated  = "escalated"
 
-	RoleModerator = "moderator"
-	RoleUser      = "user"
+	VoteApprove = "approve"
+	VoteReject  = "reject"
+
+	EditApplied  = "applied"
+	EditPending  = "pending"
+	EditDeclined = "declined"
+
+	AppealPending    = "pending"
+	AppealUpheld     = "upheld"
+	AppealOverturned = "overturned"
 )
 
 type Summary struct {
-	ID             string    `json:"id" gorm:"primarykey"`
-	Content        string    `json:"content"`
-	Summary        string    `json:"summary"`
-	IsVerified     bool      `json:"is_verified"`
-	IsSummarizedAI bool      `json:"is_summarized_ai"`
-	Rating         float64   `json:"rating"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	UserID         string    `json:"user_id"`
-	User           models.User
-	Status         string         `json:"status"`
-	ModeratorID    *string        `json:"moderator_id,omitempty"`
-	ModeratedAt    *time.Time     `json:"moderated_at,omitempty"`
-	AIResponse     string         `json:"ai_response,omitempty"`
-	ModeratorNotes string         `json:"moderator_notes,omitempty"`
-	Resources      []ResourceLink `json:"resources"`
-	EditHistory    []SummaryEdit  `json:"edit_history"`
-	CurrentVersion int            `json:"current_version" gorm:"default:1"`
+	ID             string  `json:"id" gorm:"primarykey"`
+	RequestID      string  `json:"request_id" gorm:"index"`
+	PostID         string  `json:"post_id,omitempty" gorm:"index"`
+	Content        string  `json:"content" gorm:"index:idx_summaries_fulltext,class:FULLTEXT"`
+	Summary        string  `json:"summary" gorm:"index:idx_summaries_fulltext,class:FULLTEXT"`
+	IsVerified     bool    `json:"is_verified"`
+	IsSummarizedAI bool    `json:"is_summarized_ai"`
+	Rating         float64 `json:"rating"`
+	RatingCount    int64   `json:"rating_count"`
+	// HelpfulnessStatus and HelpfulnessScore are written by the bridging-based scorer, see HelpfulnessScorer
+	HelpfulnessStatus string     `json:"helpfulness_status" gorm:"default:needs_more_ratings"`
+	HelpfulnessScore  float64    `json:"helpfulness_score"`
+	ScoredAt          *time.Time `json:"scored_at,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at"`
+	UserID            string     `json:"user_id"`
+	User              models.User
+	Status            string           `json:"status"`
+	ModeratorID       *string          `json:"moderator_id,omitempty"`
+	ModeratedAt       *time.Time       `json:"moderated_at,omitempty"`
+	ReviewReadyAt     *time.Time       `json:"review_ready_at,omitempty" gorm:"index"`
+	AIResponse        string           `json:"ai_response,omitempty"`
+	ModeratorNotes    string           `json:"moderator_notes,omitempty"`
+	Resources         []ResourceLink   `json:"resources"`
+	EditHistory       []SummaryEdit    `json:"edit_history"`
+	CurrentVersion    int              `json:"current_version" gorm:"default:1"`
+	Ratings           *RatingAggregate `json:"ratings,omitempty" gorm:"-"`
 }
 
 type SummaryRequest struct {
 	ID        string    `json:"id" gorm:"primarykey"`
-	Content   string    `json:"content"`
+	Content   string    `json:"content" gorm:"index:idx_summary_requests_fulltext,class:FULLTEXT"`
 	Metadata  string    `json:"metadata"`
+	PostID    string    `json:"post_id,omitempty" gorm:"index"`
+	PostURL   string    `json:"post_url,omitempty"`
 	UserID    string    `json:"user_id"`
 	CreatedAt time.Time `json:"created_at"`
 	Status    string    `json:"status"`
 	User      models.User
 }
 
+// CreateSummaryRequestDto needs either the content to summarize or the url of a post to fetch it from
 type CreateSummaryRequestDto struct {
-	Content  string `json:"content" validate:"required,min=10"`
-	Metadata string `json:"metadata" validate:"required"`
+	Content  string `json:"content" validate:"required_without=PostURL,omitempty,min=10"`
+	Metadata string `json:"metadata" validate:"required_without=PostURL"`
+	PostURL  string `json:"post_url" validate:"omitempty,url"`
+}
+
+// PostSummariesResponse lists the approved summaries of a Facebook post
+type PostSummariesResponse struct {
+	Post      facebook.PostRef `json:"post"`
+	Summaries []Summary        `json:"summaries"`
 }
 
 type RateSummaryDto struct {
-	Rating float64 `json:"rating" validate:"required,min=0,max=5"`
+	Rating int `json:"rating" validate:"required,min=1,max=5"`
+}
+
+// SummaryRating is a single user's vote on a summary. Each user has at most one rating per summary.
+type SummaryRating struct {
+	ID        string    `json:"id" gorm:"primarykey"`
+	SummaryID string    `json:"summary_id" gorm:"uniqueIndex:idx_summary_ratings_summary_user"`
+	UserID    string    `json:"user_id" gorm:"uniqueIndex:idx_summary_ratings_summary_user"`
+	Rating    int       `json:"rating"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// RatingAggregate is computed from every SummaryRating of a summary
+type RatingAggregate struct {
+	Mean         float64       `json:"mean"`
+	Count        int64         `json:"count"`
+	Distribution map[int]int64 `json:"distribution"`
 }
 
 type ModerateRequestDto struct {
@@ -64,30 +113,189 @@ type ModerateRequestDto struct {
 type ResourceLink struct {
 	ID          string    `json:"id" gorm:"primarykey"`
 	URL         string    `json:"url"`
-	Title       string    `json:"title"`
+	Title       string    `json:"title" gorm:"index:idx_resource_links_fulltext,class:FULLTEXT"`
 	Description string    `json:"description"`
 	SummaryID   string    `json:"summary_id"`
 	CreatedAt   time.Time `json:"created_at"`
 	CreatedBy   string    `json:"created_by"`
 }
 
+// SummaryEdit is a version of a summary's content. Edits suggested by users are pending, without a version,
+// until a moderator accepts them as the next version or declines them. BaseVersion is the version a
+// suggestion was made on and 0 for edits made by moderators directly.
 type SummaryEdit struct {
-	ID          string    `json:"id" gorm:"primarykey"`
-	SummaryID   string    `json:"summary_id"`
-	Content     string    `json:"content"`
-	EditedBy    string    `json:"edited_by"`
-	EditedAt    time.Time `json:"edited_at"`
-	Version     int       `json:"version"`
-	EditMessage string    `json:"edit_message"`
+	ID          string     `json:"id" gorm:"primarykey"`
+	SummaryID   string     `json:"summary_id"`
+	Content     string     `json:"content"`
+	EditedBy    string     `json:"edited_by"`
+	EditedAt    time.Time  `json:"edited_at"`
+	Version     int        `json:"version"`
+	EditMessage string     `json:"edit_message"`
+	Status      string     `json:"status" gorm:"index;default:applied"`
+	BaseVersion int        `json:"base_version,omitempty"`
+	ReviewerID  *string    `json:"reviewer_id,omitempty"`
+	ReviewNotes string     `json:"review_notes,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+	// Diff of a suggestion against its base version, stored when it is suggested
+	Diff *SuggestionDiff `json:"-" gorm:"serializer:json"`
 }
 
-type EditSummaryDto struct {
-	Content     string `json:"content" validate:"required,min=10"`
+// SuggestionDiff is the difference between a suggested edit and the content it was suggested on
+type SuggestionDiff struct {
+	Lines []diff.Chunk `json:"lines"`
+	Words []diff.Chunk `json:"words"`
+}
+
+// SuggestEditDto is an edit suggested by a user
+type SuggestEditDto struct {
+	Content     string `json:"content" validate:"required,min=10,max=20000"`
 	EditMessage string `json:"edit_message" validate:"required,min=5"`
 }
 
+type DeclineSuggestionDto struct {
+	Reason string `json:"reason" validate:"required,min=5"`
+}
+
+// EditSuggestion is a suggested edit with its diff against the version it was suggested on. The summary has
+// moved on since when CurrentVersion is past BaseVersion.
+type EditSuggestion struct {
+	SummaryEdit
+	CurrentVersion int          `json:"current_version"`
+	Lines          []diff.Chunk `json:"lines"`
+	Words          []diff.Chunk `json:"words"`
+}
+
+// VersionConflictResponse is the body of a 409 for an edit made on an outdated version
+type VersionConflictResponse struct {
+	Error          string `json:"error"`
+	CurrentVersion int    `json:"current_version"`
+}
+
+// VersionDiff is the difference between the content of two versions of a summary
+type VersionDiff struct {
+	SummaryID string       `json:"summary_id"`
+	From      int          `json:"from"`
+	To        int          `json:"to"`
+	Lines     []diff.Chunk `json:"lines"`
+	Words     []diff.Chunk `json:"words"`
+}
+
+// SummaryTransition records a change of a summary's status
+type SummaryTransition struct {
+	ID         string    `json:"id" gorm:"primarykey"`
+	SummaryID  string    `json:"summary_id" gorm:"index"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    string    `json:"actor_id"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EditSummaryDto is an edit of a summary's content. The expected version is the version the edit was made
+// on, it can also be given as an If-Match header.
+type EditSummaryDto struct {
+	Content         string `json:"content" validate:"required,min=10"`
+	EditMessage     string `json:"edit_message" validate:"required,min=5"`
+	ExpectedVersion int    `json:"expected_version" validate:"omitempty,min=1"`
+}
+
 type ResourceLinkDto struct {
 	URL         string `json:"url" validate:"required,url"`
 	Title       string `json:"title" validate:"required,min=3"`
 	Description string `json:"description" validate:"omitempty,min=10"`
 }
+
+// ModerationClaim is a moderator's lease on a summary in the moderation queue. Nobody else can moderate
+// the summary until the lease expires or is released.
+type ModerationClaim struct {
+	SummaryID   string    `json:"summary_id" gorm:"primarykey"`
+	ModeratorID string    `json:"moderator_id" gorm:"index"`
+	ClaimedAt   time.Time `json:"claimed_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// ModerationQueueItem is a summary waiting for a moderator
+type ModerationQueueItem struct {
+	Summary        Summary          `json:"summary"`
+	WaitingSeconds int64            `json:"waiting_seconds"`
+	Claim          *ModerationClaim `json:"claim,omitempty"`
+}
+
+type ModerationQueueResponse struct {
+	pagination.Page[ModerationQueueItem]
+	OldestWaitSeconds int64 `json:"oldest_wait_seconds"`
+}
+
+// ModeratorThroughput counts a moderator's votes on ai_reviewed summaries
+type ModeratorThroughput struct {
+	ModeratorID  string  `json:"moderator_id"`
+	Decisions    int64   `json:"decisions"`
+	Approved     int64   `json:"approved"`
+	Rejected     int64   `json:"rejected"`
+	DecisionsDay float64 `json:"decisions_per_day"`
+}
+
+type ModerationStatsResponse struct {
+	Since      time.Time             `json:"since"`
+	Moderators []ModeratorThroughput `json:"moderators"`
+}
+
+// SummaryAppeal contests a rejection. DecisionID is the transition that rejected the summary, or the
+// summary ID for rejections from before transitions were recorded, and each can only be appealed once.
+type SummaryAppeal struct {
+	ID                  string     `json:"id" gorm:"primarykey"`
+	SummaryID           string     `json:"summary_id" gorm:"index"`
+	DecisionID          string     `json:"decision_id" gorm:"uniqueIndex"`
+	AppellantID         string     `json:"appellant_id"`
+	Justification       string     `json:"justification"`
+	OriginalModeratorID string     `json:"original_moderator_id"`
+	Status              string     `json:"status" gorm:"index"`
+	ReviewerID          *string    `json:"reviewer_id,omitempty"`
+	ReviewNotes         string     `json:"review_notes,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	ResolvedAt          *time.Time `json:"resolved_at,omitempty"`
+}
+
+type FileAppealDto struct {
+	Justification string `json:"justification" validate:"required,min=20"`
+}
+
+type ResolveAppealDto struct {
+	Outcome string `json:"outcome" validate:"required,oneof=uphold overturn"`
+	Notes   string `json:"notes" validate:"required,min=5"`
+}
+
+// ModerationVote is one moderator's vote on an ai_reviewed summary. A summary is decided once a
+// quorum of votes agree, see SummariesUseCase.ModerateSummary.
+type ModerationVote struct {
+	ID          string    `json:"id" gorm:"primarykey"`
+	SummaryID   string    `json:"summary_id" gorm:"uniqueIndex:idx_vote_summary_moderator"`
+	ModeratorID string    `json:"moderator_id" gorm:"uniqueIndex:idx_vote_summary_moderator"`
+	Decision    string    `json:"decision"`
+	Notes       string    `json:"notes"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// VoteTally is the state of the vote on a summary after a moderator has voted
+type VoteTally struct {
+	SummaryID  string `json:"summary_id"`
+	Status     string `json:"status"`
+	Approvals  int    `json:"approvals"`
+	Rejections int    `json:"rejections"`
+	Quorum     int    `json:"quorum"`
+	PanelSize  int    `json:"panel_size"`
+}
+
+// EscalatedSummary is a summary the moderators could not agree on, with their votes
+type EscalatedSummary struct {
+	Summary Summary          `json:"summary"`
+	Votes   []ModerationVote `json:"votes"`
+}
+
+// SearchResult is a summary or summary request matching a search, depending on Type
+type SearchResult struct {
+	Type    string          `json:"type"`
+	Score   float64         `json:"score"`
+	Summary *Summary        `json:"summary,omitempty"`
+	Request *SummaryRequest `json:"request,omitempty"`
+}
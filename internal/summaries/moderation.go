@@ -0,0 +1,155 @@
+package summaries
+
+import (
+	"errors"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+const (
+	QueueSortAge      = "age"
+	QueueSortPriority = "priority"
+
+	// defaultClaimLease is how long a claim lasts when moderation.claim_lease is not configured
+	defaultClaimLease = 15 * time.Minute
+	// defaultStatsWindow is the throughput window when no since is given
+	defaultStatsWindow = 7 * 24 * time.Hour
+)
+
+var (
+	ErrAlreadyClaimed = errors.New("summary is claimed by another moderator")
+	ErrClaimNotHeld   = errors.New("summary is not claimed by you")
+	ErrNotInQueue     = errors.New("summary is not waiting for moderation")
+	ErrInvalidSort    = errors.New("sort must be age or priority")
+)
+
+func (uc *SummariesUseCase) claimLease() time.Duration {
+	if uc.config.Moderation.ClaimLease > 0 {
+		return uc.config.Moderation.ClaimLease
+	}
+	return defaultClaimLease
+}
+
+// GetModerationQueue lists the summaries waiting for a moderator with how long they have waited and who has claimed them
+func (uc *SummariesUseCase) GetModerationQueue(user utils.Principal, sort string, params pagination.Params) (ModerationQueueResponse, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{}); err != nil {
+		return ModerationQueueResponse{}, err
+	}
+
+	if sort == "" {
+		sort = QueueSortAge
+	}
+	if sort != QueueSortAge && sort != QueueSortPriority {
+		return ModerationQueueResponse{}, ErrInvalidSort
+	}
+
+	page, err := uc.repo.GetModerationQueue(sort, params)
+	if err != nil {
+		return ModerationQueueResponse{}, err
+	}
+
+	now := time.Now()
+	ids := make([]string, 0, len(page.Items))
+	for _, summary := range page.Items {
+		ids = append(ids, summary.ID)
+	}
+
+	claims, err := uc.repo.GetActiveClaims(ids, now)
+	if err != nil {
+		return ModerationQueueResponse{}, err
+	}
+	claimsBySummary := map[string]ModerationClaim{}
+	for _, claim := range claims {
+		claimsBySummary[claim.SummaryID] = claim
+	}
+
+	response := ModerationQueueResponse{Page: pagination.Page[ModerationQueueItem]{
+		Items:      make([]ModerationQueueItem, 0, len(page.Items)),
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	}}
+	for _, summary := range page.Items {
+		item := ModerationQueueItem{Summary: summary, WaitingSeconds: waitingSeconds(summary, now)}
+		if claim, ok := claimsBySummary[summary.ID]; ok {
+			item.Claim = &claim
+		}
+		response.Items = append(response.Items, item)
+	}
+
+	oldest, err := uc.repo.GetOldestQueuedAt()
+	if err != nil {
+		return ModerationQueueResponse{}, err
+	}
+	if oldest != nil {
+		response.OldestWaitSeconds = int64(now.Sub(*oldest).Seconds())
+	}
+
+	return response, nil
+}
+
+// waitingSeconds is how long the summary has been in the moderation queue
+func waitingSeconds(summary Summary, now time.Time) int64 {
+	queuedAt := summary.CreatedAt
+	if summary.ReviewReadyAt != nil {
+		queuedAt = *summary.ReviewReadyAt
+	}
+	return int64(now.Sub(queuedAt).Seconds())
+}
+
+// ClaimSummary leases a queued summary to the moderator. Claiming it again renews the lease.
+func (uc *SummariesUseCase) ClaimSummary(id string, user utils.Principal) (ModerationClaim, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{Type: permissions.ResourceSummary, ID: id}); err != nil {
+		return ModerationClaim{}, err
+	}
+
+	summary, err := uc.repo.GetSummaryByID(id)
+	if err != nil {
+		return ModerationClaim{}, err
+	}
+	if summary.Status != StatusAIReviewed {
+		return ModerationClaim{}, ErrNotInQueue
+	}
+
+	voted, err := uc.repo.HasVoted(id, user.UserID)
+	if err != nil {
+		return ModerationClaim{}, err
+	}
+	if voted {
+		return ModerationClaim{}, ErrAlreadyVoted
+	}
+
+	return uc.repo.ClaimSummary(id, user.UserID, uc.claimLease())
+}
+
+// ReleaseSummary hands a claimed summary back to the queue
+func (uc *SummariesUseCase) ReleaseSummary(id string, user utils.Principal) error {
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{Type: permissions.ResourceSummary, ID: id}); err != nil {
+		return err
+	}
+
+	return uc.repo.ReleaseClaim(id, user.UserID)
+}
+
+// GetModerationStats reports each moderator's votes since the given time
+func (uc *SummariesUseCase) GetModerationStats(user utils.Principal, since time.Time) (ModerationStatsResponse, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{}); err != nil {
+		return ModerationStatsResponse{}, err
+	}
+
+	throughput, err := uc.repo.GetModeratorThroughput(since)
+	if err != nil {
+		return ModerationStatsResponse{}, err
+	}
+
+	days := time.Since(since).Hours() / 24
+	for i := range throughput {
+		if days > 0 {
+			throughput[i].DecisionsDay = float64(throughput[i].Decisions) / days
+		}
+	}
+
+	return ModerationStatsResponse{Since: since, Moderators: throughput}, nil
+}
@@ -2,11 +2,13 @@ package summaries
 
 import (
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type SummariesRepository struct {
@@ -17,10 +19,34 @@ func NewSummariesRepository(db *gorm.DB) *SummariesRepository {
 	return &SummariesRepository{db: db}
 }
 
+// Transaction runs fn with a repository whose queries all run in one transaction, which is committed when
+// fn returns nil
+func (r *SummariesRepository) Transaction(fn func(repo *SummariesRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return fn(&SummariesRepository{db: tx})
+	})
+}
+
+// CreateSummary creates a summary together with version 1 of its edit history
 func (r *SummariesRepository) CreateSummary(summary Summary) (Summary, error) {
 	summary.ID = uuid.New().String()
-	result := r.db.Create(&summary)
-	return summary, result.Error
+	summary.CurrentVersion = 1
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&summary).Error; err != nil {
+			return err
+		}
+		return tx.Create(&SummaryEdit{
+			ID:          uuid.New().String(),
+			SummaryID:   summary.ID,
+			Content:     summary.Content,
+			EditedBy:    summary.UserID,
+			EditedAt:    summary.CreatedAt,
+			Version:     1,
+			EditMessage: InitialVersionMessage,
+			Status:      EditApplied,
+		}).Error
+	})
+	return summary, err
 }
 
 func (r *SummariesRepository) CreateSummaryRequest(req SummaryRequest) (SummaryRequest, error) {
@@ -29,79 +55,735 @@ func (r *SummariesRepository) CreateSummaryRequest(req SummaryRequest) (SummaryR
 	return req, result.Error
 }
 
-func (r *SummariesRepository) GetAllSummaries(dto models.PaginateDto) ([]Summary, error) {
+// GetAllSummaries returns a page of summaries, newest first
+func (r *SummariesRepository) GetAllSummaries(params pagination.Params) (pagination.Page[Summary], error) {
+	return pagination.Find(r.db.Model(&Summary{}), params, summaryCursor)
+}
+
+func summaryCursor(summary Summary) pagination.Cursor {
+	return pagination.Cursor{Time: summary.CreatedAt, ID: summary.ID}
+}
+
+// GetAllRequests returns a page of summary requests, newest first
+func (r *SummariesRepository) GetAllRequests(params pagination.Params) (pagination.Page[SummaryRequest], error) {
+	return pagination.Find(r.db.Model(&SummaryRequest{}), params, requestCursor)
+}
+
+func requestCursor(request SummaryRequest) pagination.Cursor {
+	return pagination.Cursor{Time: request.CreatedAt, ID: request.ID}
+}
+
+// GetSummariesByIDs returns the summaries with the given IDs, with their resource links, in no particular order
+func (r *SummariesRepository) GetSummariesByIDs(ids []string) ([]Summary, error) {
 	var summaries []Summary
-	result := r.db.Limit(dto.Limit).Offset(dto.Offset).Find(&summaries)
+	if len(ids) == 0 {
+		return summaries, nil
+	}
+	result := r.db.Preload("Resources").Where("id IN ?", ids).Find(&summaries)
 	return summaries, result.Error
 }
 
-func (r *SummariesRepository) GetAllRequests(dto models.PaginateDto) ([]SummaryRequest, error) {
+// GetRequestsByIDs returns the summary requests with the given IDs in no particular order
+func (r *SummariesRepository) GetRequestsByIDs(ids []string) ([]SummaryRequest, error) {
 	var requests []SummaryRequest
-	result := r.db.Limit(dto.Limit).Offset(dto.Offset).Find(&requests)
+	if len(ids) == 0 {
+		return requests, nil
+	}
+	result := r.db.Where("id IN ?", ids).Find(&requests)
 	return requests, result.Error
 }
 
+// FindSummariesInBatches calls fn with every summary, with its resource links, batchSize summaries at a time
+func (r *SummariesRepository) FindSummariesInBatches(batchSize int, fn func([]Summary) error) error {
+	var summaries []Summary
+	return r.db.Preload("Resources").FindInBatches(&summaries, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(summaries)
+	}).Error
+}
+
+// FindRequestsInBatches calls fn with every summary request, batchSize requests at a time
+func (r *SummariesRepository) FindRequestsInBatches(batchSize int, fn func([]SummaryRequest) error) error {
+	var requests []SummaryRequest
+	return r.db.FindInBatches(&requests, batchSize, func(tx *gorm.DB, batch int) error {
+		return fn(requests)
+	}).Error
+}
+
+func (r *SummariesRepository) GetSummaryRequestByID(id string) (SummaryRequest, error) {
+	var request SummaryRequest
+	result := r.db.First(&request, "id = ?", id)
+	if result.Error != nil {
+		return SummaryRequest{}, errors.New("summary request not found")
+	}
+	return request, nil
+}
+
+// GetSummaryRequestByPostID returns the request made for a post, or ErrSummaryRequestNotFound
+func (r *SummariesRepository) GetSummaryRequestByPostID(postID string) (SummaryRequest, error) {
+	var request SummaryRequest
+	result := r.db.First(&request, "post_id = ?", postID)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return SummaryRequest{}, ErrSummaryRequestNotFound
+	}
+	return request, result.Error
+}
+
+// DeleteSummaryRequest removes a summary request that could not be queued
+func (r *SummariesRepository) DeleteSummaryRequest(id string) error {
+	return r.db.Delete(&SummaryRequest{}, "id = ?", id).Error
+}
+
+func (r *SummariesRepository) UpdateRequestStatus(id string, status string) error {
+	return r.db.Model(&SummaryRequest{}).Where("id = ?", id).Update("status", status).Error
+}
+
+// GetApprovedSummariesByPostID returns the approved summaries of a post, best rated first
+func (r *SummariesRepository) GetApprovedSummariesByPostID(postID string) ([]Summary, error) {
+	var summaries []Summary
+	result := r.db.Preload("Resources").
+		Where("post_id = ? AND status = ?", postID, StatusApproved).
+		Order("rating desc, created_at desc").
+		Find(&summaries)
+	return summaries, result.Error
+}
+
 func (r *SummariesRepository) GetSummaryByID(id string) (Summary, error) {
 	var summary Summary
 	result := r.db.First(&summary, "id = ?", id)
 	if result.Error != nil {
-		return Summary{}, errors.New("summary not found")
+		return Summary{}, ErrSummaryNotFound
 	}
 	return summary, nil
 }
 
-func (r *SummariesRepository) UpdateSummaryRating(id string, rating float64) error {
-	result := r.db.Model(&Summary{}).Where("id = ?", id).Update("rating", rating)
-	return result.Error
+func (r *SummariesRepository) GetSummaryByRequestID(requestID string) (Summary, error) {
+	var summary Summary
+	result := r.db.First(&summary, "request_id = ?", requestID)
+	if result.Error != nil {
+		return Summary{}, ErrSummaryNotFound
+	}
+	return summary, nil
 }
 
-func (r *SummariesRepository) UpdateSummaryStatus(id string, status string, moderatorID string, notes string) error {
-	now := time.Now()
+// UpsertSummaryRating stores a user's rating, replacing any earlier vote by the same user,
+// and writes the recomputed aggregate back onto the summary
+func (r *SummariesRepository) UpsertSummaryRating(rating SummaryRating) (RatingAggregate, error) {
+	var aggregate RatingAggregate
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		rating.ID = uuid.New().String()
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "summary_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"rating", "updated_at"}),
+		}).Create(&rating).Error
+		if err != nil {
+			return err
+		}
+
+		aggregate, err = getRatingAggregate(tx, rating.SummaryID)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&Summary{}).Where("id = ?", rating.SummaryID).Updates(map[string]interface{}{
+			"rating":       aggregate.Mean,
+			"rating_count": aggregate.Count,
+		}).Error
+	})
+	return aggregate, err
+}
+
+func (r *SummariesRepository) GetRatingAggregate(summaryID string) (RatingAggregate, error) {
+	return getRatingAggregate(r.db, summaryID)
+}
+
+// GetAllSummaryRatings returns every rating of every summary
+func (r *SummariesRepository) GetAllSummaryRatings() ([]SummaryRating, error) {
+	var ratings []SummaryRating
+	result := r.db.Select("summary_id", "user_id", "rating").Find(&ratings)
+	return ratings, result.Error
+}
+
+func (r *SummariesRepository) UpdateHelpfulness(id string, status string, score float64, scoredAt time.Time) error {
 	return r.db.Model(&Summary{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":          status,
+		"helpfulness_status": status,
+		"helpfulness_score":  score,
+		"scored_at":          scoredAt,
+	}).Error
+}
+
+func getRatingAggregate(db *gorm.DB, summaryID string) (RatingAggregate, error) {
+	var rows []struct {
+		Rating int
+		Count  int64
+	}
+	err := db.Model(&SummaryRating{}).
+		Select("rating, COUNT(*) AS count").
+		Where("summary_id = ?", summaryID).
+		Group("rating").
+		Scan(&rows).Error
+	if err != nil {
+		return RatingAggregate{}, err
+	}
+
+	aggregate := RatingAggregate{Distribution: map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	var total int64
+	for _, row := range rows {
+		aggregate.Distribution[row.Rating] = row.Count
+		aggregate.Count += row.Count
+		total += int64(row.Rating) * row.Count
+	}
+	if aggregate.Count > 0 {
+		aggregate.Mean = float64(total) / float64(aggregate.Count)
+	}
+
+	return aggregate, nil
+}
+
+func (r *SummariesRepository) UpdateSummaryStatus(id string, from string, status string, moderatorID string, notes string) error {
+	now := time.Now()
+	return r.TransitionSummary(id, from, status, moderatorID, notes, map[string]interface{}{
 		"moderator_id":    moderatorID,
 		"moderated_at":    now,
 		"moderator_notes": notes,
-	}).Error
+	})
 }
 
 func (r *SummariesRepository) UpdateAIResponse(id string, aiResponse string) error {
-	return r.db.Model(&Summary{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"status":      StatusAIReviewed,
-		"ai_response": aiResponse,
-	}).Error
+	return r.TransitionSummary(id, StatusPending, StatusAIReviewed, ActorSummarizer, "AI summary completed", map[string]interface{}{
+		"ai_response":     aiResponse,
+		"review_ready_at": time.Now(),
+	})
+}
+
+// TransitionSummary moves a summary from one status to another, applying updates and recording the transition
+// in one transaction. The status is compared and swapped, so a summary that changed status in the meantime
+// is left untouched and an IllegalTransitionError is returned.
+func (r *SummariesRepository) TransitionSummary(id string, from string, to string, actorID string, reason string, updates map[string]interface{}) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		_, err := transitionSummary(tx, id, from, to, actorID, reason, updates)
+		return err
+	})
+}
+
+// transitionSummary is TransitionSummary inside a caller's transaction. It returns the recorded transition.
+func transitionSummary(tx *gorm.DB, id string, from string, to string, actorID string, reason string, updates map[string]interface{}) (SummaryTransition, error) {
+	values := map[string]interface{}{"status": to}
+	for column, value := range updates {
+		values[column] = value
+	}
+
+	result := tx.Model(&Summary{}).Where("id = ? AND status = ?", id, from).Updates(values)
+	if result.Error != nil {
+		return SummaryTransition{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		var current Summary
+		if err := tx.Select("status").First(&current, "id = ?", id).Error; err != nil {
+			return SummaryTransition{}, ErrSummaryNotFound
+		}
+		return SummaryTransition{}, &IllegalTransitionError{From: current.Status, To: to}
+	}
+
+	transition := SummaryTransition{
+		ID:         uuid.New().String(),
+		SummaryID:  id,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Reason:     reason,
+		CreatedAt:  time.Now(),
+	}
+	return transition, tx.Create(&transition).Error
+}
+
+// GetLatestTransitionTo returns the most recent transition of the summary into the status, or
+// ErrTransitionNotFound for summaries that reached it before transitions were recorded
+func (r *SummariesRepository) GetLatestTransitionTo(summaryID string, status string) (SummaryTransition, error) {
+	var transition SummaryTransition
+	result := r.db.Where("summary_id = ? AND to_status = ?", summaryID, status).Order("created_at desc").First(&transition)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return SummaryTransition{}, ErrTransitionNotFound
+	}
+	return transition, result.Error
+}
+
+func (r *SummariesRepository) GetSummaryTransitions(summaryID string) ([]SummaryTransition, error) {
+	var transitions []SummaryTransition
+	result := r.db.Where("summary_id = ?", summaryID).Order("created_at asc").Find(&transitions)
+	return transitions, result.Error
 }
 
-func (r *SummariesRepository) CreateSummaryEdit(edit SummaryEdit) error {
-	return r.db.Create(&edit).Error
+// CreateSummaryVersion stores an edit and makes its content the summary's current content. The summary
+// is only updated while it is still at the version before the edit, otherwise a VersionConflictError
+// with its current version is returned and nothing is written.
+func (r *SummariesRepository) CreateSummaryVersion(edit SummaryEdit) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := advanceSummaryVersion(tx, edit.SummaryID, edit.Content, edit.Version); err != nil {
+			return err
+		}
+		return tx.Create(&edit).Error
+	})
 }
 
+// advanceSummaryVersion sets a summary's content as the given version, provided the summary is still at
+// the version before it
+func advanceSummaryVersion(tx *gorm.DB, summaryID string, content string, version int) error {
+	result := tx.Model(&Summary{}).
+		Where("id = ? AND current_version = ?", summaryID, version-1).
+		Updates(map[string]interface{}{
+			"content":         content,
+			"current_version": version,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var current Summary
+		if err := tx.Select("current_version").First(&current, "id = ?", summaryID).Error; err != nil {
+			return ErrSummaryNotFound
+		}
+		return &VersionConflictError{Expected: version - 1, Current: current.CurrentVersion}
+	}
+	return nil
+}
+
+// GetSummaryEdits returns a summary's versions, newest first
 func (r *SummariesRepository) GetSummaryEdits(summaryID string) ([]SummaryEdit, error) {
 	var edits []SummaryEdit
-	result := r.db.Where("summary_id = ?", summaryID).Order("version desc").Find(&edits)
+	result := r.db.Where("summary_id = ? AND status = ?", summaryID, EditApplied).Order("version desc").Find(&edits)
 	return edits, result.Error
 }
 
+// GetSummaryEdit returns one version of a summary, or ErrVersionNotFound
+func (r *SummariesRepository) GetSummaryEdit(summaryID string, version int) (SummaryEdit, error) {
+	var edit SummaryEdit
+	result := r.db.Where("summary_id = ? AND version = ? AND status = ?", summaryID, version, EditApplied).Order("edited_at desc").First(&edit)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return SummaryEdit{}, ErrVersionNotFound
+	}
+	return edit, result.Error
+}
+
 func (r *SummariesRepository) AddResourceLink(link ResourceLink) error {
 	return r.db.Create(&link).Error
 }
 
+func (r *SummariesRepository) GetResourceLinkByID(id string) (ResourceLink, error) {
+	var link ResourceLink
+	result := r.db.First(&link, "id = ?", id)
+	return link, result.Error
+}
+
 func (r *SummariesRepository) RemoveResourceLink(id string) error {
 	return r.db.Delete(&ResourceLink{}, "id = ?", id).Error
 }
 
 func (r *SummariesRepository) GetSummaryWithResources(id string) (Summary, error) {
 	var summary Summary
-	result := r.db.Preload("Resources").Preload("EditHistory").First(&summary, "id = ?", id)
+	result := r.db.Preload("Resources").Preload("EditHistory", "status = ?", EditApplied).First(&summary, "id = ?", id)
 	if result.Error != nil {
-		return Summary{}, errors.New("summary not found")
+		return Summary{}, ErrSummaryNotFound
 	}
 	return summary, nil
 }
 
-func (r *SummariesRepository) UpdateSummaryContent(id string, content string, version int) error {
-	return r.db.Model(&Summary{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"content":         content,
-		"current_version": version,
-	}).Error
+// reviewReadyAt is when a summary entered the moderation queue. Summaries from before review_ready_at
+// was recorded fall back to their creation time.
+const reviewReadyAt = "COALESCE(review_ready_at, created_at)"
+
+// GetModerationQueue returns ai_reviewed summaries, oldest first, or for the priority sort the most rated
+// first since those are already being read
+func (r *SummariesRepository) GetModerationQueue(sort string, params pagination.Params) (pagination.Page[Summary], error) {
+	order := pagination.Order{Time: reviewReadyAt, Ascending: true}
+	if sort == QueueSortPriority {
+		order.Rank = "rating_count"
+	}
+	return pagination.FindOrdered(r.db.Model(&Summary{}).Where("status = ?", StatusAIReviewed), params, order, queueCursor)
+}
+
+// queueCursor is the position of a summary in the moderation queue, for either sort
+func queueCursor(summary Summary) pagination.Cursor {
+	queuedAt := summary.CreatedAt
+	if summary.ReviewReadyAt != nil {
+		queuedAt = *summary.ReviewReadyAt
+	}
+	return pagination.Cursor{Rank: float64(summary.RatingCount), Time: queuedAt, ID: summary.ID}
+}
+
+// GetOldestQueuedAt returns when the longest waiting ai_reviewed summary entered the queue
+func (r *SummariesRepository) GetOldestQueuedAt() (*time.Time, error) {
+	var summary Summary
+	result := r.db.Where("status = ?", StatusAIReviewed).Order(reviewReadyAt + " asc").Limit(1).Find(&summary)
+	if result.Error != nil || result.RowsAffected == 0 {
+		return nil, result.Error
+	}
+
+	queuedAt := summary.CreatedAt
+	if summary.ReviewReadyAt != nil {
+		queuedAt = *summary.ReviewReadyAt
+	}
+	return &queuedAt, nil
+}
+
+// GetActiveClaims returns the unexpired claims on the given summaries
+func (r *SummariesRepository) GetActiveClaims(summaryIDs []string, now time.Time) ([]ModerationClaim, error) {
+	var claims []ModerationClaim
+	if len(summaryIDs) == 0 {
+		return claims, nil
+	}
+	result := r.db.Where("summary_id IN ? AND expires_at > ?", summaryIDs, now).Find(&claims)
+	return claims, result.Error
+}
+
+// lockSummary reads the summary's status and locks its row until the end of the transaction
+func lockSummary(tx *gorm.DB, id string) (Summary, error) {
+	var summary Summary
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "status").First(&summary, "id = ?", id).Error; err != nil {
+		return Summary{}, ErrSummaryNotFound
+	}
+	return summary, nil
+}
+
+// ClaimSummary gives the moderator a lease on the summary. An expired claim is taken over and the moderator's
+// own claim is renewed; a live claim of another moderator returns ErrAlreadyClaimed. The summary row is
+// locked like in CastVote, so a claim can't slip in while a vote is being counted.
+func (r *SummariesRepository) ClaimSummary(summaryID string, moderatorID string, lease time.Duration) (ModerationClaim, error) {
+	now := time.Now()
+	claim := ModerationClaim{
+		SummaryID:   summaryID,
+		ModeratorID: moderatorID,
+		ClaimedAt:   now,
+		ExpiresAt:   now.Add(lease),
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockSummary(tx, summaryID); err != nil {
+			return err
+		}
+
+		result := tx.Model(&ModerationClaim{}).
+			Where("summary_id = ? AND (expires_at <= ? OR moderator_id = ?)", summaryID, now, moderatorID).
+			Updates(map[string]interface{}{
+				"moderator_id": claim.ModeratorID,
+				"claimed_at":   claim.ClaimedAt,
+				"expires_at":   claim.ExpiresAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			return nil
+		}
+
+		// Nothing was taken over, so either there is no claim yet or another moderator's claim is live
+		var existing int64
+		if err := tx.Model(&ModerationClaim{}).Where("summary_id = ?", summaryID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyClaimed
+		}
+		return tx.Create(&claim).Error
+	})
+	if err != nil {
+		return ModerationClaim{}, err
+	}
+
+	return claim, nil
+}
+
+// ReleaseClaim gives up the moderator's claim on the summary
+func (r *SummariesRepository) ReleaseClaim(summaryID string, moderatorID string) error {
+	result := r.db.Where("summary_id = ? AND moderator_id = ?", summaryID, moderatorID).Delete(&ModerationClaim{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrClaimNotHeld
+	}
+	return nil
+}
+
+// DeleteClaim removes any claim on the summary once it has left the queue
+func (r *SummariesRepository) DeleteClaim(summaryID string) error {
+	return r.db.Where("summary_id = ?", summaryID).Delete(&ModerationClaim{}).Error
+}
+
+// GetModeratorThroughput counts each moderator's approve and reject votes since the given time
+func (r *SummariesRepository) GetModeratorThroughput(since time.Time) ([]ModeratorThroughput, error) {
+	var throughput []ModeratorThroughput
+	result := r.db.Model(&ModerationVote{}).
+		Select("moderator_id, COUNT(*) AS decisions, "+
+			"SUM(CASE WHEN decision = ? THEN 1 ELSE 0 END) AS approved, "+
+			"SUM(CASE WHEN decision = ? THEN 1 ELSE 0 END) AS rejected", VoteApprove, VoteReject).
+		Where("created_at >= ?", since).
+		Group("moderator_id").
+		Order("decisions desc").
+		Scan(&throughput)
+	return throughput, result.Error
+}
+
+// CastVote records a moderator's vote on an ai_reviewed summary and, in the same transaction, moves the
+// summary to the status decided by the votes so far, if any. The summary row is locked so concurrent
+// votes and claims are handled one after the other; a live claim of another moderator returns
+// ErrAlreadyClaimed.
+func (r *SummariesRepository) CastVote(vote ModerationVote, quorum int, panelSize int) (VoteTally, error) {
+	tally := VoteTally{SummaryID: vote.SummaryID, Quorum: quorum, PanelSize: panelSize}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		summary, err := lockSummary(tx, vote.SummaryID)
+		if err != nil {
+			return err
+		}
+		if summary.Status != StatusAIReviewed {
+			return &IllegalTransitionError{From: summary.Status, To: voteStatus(vote.Decision)}
+		}
+
+		var claimed int64
+		err = tx.Model(&ModerationClaim{}).
+			Where("summary_id = ? AND expires_at > ? AND moderator_id <> ?", vote.SummaryID, time.Now(), vote.ModeratorID).
+			Count(&claimed).Error
+		if err != nil {
+			return err
+		}
+		if claimed > 0 {
+			return ErrAlreadyClaimed
+		}
+
+		var existing int64
+		if err := tx.Model(&ModerationVote{}).Where("summary_id = ? AND moderator_id = ?", vote.SummaryID, vote.ModeratorID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyVoted
+		}
+
+		vote.ID = uuid.New().String()
+		vote.CreatedAt = time.Now()
+		if err := tx.Create(&vote).Error; err != nil {
+			return err
+		}
+
+		var votes []ModerationVote
+		if err := tx.Where("summary_id = ?", vote.SummaryID).Find(&votes).Error; err != nil {
+			return err
+		}
+		tally.Approvals, tally.Rejections = countVotes(votes)
+
+		tally.Status = quorumOutcome(tally.Approvals, tally.Rejections, quorum, panelSize)
+		switch tally.Status {
+		case StatusApproved, StatusRejected:
+			_, err := transitionSummary(tx, vote.SummaryID, StatusAIReviewed, tally.Status, vote.ModeratorID, vote.Notes, map[string]interface{}{
+				"moderator_id":    vote.ModeratorID,
+				"moderated_at":    vote.CreatedAt,
+				"moderator_notes": vote.Notes,
+			})
+			return err
+		case StatusEscalated:
+			reason := fmt.Sprintf("no quorum: %d approve, %d reject", tally.Approvals, tally.Rejections)
+			_, err := transitionSummary(tx, vote.SummaryID, StatusAIReviewed, StatusEscalated, vote.ModeratorID, reason, nil)
+			return err
+		default:
+			tally.Status = StatusAIReviewed
+			return nil
+		}
+	})
+
+	return tally, err
+}
+
+// GetVotes returns the votes cast on a summary, oldest first
+func (r *SummariesRepository) GetVotes(summaryID string) ([]ModerationVote, error) {
+	var votes []ModerationVote
+	result := r.db.Where("summary_id = ?", summaryID).Order("created_at asc").Find(&votes)
+	return votes, result.Error
+}
+
+// GetVotesBySummaryIDs returns the votes cast on each of the summaries
+func (r *SummariesRepository) GetVotesBySummaryIDs(summaryIDs []string) ([]ModerationVote, error) {
+	var votes []ModerationVote
+	if len(summaryIDs) == 0 {
+		return votes, nil
+	}
+	result := r.db.Where("summary_id IN ?", summaryIDs).Order("created_at asc").Find(&votes)
+	return votes, result.Error
+}
+
+// HasVoted reports whether the moderator has voted on the summary
+func (r *SummariesRepository) HasVoted(summaryID string, moderatorID string) (bool, error) {
+	var count int64
+	result := r.db.Model(&ModerationVote{}).Where("summary_id = ? AND moderator_id = ?", summaryID, moderatorID).Count(&count)
+	return count > 0, result.Error
+}
+
+// GetEscalatedSummaries returns the summaries waiting for an admin decision, oldest first
+func (r *SummariesRepository) GetEscalatedSummaries(params pagination.Params) (pagination.Page[Summary], error) {
+	order := pagination.Order{Time: "updated_at", Ascending: true}
+	return pagination.FindOrdered(r.db.Model(&Summary{}).Where("status = ?", StatusEscalated), params, order, func(summary Summary) pagination.Cursor {
+		return pagination.Cursor{Time: summary.UpdatedAt, ID: summary.ID}
+	})
+}
+
+// CreateAppeal stores an appeal and moves the summary from rejected to appealed. A second appeal of the
+// same decision violates the unique decision ID and returns ErrAlreadyAppealed.
+func (r *SummariesRepository) CreateAppeal(appeal SummaryAppeal) (SummaryAppeal, error) {
+	appeal.ID = uuid.New().String()
+	appeal.Status = AppealPending
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&SummaryAppeal{}).Where("decision_id = ?", appeal.DecisionID).Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyAppealed
+		}
+
+		if err := tx.Create(&appeal).Error; err != nil {
+			return err
+		}
+
+		_, err := transitionSummary(tx, appeal.SummaryID, StatusRejected, StatusAppealed, appeal.AppellantID, appeal.Justification, nil)
+		return err
+	})
+
+	return appeal, err
+}
+
+// ResolveAppeal records the outcome of a pending appeal and moves the summary to approved when the
+// rejection is overturned or back to rejected when it is upheld
+func (r *SummariesRepository) ResolveAppeal(appealID string, outcome string, reviewerID string, notes string) (SummaryAppeal, error) {
+	var appeal SummaryAppeal
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&SummaryAppeal{}).Where("id = ? AND status = ?", appealID, AppealPending).Updates(map[string]interface{}{
+			"status":       outcome,
+			"reviewer_id":  reviewerID,
+			"review_notes": notes,
+			"resolved_at":  now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAppealNotPending
+		}
+
+		if err := tx.First(&appeal, "id = ?", appealID).Error; err != nil {
+			return err
+		}
+
+		to := StatusRejected
+		if outcome == AppealOverturned {
+			to = StatusApproved
+		}
+		_, err := transitionSummary(tx, appeal.SummaryID, StatusAppealed, to, reviewerID, notes, map[string]interface{}{
+			"moderator_id":    reviewerID,
+			"moderated_at":    now,
+			"moderator_notes": notes,
+		})
+		return err
+	})
+
+	return appeal, err
+}
+
+func (r *SummariesRepository) GetAppealByID(id string) (SummaryAppeal, error) {
+	var appeal SummaryAppeal
+	result := r.db.First(&appeal, "id = ?", id)
+	if result.Error != nil {
+		return SummaryAppeal{}, ErrAppealNotFound
+	}
+	return appeal, nil
+}
+
+// GetAppealsBySummaryID returns every appeal of a summary, oldest first
+func (r *SummariesRepository) GetAppealsBySummaryID(summaryID string) ([]SummaryAppeal, error) {
+	var appeals []SummaryAppeal
+	result := r.db.Where("summary_id = ?", summaryID).Order("created_at asc").Find(&appeals)
+	return appeals, result.Error
+}
+
+// GetPendingAppeals returns the appeals waiting for a reviewer, oldest first
+func (r *SummariesRepository) GetPendingAppeals(params pagination.Params) (pagination.Page[SummaryAppeal], error) {
+	return pagination.FindOrdered(r.db.Model(&SummaryAppeal{}).Where("status = ?", AppealPending), params, pagination.OldestFirst, func(appeal SummaryAppeal) pagination.Cursor {
+		return pagination.Cursor{Time: appeal.CreatedAt, ID: appeal.ID}
+	})
+}
+
+// CreateSuggestion stores an edit suggested by a user
+func (r *SummariesRepository) CreateSuggestion(suggestion SummaryEdit) error {
+	return r.db.Create(&suggestion).Error
+}
+
+// GetSuggestionByID returns a suggested edit, whether it is pending or was already reviewed
+func (r *SummariesRepository) GetSuggestionByID(id string) (SummaryEdit, error) {
+	var suggestion SummaryEdit
+	result := r.db.First(&suggestion, "id = ? AND base_version > 0", id)
+	if result.Error != nil {
+		return SummaryEdit{}, ErrSuggestionNotFound
+	}
+	return suggestion, nil
+}
+
+// GetPendingSuggestions returns the suggestions waiting for a moderator, oldest first, optionally only
+// those of one summary
+func (r *SummariesRepository) GetPendingSuggestions(summaryID string, params pagination.Params) (pagination.Page[SummaryEdit], error) {
+	query := r.db.Model(&SummaryEdit{}).Where("status = ?", EditPending)
+	if summaryID != "" {
+		query = query.Where("summary_id = ?", summaryID)
+	}
+	order := pagination.Order{Time: "edited_at", Ascending: true}
+	return pagination.FindOrdered(query, params, order, func(suggestion SummaryEdit) pagination.Cursor {
+		return pagination.Cursor{Time: suggestion.EditedAt, ID: suggestion.ID}
+	})
+}
+
+// AcceptSuggestion applies a pending suggestion as the given version of its summary, in one transaction
+// with the compare-and-swap on the summary's version
+func (r *SummariesRepository) AcceptSuggestion(id string, version int, reviewerID string) (SummaryEdit, error) {
+	var suggestion SummaryEdit
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&suggestion, "id = ?", id).Error; err != nil {
+			return ErrSuggestionNotFound
+		}
+
+		if err := advanceSummaryVersion(tx, suggestion.SummaryID, suggestion.Content, version); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		result := tx.Model(&SummaryEdit{}).Where("id = ? AND status = ?", id, EditPending).Updates(map[string]interface{}{
+			"status":      EditApplied,
+			"version":     version,
+			"reviewer_id": reviewerID,
+			"reviewed_at": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSuggestionNotPending
+		}
+
+		return tx.First(&suggestion, "id = ?", id).Error
+	})
+	return suggestion, err
+}
+
+// DeclineSuggestion marks a pending suggestion as declined with the moderator's reason
+func (r *SummariesRepository) DeclineSuggestion(id string, reviewerID string, reason string) (SummaryEdit, error) {
+	result := r.db.Model(&SummaryEdit{}).Where("id = ? AND status = ?", id, EditPending).Updates(map[string]interface{}{
+		"status":       EditDeclined,
+		"reviewer_id":  reviewerID,
+		"review_notes": reason,
+		"reviewed_at":  time.Now(),
+	})
+	if result.Error != nil {
+		return SummaryEdit{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return SummaryEdit{}, ErrSuggestionNotPending
+	}
+	return r.GetSuggestionByID(id)
 }
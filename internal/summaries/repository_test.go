@@ -0,0 +1,200 @@
+package summaries
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+)
+
+// newTestRepository returns a repository on a new in-memory SQLite database with every migration applied
+func newTestRepository(t *testing.T) *SummariesRepository {
+	t.Helper()
+
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrations, err := Migrations(db.Dialect(database))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate.New(database, MigrationsName, migrations).Up(); err != nil {
+		t.Fatal(err)
+	}
+	auditMigrations, err := audit.Migrations(db.Dialect(database))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate.New(database, audit.MigrationsName, auditMigrations).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewSummariesRepository(database)
+}
+
+func createTestSummary(t *testing.T, repo *SummariesRepository, status string) Summary {
+	t.Helper()
+	summary, err := repo.CreateSummary(Summary{
+		RequestID: "request-1",
+		Content:   "The post",
+		Summary:   "A summary of the post",
+		UserID:    "author",
+		Status:    status,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return summary
+}
+
+func TestCastVoteRespectsClaims(t *testing.T) {
+	repo := newTestRepository(t)
+	summary := createTestSummary(t, repo, StatusAIReviewed)
+
+	if _, err := repo.ClaimSummary(summary.ID, "moderator-a", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ClaimSummary(summary.ID, "moderator-b", time.Minute); !errors.Is(err, ErrAlreadyClaimed) {
+		t.Fatalf("claiming a claimed summary returned %v, want ErrAlreadyClaimed", err)
+	}
+
+	vote := ModerationVote{SummaryID: summary.ID, ModeratorID: "moderator-b", Decision: VoteApprove}
+	if _, err := repo.CastVote(vote, 2, 3); !errors.Is(err, ErrAlreadyClaimed) {
+		t.Fatalf("voting on another moderator's claim returned %v, want ErrAlreadyClaimed", err)
+	}
+
+	vote.ModeratorID = "moderator-a"
+	tally, err := repo.CastVote(vote, 2, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tally.Approvals != 1 || tally.Status != StatusAIReviewed {
+		t.Errorf("tally = %+v", tally)
+	}
+}
+
+func TestExpiredClaimsAreTakenOver(t *testing.T) {
+	repo := newTestRepository(t)
+	summary := createTestSummary(t, repo, StatusAIReviewed)
+
+	if _, err := repo.ClaimSummary(summary.ID, "moderator-a", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	vote := ModerationVote{SummaryID: summary.ID, ModeratorID: "moderator-b", Decision: VoteReject}
+	if _, err := repo.CastVote(vote, 2, 3); err != nil {
+		t.Fatalf("voting on an expired claim returned %v", err)
+	}
+
+	claim, err := repo.ClaimSummary(summary.ID, "moderator-c", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claim.ModeratorID != "moderator-c" {
+		t.Errorf("claim = %+v", claim)
+	}
+}
+
+func TestGetLatestTransitionTo(t *testing.T) {
+	repo := newTestRepository(t)
+	summary := createTestSummary(t, repo, StatusRejected)
+
+	if _, err := repo.GetLatestTransitionTo(summary.ID, StatusRejected); !errors.Is(err, ErrTransitionNotFound) {
+		t.Fatalf("summary without transitions returned %v, want ErrTransitionNotFound", err)
+	}
+
+	appeal, err := repo.CreateAppeal(SummaryAppeal{SummaryID: summary.ID, DecisionID: summary.ID, AppellantID: "author"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ResolveAppeal(appeal.ID, AppealUpheld, "reviewer", "The rejection stands"); err != nil {
+		t.Fatal(err)
+	}
+
+	transition, err := repo.GetLatestTransitionTo(summary.ID, StatusRejected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transition.FromStatus != StatusAppealed || transition.ActorID != "reviewer" {
+		t.Errorf("transition = %+v, want the upheld appeal", transition)
+	}
+}
+
+func TestCastVoteOnDecidedSummary(t *testing.T) {
+	repo := newTestRepository(t)
+	summary := createTestSummary(t, repo, StatusApproved)
+
+	for decision, want := range map[string]string{VoteApprove: StatusApproved, VoteReject: StatusRejected} {
+		vote := ModerationVote{SummaryID: summary.ID, ModeratorID: "moderator-a", Decision: decision}
+		_, err := repo.CastVote(vote, 1, 1)
+
+		var illegal *IllegalTransitionError
+		if !errors.As(err, &illegal) {
+			t.Fatalf("%s vote on an approved summary returned %v, want an IllegalTransitionError", decision, err)
+		}
+		if illegal.From != StatusApproved || illegal.To != want {
+			t.Errorf("%s vote: error = %+v, want %s to %s", decision, illegal, StatusApproved, want)
+		}
+	}
+}
+
+func TestGetModerationQueuePages(t *testing.T) {
+	repo := newTestRepository(t)
+
+	// Summaries queued one after the other, the last with the most ratings; the first has no
+	// review_ready_at and is queued at its creation time
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	var ids []string
+	for i, ratings := range []int64{0, 1, 5} {
+		summary := createTestSummary(t, repo, StatusAIReviewed)
+		updates := map[string]interface{}{"created_at": start, "rating_count": ratings}
+		if i > 0 {
+			updates["review_ready_at"] = start.Add(time.Duration(i) * time.Minute)
+		}
+		if err := repo.db.Model(&Summary{}).Where("id = ?", summary.ID).Updates(updates).Error; err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, summary.ID)
+	}
+
+	for sort, want := range map[string][]string{
+		QueueSortAge:      {ids[0], ids[1], ids[2]},
+		QueueSortPriority: {ids[2], ids[1], ids[0]},
+	} {
+		var got []string
+		params := pagination.Params{Limit: 1}
+		for len(got) <= len(want) {
+			page, err := repo.GetModerationQueue(sort, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, summary := range page.Items {
+				got = append(got, summary.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor, err := pagination.DecodeCursor(page.NextCursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params.After = &cursor
+		}
+
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s queue = %v, want %v", sort, got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package summaries
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	customMiddleware "github.com/mwelwankuta/facebook-notes/pkg/middleware"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+)
+
+// RegisterRoutes registers the summaries service's routes. authenticate verifies the access token of the
+// protected routes; the use case checks the caller's permissions, except for the admin routes which
+// policy gates.
+func RegisterRoutes(e *echo.Echo, summariesHandler *SummariesHandler, auditHandler *audit.Handler, authenticate echo.MiddlewareFunc, policy *permissions.Policy) {
+	// Protected routes requiring authentication
+	protected := e.Group("")
+	protected.Use(authenticate)
+
+	// User routes
+	protected.POST("/api/summaries/requests", summariesHandler.CreateSummaryRequestHandler)
+	protected.POST("/api/summaries/:id/rate", summariesHandler.RateSummaryHandler)
+	protected.POST("/api/summaries/:id/appeals", summariesHandler.FileAppealHandler)
+	protected.POST("/api/summaries/:id/suggestions", summariesHandler.SuggestEditHandler)
+
+	// Moderator routes
+	protected.POST("/api/summaries/:id/moderate", summariesHandler.ModerateSummaryHandler)
+	protected.GET("/api/summaries/:id/votes", summariesHandler.GetSummaryVotesHandler)
+	protected.PUT("/api/summaries/:id/edit", summariesHandler.EditSummaryHandler)
+	protected.POST("/api/summaries/:id/revert/:v", summariesHandler.RevertSummaryHandler)
+	protected.POST("/api/summaries/:id/resources", summariesHandler.AddResourceLinkHandler)
+	protected.DELETE("/api/summaries/:id/resources/:linkId", summariesHandler.RemoveResourceLinkHandler)
+
+	// Moderation queue routes
+	protected.GET("/api/moderation/queue", summariesHandler.GetModerationQueueHandler)
+	protected.POST("/api/moderation/queue/:id/claim", summariesHandler.ClaimSummaryHandler)
+	protected.DELETE("/api/moderation/queue/:id/claim", summariesHandler.ReleaseSummaryHandler)
+	protected.GET("/api/moderation/stats", summariesHandler.GetModerationStatsHandler)
+	protected.GET("/api/moderation/appeals", summariesHandler.GetPendingAppealsHandler)
+	protected.GET("/api/moderation/escalations", summariesHandler.GetEscalatedSummariesHandler)
+	protected.POST("/api/moderation/appeals/:id/resolve", summariesHandler.ResolveAppealHandler)
+	protected.GET("/api/moderation/suggestions", summariesHandler.GetPendingSuggestionsHandler)
+	protected.POST("/api/moderation/suggestions/:id/accept", summariesHandler.AcceptSuggestionHandler)
+	protected.POST("/api/moderation/suggestions/:id/decline", summariesHandler.DeclineSuggestionHandler)
+
+	// Admin routes
+	admin := protected.Group("/api/admin")
+	admin.Use(customMiddleware.RequirePermission(policy, permissions.AuditRead))
+	admin.GET("/audit", auditHandler.QueryHandler)
+	admin.GET("/audit/verify", auditHandler.VerifyHandler)
+
+	// Public routes
+	e.GET("/api/summaries", summariesHandler.GetAllSummariesHandler)
+	e.GET("/api/summaries/requests", summariesHandler.GetAllRequestsHandler)
+	e.GET("/api/summaries/by-post", summariesHandler.GetSummariesByPostHandler)
+	e.GET("/api/summaries/search", summariesHandler.SearchHandler)
+	e.GET("/api/summaries/:id", summariesHandler.GetSummaryByIDHandler)
+	e.GET("/api/summaries/:id/transitions", summariesHandler.GetSummaryTransitionsHandler)
+	e.GET("/api/summaries/:id/versions", summariesHandler.GetSummaryVersionsHandler)
+	e.GET("/api/summaries/:id/versions/:v", summariesHandler.GetSummaryVersionHandler)
+	e.GET("/api/summaries/:id/diff", summariesHandler.DiffSummaryVersionsHandler)
+	e.GET("/api/summaries/:id/appeals", summariesHandler.GetSummaryAppealsHandler)
+
+	// Facebook webhooks, authenticated by the verify token and the payload signature
+	e.GET("/api/webhooks/facebook", summariesHandler.VerifyWebhookHandler)
+	e.POST("/api/webhooks/facebook", summariesHandler.ReceiveWebhookHandler)
+}
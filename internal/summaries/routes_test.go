@@ -0,0 +1,124 @@
+package summaries
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/jwks"
+	customMiddleware "github.com/mwelwankuta/facebook-notes/pkg/middleware"
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/tokens"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+func newTestKeySet(t *testing.T, kid string) *jwks.KeySet {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keySet, err := jwks.NewKeySet([]jwks.SigningKey{{Kid: kid, PrivateKey: privateKey}}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keySet
+}
+
+// testServer is the summaries service's routes on the test use case. Tokens are verified with keys, the
+// keyset the auth service would sign them with.
+type testServer struct {
+	e      *echo.Echo
+	uc     *SummariesUseCase
+	repo   *SummariesRepository
+	keys   *jwks.KeySet
+	tokens *tokens.Store
+}
+
+func newTestServer(t *testing.T) testServer {
+	t.Helper()
+	uc, repo := newTestUseCase(t, 1, 1)
+	server := testServer{e: echo.New(), uc: uc, repo: repo, keys: newTestKeySet(t, "test"), tokens: tokens.NewStore(uc.redis)}
+
+	authenticate := customMiddleware.Authenticate(customMiddleware.AuthConfig{Keys: server.keys.Keyfunc, Tokens: server.tokens})
+	RegisterRoutes(server.e, NewSummariesHandler(*uc, ""), audit.NewHandler(uc.audit), authenticate, uc.policy)
+	return server
+}
+
+// mint signs an access token for a user with the role, valid until expiresAt
+func mint(t *testing.T, signer utils.TokenSigner, role string, jti string, expiresAt time.Time) string {
+	t.Helper()
+	token, err := utils.GenerateJwtToken(signer, models.User{ID: role + "-1", Role: role}, jti, 0, expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func (s testServer) serve(method string, path string, token string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoutes(t *testing.T) {
+	server := newTestServer(t)
+	summary := createTestSummary(t, server.repo, StatusAIReviewed)
+
+	valid := time.Now().Add(time.Minute)
+	userToken := mint(t, server.keys, models.RoleUser, "user-jti", valid)
+	moderatorToken := mint(t, server.keys, models.RoleModerator, "moderator-jti", valid)
+	adminToken := mint(t, server.keys, models.RoleAdmin, "admin-jti", valid)
+	revokedToken := mint(t, server.keys, models.RoleUser, "revoked-jti", valid)
+	if err := server.tokens.RevokeAccessToken(context.Background(), "revoked-jti", valid); err != nil {
+		t.Fatal(err)
+	}
+
+	rate := "/api/summaries/" + summary.ID + "/rate"
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		body   string
+		status int
+	}{
+		{"public listing", http.MethodGet, "/api/summaries", "", "", http.StatusOK},
+		{"public summary", http.MethodGet, "/api/summaries/" + summary.ID, "", "", http.StatusOK},
+		{"public summary with a bad token", http.MethodGet, "/api/summaries/" + summary.ID, "not-a-token", "", http.StatusOK},
+		{"protected without a token", http.MethodPost, rate, "", `{"rating":4}`, http.StatusUnauthorized},
+		{"protected with a valid token", http.MethodPost, rate, userToken, `{"rating":4}`, http.StatusOK},
+		{"protected with an expired token", http.MethodPost, rate, mint(t, server.keys, models.RoleUser, "expired-jti", time.Now().Add(-time.Minute)), `{"rating":4}`, http.StatusUnauthorized},
+		{"protected with a revoked token", http.MethodPost, rate, revokedToken, `{"rating":4}`, http.StatusUnauthorized},
+		{"protected with an unknown kid", http.MethodPost, rate, mint(t, newTestKeySet(t, "other"), models.RoleUser, "user-jti", valid), `{"rating":4}`, http.StatusUnauthorized},
+		// A role the policy doesn't know has no permissions
+		{"rating with a role without summary:rate", http.MethodPost, rate, mint(t, server.keys, "guest", "guest-jti", valid), `{"rating":4}`, http.StatusForbidden},
+		{"request with a role without summary:request", http.MethodPost, "/api/summaries/requests", mint(t, server.keys, "guest", "guest-jti", valid), `{"content":"A post to summarize","metadata":"{}"}`, http.StatusForbidden},
+		{"moderation as a user", http.MethodPost, "/api/summaries/" + summary.ID + "/moderate", userToken, `{"action":"approve"}`, http.StatusForbidden},
+		{"moderation queue as a user", http.MethodGet, "/api/moderation/queue", userToken, "", http.StatusForbidden},
+		{"moderation queue as a moderator", http.MethodGet, "/api/moderation/queue", moderatorToken, "", http.StatusOK},
+		{"moderation as a moderator", http.MethodPost, "/api/summaries/" + summary.ID + "/moderate", moderatorToken, `{"action":"approve"}`, http.StatusOK},
+		{"audit log as a moderator", http.MethodGet, "/api/admin/audit", moderatorToken, "", http.StatusForbidden},
+		{"audit log as an admin", http.MethodGet, "/api/admin/audit", adminToken, "", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := server.serve(test.method, test.path, test.token, test.body)
+			if rec.Code != test.status {
+				t.Errorf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+		})
+	}
+}
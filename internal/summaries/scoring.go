@@ -0,0 +1,48 @@
+package summaries
+
+import (
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/scoring"
+)
+
+// HelpfulnessScorer rescores every rated summary from the full user×summary rating matrix
+type HelpfulnessScorer struct {
+	repo   SummariesRepository
+	params scoring.Params
+}
+
+func NewHelpfulnessScorer(repo SummariesRepository, params scoring.Params) *HelpfulnessScorer {
+	return &HelpfulnessScorer{
+		repo:   repo,
+		params: params,
+	}
+}
+
+// Run scores all ratings and writes each summary's helpfulness status and score back to the database
+func (s *HelpfulnessScorer) Run() (scoring.Result, error) {
+	summaryRatings, err := s.repo.GetAllSummaryRatings()
+	if err != nil {
+		return scoring.Result{}, err
+	}
+
+	ratings := make([]scoring.Rating, len(summaryRatings))
+	for i, rating := range summaryRatings {
+		ratings[i] = scoring.Rating{
+			RaterID: rating.UserID,
+			NoteID:  rating.SummaryID,
+			Value:   scoring.StarsToValue(rating.Rating),
+		}
+	}
+
+	result := scoring.Score(ratings, s.params)
+
+	scoredAt := time.Now()
+	for _, note := range result.Notes {
+		if err := s.repo.UpdateHelpfulness(note.NoteID, note.Status, note.Intercept, scoredAt); err != nil {
+			return result, err
+		}
+	}
+
+	return result, nil
+}
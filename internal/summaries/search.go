@@ -0,0 +1,305 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/search"
+	"gorm.io/gorm"
+)
+
+const (
+	// SearchIndexDatabase searches with the full-text search of the database, SearchIndexMemory with an
+	// index kept in memory
+	SearchIndexDatabase = "database"
+	SearchIndexMemory   = "memory"
+
+	SearchKindSummary = "summary"
+	SearchKindRequest = "request"
+
+	// DefaultSearchRefreshInterval is how often a memory index is rebuilt from the database when
+	// search.refresh_interval is not configured
+	DefaultSearchRefreshInterval = time.Minute
+
+	// searchRebuildBatchSize is how many rows are read at a time when rebuilding the index
+	searchRebuildBatchSize = 500
+)
+
+var ErrInvalidSearchKind = errors.New("type must be summary or request")
+
+// NewSearchIndex returns the search index selected by the config. The full-text search of the database is
+// used unless the memory index is configured or the database has none, such as SQLite.
+func NewSearchIndex(cfg config.Config, database *gorm.DB) search.Index {
+	fullText, ok := fullTextDialects[db.Dialect(database)]
+	if cfg.Search.Index == SearchIndexMemory || !ok {
+		return search.NewMemoryIndex()
+	}
+	return NewSQLSearchIndex(database, fullText)
+}
+
+// FullText is how a database matches and ranks text columns. Both return an SQL expression taking the
+// search text as its single argument.
+type FullText struct {
+	Match func(columns ...string) string
+	Score func(columns ...string) string
+}
+
+// fullTextDialects are the databases that have full-text search. The migrations create the indexes the
+// expressions use.
+var fullTextDialects = map[string]FullText{
+	db.DialectMySQL: {
+		Match: mysqlMatch,
+		Score: mysqlMatch,
+	},
+	db.DialectPostgres: {
+		Match: func(columns ...string) string {
+			return postgresDocument(columns) + " @@ plainto_tsquery('simple', ?)"
+		},
+		Score: func(columns ...string) string {
+			return "ts_rank(" + postgresDocument(columns) + ", plainto_tsquery('simple', ?))"
+		},
+	},
+}
+
+func mysqlMatch(columns ...string) string {
+	return "MATCH(" + strings.Join(columns, ", ") + ") AGAINST (? IN NATURAL LANGUAGE MODE)"
+}
+
+// postgresDocument must stay identical to the expressions of the GIN indexes, otherwise they are not used
+func postgresDocument(columns []string) string {
+	parts := make([]string, len(columns))
+	for i, column := range columns {
+		parts[i] = "coalesce(" + column + ", '')"
+	}
+	return "to_tsvector('simple', " + strings.Join(parts, " || ' ' || ") + ")"
+}
+
+// SQLSearchIndex searches the summaries tables with the full-text search of the database. The tables are
+// the index, so indexing and replacing documents are no-ops.
+type SQLSearchIndex struct {
+	db       *gorm.DB
+	fullText FullText
+}
+
+func NewSQLSearchIndex(db *gorm.DB, fullText FullText) *SQLSearchIndex {
+	return &SQLSearchIndex{db: db, fullText: fullText}
+}
+
+func (s *SQLSearchIndex) Index(ctx context.Context, docs ...search.Document) error {
+	return nil
+}
+
+func (s *SQLSearchIndex) Replace(ctx context.Context, docs ...search.Document) error {
+	return nil
+}
+
+// Search ranks summaries by the relevance of their content and summary plus their best matching
+// resource link title, and requests by the relevance of their content
+func (s *SQLSearchIndex) Search(ctx context.Context, query search.Query, params pagination.Params) (pagination.Page[search.Hit], error) {
+	if len(search.Tokenize(query.Text)) == 0 {
+		return pagination.Page[search.Hit]{}, search.ErrEmptyQuery
+	}
+
+	var filtered func() *gorm.DB
+	var score string
+	var scoreArgs []interface{}
+
+	switch query.Kind {
+	case SearchKindSummary:
+		summaryColumns := []string{"summaries.content", "summaries.summary"}
+		linkScore := "(SELECT MAX(" + s.fullText.Score("resource_links.title") + ") FROM resource_links WHERE resource_links.summary_id = summaries.id)"
+		score = s.fullText.Score(summaryColumns...) + " + COALESCE(" + linkScore + ", 0)"
+		scoreArgs = []interface{}{query.Text, query.Text}
+
+		filtered = func() *gorm.DB {
+			db := s.db.WithContext(ctx).Table("summaries").
+				Where("("+s.fullText.Match(summaryColumns...)+" OR EXISTS (SELECT 1 FROM resource_links WHERE resource_links.summary_id = summaries.id AND "+s.fullText.Match("resource_links.title")+"))", query.Text, query.Text)
+			if query.Verified != nil {
+				db = db.Where("summaries.is_verified = ?", *query.Verified)
+			}
+			return filterSearch(db, "summaries", query)
+		}
+	case SearchKindRequest:
+		score = s.fullText.Score("summary_requests.content")
+		scoreArgs = []interface{}{query.Text}
+
+		filtered = func() *gorm.DB {
+			db := s.db.WithContext(ctx).Table("summary_requests").Where(s.fullText.Match("summary_requests.content"), query.Text)
+			return filterSearch(db, "summary_requests", query)
+		}
+	default:
+		return pagination.Page[search.Hit]{}, ErrInvalidSearchKind
+	}
+
+	// The score is computed in a subquery so the cursor condition can compare it by name
+	hits := s.db.WithContext(ctx).Table("(?) AS hits", filtered().Select("id, created_at, "+score+" AS score", scoreArgs...))
+	page, err := pagination.FindOrdered(hits, params, pagination.Order{Rank: "score", Time: "created_at"}, search.Hit.Cursor)
+	if err != nil {
+		return pagination.Page[search.Hit]{}, err
+	}
+	for i := range page.Items {
+		page.Items[i].Kind = query.Kind
+	}
+
+	return page, nil
+}
+
+// filterSearch applies the filters every searchable table has
+func filterSearch(db *gorm.DB, table string, query search.Query) *gorm.DB {
+	if query.Status != "" {
+		db = db.Where(table+".status = ?", query.Status)
+	}
+	if query.AuthorID != "" {
+		db = db.Where(table+".user_id = ?", query.AuthorID)
+	}
+	if !query.From.IsZero() {
+		db = db.Where(table+".created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where(table+".created_at < ?", query.To)
+	}
+	return db
+}
+
+// summaryDocument is the search document of a summary, its resource links must be loaded
+func summaryDocument(summary Summary) search.Document {
+	fields := []string{summary.Content, summary.Summary}
+	for _, link := range summary.Resources {
+		fields = append(fields, link.Title)
+	}
+
+	return search.Document{
+		Kind:      SearchKindSummary,
+		ID:        summary.ID,
+		Fields:    fields,
+		Status:    summary.Status,
+		AuthorID:  summary.UserID,
+		Verified:  summary.IsVerified,
+		CreatedAt: summary.CreatedAt,
+	}
+}
+
+func requestDocument(request SummaryRequest) search.Document {
+	return search.Document{
+		Kind:      SearchKindRequest,
+		ID:        request.ID,
+		Fields:    []string{request.Content},
+		Status:    request.Status,
+		AuthorID:  request.UserID,
+		CreatedAt: request.CreatedAt,
+	}
+}
+
+// indexSummary refreshes a summary in the search index. Failures are only logged, the next rebuild of
+// the index picks the change up.
+func (uc *SummariesUseCase) indexSummary(ctx context.Context, id string) {
+	summary, err := uc.repo.GetSummaryWithResources(id)
+	if err != nil {
+		log.Printf("could not index summary %s: %v", id, err)
+		return
+	}
+	if err := uc.search.Index(ctx, summaryDocument(summary)); err != nil {
+		log.Printf("could not index summary %s: %v", id, err)
+	}
+}
+
+// indexRequest refreshes a summary request in the search index
+func (uc *SummariesUseCase) indexRequest(ctx context.Context, id string) {
+	request, err := uc.repo.GetSummaryRequestByID(id)
+	if err != nil {
+		log.Printf("could not index summary request %s: %v", id, err)
+		return
+	}
+	if err := uc.search.Index(ctx, requestDocument(request)); err != nil {
+		log.Printf("could not index summary request %s: %v", id, err)
+	}
+}
+
+// RebuildSearchIndex replaces the search index with every summary and summary request, which also drops
+// deleted rows. A memory index is rebuilt periodically because summaries also change in other processes,
+// such as the summaries-worker.
+func (uc *SummariesUseCase) RebuildSearchIndex(ctx context.Context) error {
+	var docs []search.Document
+	err := uc.repo.FindSummariesInBatches(searchRebuildBatchSize, func(summaries []Summary) error {
+		for _, summary := range summaries {
+			docs = append(docs, summaryDocument(summary))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = uc.repo.FindRequestsInBatches(searchRebuildBatchSize, func(requests []SummaryRequest) error {
+		for _, request := range requests {
+			docs = append(docs, requestDocument(request))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return uc.search.Replace(ctx, docs...)
+}
+
+// Search ranks summaries or summary requests by their relevance to the query
+func (uc *SummariesUseCase) Search(ctx context.Context, query search.Query, params pagination.Params) (pagination.Page[SearchResult], error) {
+	if query.Kind == "" {
+		query.Kind = SearchKindSummary
+	}
+	if query.Kind != SearchKindSummary && query.Kind != SearchKindRequest {
+		return pagination.Page[SearchResult]{}, ErrInvalidSearchKind
+	}
+
+	results, err := uc.search.Search(ctx, query, params)
+	if err != nil {
+		return pagination.Page[SearchResult]{}, err
+	}
+
+	ids := make([]string, len(results.Items))
+	for i, hit := range results.Items {
+		ids[i] = hit.ID
+	}
+
+	response := pagination.Page[SearchResult]{Items: []SearchResult{}, NextCursor: results.NextCursor, Total: results.Total}
+	switch query.Kind {
+	case SearchKindSummary:
+		summaries, err := uc.repo.GetSummariesByIDs(ids)
+		if err != nil {
+			return pagination.Page[SearchResult]{}, err
+		}
+		byID := map[string]Summary{}
+		for _, summary := range summaries {
+			byID[summary.ID] = summary
+		}
+		for _, hit := range results.Items {
+			// A hit can be gone from the database when the memory index has not caught up yet
+			if summary, ok := byID[hit.ID]; ok {
+				response.Items = append(response.Items, SearchResult{Type: hit.Kind, Score: hit.Score, Summary: &summary})
+			}
+		}
+	case SearchKindRequest:
+		requests, err := uc.repo.GetRequestsByIDs(ids)
+		if err != nil {
+			return pagination.Page[SearchResult]{}, err
+		}
+		byID := map[string]SummaryRequest{}
+		for _, request := range requests {
+			byID[request.ID] = request
+		}
+		for _, hit := range results.Items {
+			if request, ok := byID[hit.ID]; ok {
+				response.Items = append(response.Items, SearchResult{Type: hit.Kind, Score: hit.Score, Request: &request})
+			}
+		}
+	}
+
+	return response, nil
+}
@@ -0,0 +1,85 @@
+package summaries
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/search"
+)
+
+// likeFullText stands in for a database full-text search on SQLite: a document matches when it contains
+// the text, and scores the number of columns containing it
+var likeFullText = FullText{
+	Match: func(columns ...string) string {
+		return "instr(" + strings.Join(columns, " || ' ' || ") + ", ?) > 0"
+	},
+	Score: func(columns ...string) string {
+		return "(SELECT COUNT(*) FROM (SELECT " + strings.Join(columns, " AS c UNION ALL SELECT ") + " AS c) WHERE instr(c, ?) > 0)"
+	},
+}
+
+func TestSQLSearchIndexPaging(t *testing.T) {
+	repo := newTestRepository(t)
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for i, summary := range []Summary{
+		{ID: "a", Content: "rain", Summary: "sun", CreatedAt: start},
+		{ID: "b", Content: "rain", Summary: "sun", CreatedAt: start},
+		{ID: "c", Content: "rain", Summary: "sun", CreatedAt: start.Add(time.Hour)},
+		{ID: "d", Content: "rain", Summary: "more rain", CreatedAt: start},
+		{ID: "e", Content: "snow", Summary: "sun", CreatedAt: start},
+	} {
+		summary.RequestID = "request-1"
+		summary.UserID = "author"
+		summary.Status = StatusPending
+		if err := repo.db.Create(&summary).Error; err != nil {
+			t.Fatalf("summary %d: %v", i, err)
+		}
+	}
+	index := NewSQLSearchIndex(repo.db, likeFullText)
+	query := search.Query{Kind: SearchKindSummary, Text: "rain"}
+
+	first, err := index.Search(context.Background(), query, pagination.Params{Limit: 2, WithTotal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Total == nil || *first.Total != 4 {
+		t.Errorf("total = %v, want 4", first.Total)
+	}
+
+	// Every page size splits the hits with equal scores differently
+	for limit := 1; limit <= 5; limit++ {
+		var ids []string
+		params := pagination.Params{Limit: limit}
+		for pages := 0; ; pages++ {
+			if pages > 5 {
+				t.Fatalf("no last page after %v", ids)
+			}
+			page, err := index.Search(context.Background(), query, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, hit := range page.Items {
+				if hit.Kind != SearchKindSummary {
+					t.Errorf("hit kind = %q", hit.Kind)
+				}
+				ids = append(ids, hit.ID)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			cursor, err := pagination.DecodeCursor(page.NextCursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params.After = &cursor
+		}
+
+		// The best match first, then equal scores newest first and by ID
+		if got := strings.Join(ids, " "); got != "d c b a" {
+			t.Errorf("pages of %d: hits %s, want d c b a", limit, got)
+		}
+	}
+}
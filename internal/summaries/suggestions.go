@@ -0,0 +1,218 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/diff"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+var (
+	ErrSuggestionNotFound   = errors.New("suggested edit not found")
+	ErrSuggestionNotPending = errors.New("suggested edit has already been reviewed")
+	ErrSuggestionUnchanged  = errors.New("the suggested content is the same as the current content")
+	ErrOwnSuggestion        = errors.New("a suggested edit must be reviewed by another moderator")
+)
+
+// SuggestEdit stores a user's edit of a summary as a pending suggestion for moderators to review
+func (uc *SummariesUseCase) SuggestEdit(summaryID string, dto SuggestEditDto, user utils.Principal) (EditSuggestion, error) {
+	if err := uc.policy.Authorize(user, permissions.SummarySuggest, permissions.Resource{Type: permissions.ResourceSummary, ID: summaryID}); err != nil {
+		return EditSuggestion{}, err
+	}
+
+	summary, err := uc.repo.GetSummaryByID(summaryID)
+	if err != nil {
+		return EditSuggestion{}, err
+	}
+
+	if err := checkEditable(summary.Status); err != nil {
+		return EditSuggestion{}, err
+	}
+	if dto.Content == summary.Content {
+		return EditSuggestion{}, ErrSuggestionUnchanged
+	}
+
+	suggestion := SummaryEdit{
+		ID:          uuid.New().String(),
+		SummaryID:   summaryID,
+		Content:     dto.Content,
+		EditedBy:    user.UserID,
+		EditedAt:    time.Now(),
+		EditMessage: dto.EditMessage,
+		Status:      EditPending,
+		BaseVersion: summary.CurrentVersion,
+		Diff: &SuggestionDiff{
+			Lines: diff.Lines(summary.Content, dto.Content),
+			Words: diff.Words(summary.Content, dto.Content),
+		},
+	}
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		if err := repo.CreateSuggestion(suggestion); err != nil {
+			return err
+		}
+
+		_, err := uc.audit.Append(repo.db, user.UserID, audit.ActionSuggestionFiled, audit.TargetSuggestion, suggestion.ID, map[string]interface{}{
+			"summary_id":   summaryID,
+			"base_version": summary.CurrentVersion,
+		})
+		return err
+	})
+	if err != nil {
+		return EditSuggestion{}, err
+	}
+
+	return newEditSuggestion(suggestion, summary), nil
+}
+
+// GetPendingSuggestions lists the suggested edits waiting for a moderator, each with the diff stored when
+// it was suggested
+func (uc *SummariesUseCase) GetPendingSuggestions(summaryID string, user utils.Principal, params pagination.Params) (pagination.Page[EditSuggestion], error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryEdit, permissions.Resource{}); err != nil {
+		return pagination.Page[EditSuggestion]{}, err
+	}
+
+	pending, err := uc.repo.GetPendingSuggestions(summaryID, params)
+	if err != nil {
+		return pagination.Page[EditSuggestion]{}, err
+	}
+
+	summaryIDs := make([]string, 0, len(pending.Items))
+	for _, suggestion := range pending.Items {
+		summaryIDs = append(summaryIDs, suggestion.SummaryID)
+	}
+	summaries, err := uc.repo.GetSummariesByIDs(summaryIDs)
+	if err != nil {
+		return pagination.Page[EditSuggestion]{}, err
+	}
+	byID := make(map[string]Summary, len(summaries))
+	for _, summary := range summaries {
+		byID[summary.ID] = summary
+	}
+
+	suggestions := pagination.Page[EditSuggestion]{
+		Items:      make([]EditSuggestion, 0, len(pending.Items)),
+		NextCursor: pending.NextCursor,
+		Total:      pending.Total,
+	}
+	for _, suggestion := range pending.Items {
+		suggestions.Items = append(suggestions.Items, newEditSuggestion(suggestion, byID[suggestion.SummaryID]))
+	}
+	return suggestions, nil
+}
+
+// AcceptSuggestion applies a suggested edit as the next version of its summary, credited to the user who
+// suggested it. expectedVersion is the version the moderator reviewed it against and must be the current
+// one. The suggestion replaces the current content, so a suggestion whose base version was edited since
+// conflicts unless the moderator reviewed it against the current version, accepting that it drops those edits.
+func (uc *SummariesUseCase) AcceptSuggestion(id string, expectedVersion int, user utils.Principal) (SummaryEdit, error) {
+	suggestion, err := uc.repo.GetSuggestionByID(id)
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if err := uc.policy.Authorize(user, permissions.SummaryEdit, permissions.Resource{Type: permissions.ResourceSummary, ID: suggestion.SummaryID}); err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if suggestion.EditedBy == user.UserID {
+		return SummaryEdit{}, ErrOwnSuggestion
+	}
+	if suggestion.Status != EditPending {
+		return SummaryEdit{}, ErrSuggestionNotPending
+	}
+
+	summary, err := uc.repo.GetSummaryByID(suggestion.SummaryID)
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if err := checkEditable(summary.Status); err != nil {
+		return SummaryEdit{}, err
+	}
+	if expectedVersion == 0 {
+		return SummaryEdit{}, ErrVersionRequired
+	}
+	if summary.CurrentVersion != expectedVersion {
+		return SummaryEdit{}, &VersionConflictError{Expected: expectedVersion, Current: summary.CurrentVersion}
+	}
+
+	var accepted SummaryEdit
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		var err error
+		accepted, err = repo.AcceptSuggestion(id, summary.CurrentVersion+1, user.UserID)
+		if err != nil {
+			return err
+		}
+
+		_, err = uc.audit.Append(repo.db, user.UserID, audit.ActionSuggestionAccepted, audit.TargetSuggestion, accepted.ID, map[string]interface{}{
+			"summary_id": accepted.SummaryID,
+			"version":    accepted.Version,
+			"author_id":  accepted.EditedBy,
+		})
+		return err
+	})
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+
+	ctx := context.Background()
+	uc.redis.Delete(ctx, fmt.Sprintf("summary:%s", accepted.SummaryID))
+	uc.indexSummary(ctx, accepted.SummaryID)
+
+	return accepted, nil
+}
+
+// DeclineSuggestion declines a suggested edit with the moderator's reason
+func (uc *SummariesUseCase) DeclineSuggestion(id string, dto DeclineSuggestionDto, user utils.Principal) (SummaryEdit, error) {
+	suggestion, err := uc.repo.GetSuggestionByID(id)
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if err := uc.policy.Authorize(user, permissions.SummaryEdit, permissions.Resource{Type: permissions.ResourceSummary, ID: suggestion.SummaryID}); err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if suggestion.EditedBy == user.UserID {
+		return SummaryEdit{}, ErrOwnSuggestion
+	}
+	if suggestion.Status != EditPending {
+		return SummaryEdit{}, ErrSuggestionNotPending
+	}
+
+	var declined SummaryEdit
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		var err error
+		declined, err = repo.DeclineSuggestion(id, user.UserID, dto.Reason)
+		if err != nil {
+			return err
+		}
+
+		_, err = uc.audit.Append(repo.db, user.UserID, audit.ActionSuggestionDeclined, audit.TargetSuggestion, declined.ID, map[string]string{
+			"summary_id": declined.SummaryID,
+			"reason":     dto.Reason,
+		})
+		return err
+	})
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+
+	return declined, nil
+}
+
+func newEditSuggestion(suggestion SummaryEdit, summary Summary) EditSuggestion {
+	return EditSuggestion{
+		SummaryEdit:    suggestion,
+		CurrentVersion: summary.CurrentVersion,
+		Lines:          suggestion.Diff.Lines,
+		Words:          suggestion.Diff.Words,
+	}
+}
@@ -0,0 +1,153 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+)
+
+const (
+	SummarizerOpenAI = "openai"
+	SummarizerLocal  = "local"
+)
+
+var ErrEmptyContent = errors.New("nothing to summarize")
+
+// Summarizer produces a short summary of a piece of content
+type Summarizer interface {
+	Summarize(ctx context.Context, content string) (string, error)
+}
+
+// NewSummarizer returns the summarizer selected by the config.
+// The local extractive summarizer is used when no provider is configured so the pipeline can run offline.
+func NewSummarizer(cfg config.Config) Summarizer {
+	switch cfg.Summarizer {
+	case SummarizerOpenAI:
+		return adapters.NewOpenAIClient(cfg.OpenAIBaseURL, cfg.OpenAIKey, cfg.OpenAIModel)
+	default:
+		return NewExtractiveSummarizer(3)
+	}
+}
+
+// ExtractiveSummarizer picks the highest scoring sentences from the content.
+// It is deterministic: the same content always produces the same summary.
+type ExtractiveSummarizer struct {
+	maxSentences int
+}
+
+// NewExtractiveSummarizer creates a new ExtractiveSummarizer that keeps at most maxSentences sentences
+func NewExtractiveSummarizer(maxSentences int) *ExtractiveSummarizer {
+	if maxSentences < 1 {
+		maxSentences = 1
+	}
+	return &ExtractiveSummarizer{maxSentences: maxSentences}
+}
+
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true, "but": true,
+	"by": true, "for": true, "from": true, "has": true, "have": true, "he": true, "her": true, "his": true,
+	"i": true, "in": true, "is": true, "it": true, "its": true, "of": true, "on": true, "or": true,
+	"our": true, "she": true, "that": true, "the": true, "their": true, "they": true, "this": true,
+	"to": true, "was": true, "we": true, "were": true, "will": true, "with": true, "you": true,
+}
+
+// Summarize scores each sentence by the frequency of its non stop words and returns the best ones in their original order
+func (s *ExtractiveSummarizer) Summarize(ctx context.Context, content string) (string, error) {
+	sentences := splitSentences(content)
+	if len(sentences) == 0 {
+		return "", ErrEmptyContent
+	}
+	if len(sentences) <= s.maxSentences {
+		return strings.Join(sentences, " "), nil
+	}
+
+	frequencies := map[string]int{}
+	for _, sentence := range sentences {
+		for _, word := range tokenize(sentence) {
+			frequencies[word]++
+		}
+	}
+
+	type scored struct {
+		index int
+		score float64
+	}
+	scores := make([]scored, len(sentences))
+	for i, sentence := range sentences {
+		words := tokenize(sentence)
+		total := 0
+		for _, word := range words {
+			total += frequencies[word]
+		}
+		score := 0.0
+		if len(words) > 0 {
+			score = float64(total) / float64(len(words))
+		}
+		scores[i] = scored{index: i, score: score}
+	}
+
+	sort.SliceStable(scores, func(i, j int) bool {
+		return scores[i].score > scores[j].score
+	})
+
+	picked := scores[:s.maxSentences]
+	sort.Slice(picked, func(i, j int) bool {
+		return picked[i].index < picked[j].index
+	})
+
+	summary := make([]string, len(picked))
+	for i, p := range picked {
+		summary[i] = sentences[p.index]
+	}
+
+	return strings.Join(summary, " "), nil
+}
+
+// splitSentences splits text on sentence ending punctuation and line breaks
+func splitSentences(text string) []string {
+	var sentences []string
+	var current strings.Builder
+
+	flush := func() {
+		sentence := strings.TrimSpace(current.String())
+		if sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(text)
+	for i, r := range runes {
+		if r == '\n' {
+			flush()
+			continue
+		}
+		current.WriteRune(r)
+		if (r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])) {
+			flush()
+		}
+	}
+	flush()
+
+	return sentences
+}
+
+// tokenize lower cases a sentence and returns its words without stop words
+func tokenize(sentence string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(sentence), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+
+	words := fields[:0]
+	for _, field := range fields {
+		if !stopWords[field] {
+			words = append(words, field)
+		}
+	}
+	return words
+}
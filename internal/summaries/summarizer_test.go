@@ -0,0 +1,94 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+)
+
+const claimPost = `Vaccines were tested on thousands of volunteers before approval.
+The trials tracked side effects for months.
+I had lunch with my cousin today!
+Regulators published the trial data for vaccines and side effects.
+Is it raining?`
+
+func TestExtractiveSummarizer(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxSentences int
+		content      string
+		want         string
+	}{
+		{
+			name:         "short content is kept whole",
+			maxSentences: 3,
+			content:      "The bridge closed on Monday.\nIt reopens in May.",
+			want:         "The bridge closed on Monday. It reopens in May.",
+		},
+		{
+			name:         "the sentences sharing the most words are kept in order",
+			maxSentences: 2,
+			content:      claimPost,
+			want:         "The trials tracked side effects for months. Regulators published the trial data for vaccines and side effects.",
+		},
+		{
+			name:         "at least one sentence is kept",
+			maxSentences: 0,
+			content:      "Prices rose. Prices rose again in March. Wages did not.",
+			want:         "Prices rose.",
+		},
+		{
+			name:         "decimals and abbreviations don't end a sentence",
+			maxSentences: 3,
+			content:      "Inflation was 3.5 percent in the U.S.A.market. It fell later!",
+			want:         "Inflation was 3.5 percent in the U.S.A.market. It fell later!",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := NewExtractiveSummarizer(test.maxSentences).Summarize(context.Background(), test.content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("Summarize = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestExtractiveSummarizerIsDeterministic(t *testing.T) {
+	summarizer := NewExtractiveSummarizer(2)
+	first, err := summarizer.Summarize(context.Background(), claimPost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if got, _ := summarizer.Summarize(context.Background(), claimPost); got != first {
+			t.Fatalf("Summarize = %q, earlier %q", got, first)
+		}
+	}
+}
+
+func TestExtractiveSummarizerEmptyContent(t *testing.T) {
+	for _, content := range []string{"", "   ", "\n\n"} {
+		if _, err := NewExtractiveSummarizer(3).Summarize(context.Background(), content); !errors.Is(err, ErrEmptyContent) {
+			t.Errorf("Summarize(%q) returned %v, want ErrEmptyContent", content, err)
+		}
+	}
+}
+
+func TestNewSummarizer(t *testing.T) {
+	if _, ok := NewSummarizer(config.Config{}).(*ExtractiveSummarizer); !ok {
+		t.Error("no summarizer configured did not select the extractive summarizer")
+	}
+	if _, ok := NewSummarizer(config.Config{Summarizer: SummarizerLocal}).(*ExtractiveSummarizer); !ok {
+		t.Error("the local summarizer is not the extractive summarizer")
+	}
+	if _, ok := NewSummarizer(config.Config{Summarizer: SummarizerOpenAI}).(*adapters.OpenAIClient); !ok {
+		t.Error("the openai summarizer is not the OpenAI client")
+	}
+}
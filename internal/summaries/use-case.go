@@ -2,93 +2,382 @@ package summaries
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
 	"github.com/mwelwankuta/facebook-notes/pkg/config"
-	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/facebook"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/queue"
+	"github.com/mwelwankuta/facebook-notes/pkg/search"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
 )
 
 var (
-	ErrUnauthorized    = errors.New("unauthorized")
-	ErrNotModerator    = errors.New("user is not a moderator")
 	ErrInvalidStatus   = errors.New("invalid summary status")
 	ErrInvalidResource = errors.New("invalid resource link")
+	ErrSummaryNotFound = errors.New("summary not found")
+	ErrInvalidPostURL  = errors.New("url is not a facebook post")
+	ErrPostNotFound    = errors.New("facebook post not found")
+	ErrPostUnavailable = errors.New("could not fetch facebook post")
+	ErrEmptyPost       = errors.New("facebook post has no text to summarize")
 )
 
 type SummariesUseCase struct {
-	config config.Config
-	repo   SummariesRepository
-	redis  *adapters.RedisClient
+	config     config.Config
+	repo       SummariesRepository
+	redis      *adapters.RedisClient
+	summarizer Summarizer
+	jobs       *queue.Queue
+	audit      *audit.Log
+	graph      *adapters.FacebookGraphClient
+	policy     *permissions.Policy
+	search     search.Index
 }
 
-func NewSummariesUseCase(repo SummariesRepository, cfg config.Config, redis *adapters.RedisClient) *SummariesUseCase {
+func NewSummariesUseCase(repo SummariesRepository, cfg config.Config, redis *adapters.RedisClient, summarizer Summarizer, jobs *queue.Queue, auditLog *audit.Log, policy *permissions.Policy, searchIndex search.Index) *SummariesUseCase {
 	return &SummariesUseCase{
-		repo:   repo,
-		config: cfg,
-		redis:  redis,
+		repo:       repo,
+		config:     cfg,
+		redis:      redis,
+		summarizer: summarizer,
+		jobs:       jobs,
+		audit:      auditLog,
+		graph:      adapters.NewFacebookGraphClient(cfg.FacebookGraphURL, graphAccessToken(cfg)),
+		policy:     policy,
+		search:     searchIndex,
 	}
 }
 
-func (uc *SummariesUseCase) CreateSummaryRequest(dto CreateSummaryRequestDto, user models.User) (SummaryRequest, error) {
-	if user.ID == "" {
-		return SummaryRequest{}, ErrUnauthorized
+// graphAccessToken returns the configured page token, falling back to the app access token
+func graphAccessToken(cfg config.Config) string {
+	if cfg.FacebookAccessToken != "" {
+		return cfg.FacebookAccessToken
+	}
+	return cfg.OpenGraphClientID + "|" + cfg.OpenGraphClientSecret
+}
+
+func (uc *SummariesUseCase) CreateSummaryRequest(dto CreateSummaryRequestDto, user utils.Principal) (SummaryRequest, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryRequest, permissions.Resource{}); err != nil {
+		return SummaryRequest{}, err
 	}
 
 	request := SummaryRequest{
 		Content:  dto.Content,
 		Metadata: dto.Metadata,
-		UserID:   user.ID,
+		UserID:   user.UserID,
 		Status:   StatusPending,
 	}
 
+	if dto.PostURL != "" {
+		post, err := facebook.ParsePostURL(dto.PostURL)
+		if err != nil {
+			return SummaryRequest{}, ErrInvalidPostURL
+		}
+		request.PostID = post.ID
+		request.PostURL = post.CanonicalURL
+
+		// Only a post url was submitted, so the content is pulled from the Graph API
+		if request.Content == "" {
+			content, metadata, err := uc.fetchPostContent(context.Background(), post)
+			if err != nil {
+				return SummaryRequest{}, err
+			}
+			request.Content = content
+			if request.Metadata == "" {
+				request.Metadata = metadata
+			}
+		}
+	}
+
+	return uc.submitRequest(context.Background(), request)
+}
+
+// submitRequest stores a new summary request and queues it for summarization
+func (uc *SummariesUseCase) submitRequest(ctx context.Context, request SummaryRequest) (SummaryRequest, error) {
 	// Create the request
 	newRequest, err := uc.repo.CreateSummaryRequest(request)
 	if err != nil {
 		return SummaryRequest{}, err
 	}
 
-	// TODO: Trigger AI summarization asynchronously
-	go uc.processAISummarization(newRequest.ID)
+	// Summarization runs on the summaries-worker so it survives restarts and is retried on failure.
+	// A request that never made it onto the queue would stay pending forever, so it is removed again.
+	_, err = uc.jobs.Enqueue(ctx, JobSummarizeRequest, summarizeRequestPayload{RequestID: newRequest.ID})
+	if err != nil {
+		if deleteErr := uc.repo.DeleteSummaryRequest(newRequest.ID); deleteErr != nil {
+			return SummaryRequest{}, errors.Join(err, deleteErr)
+		}
+		return SummaryRequest{}, err
+	}
+	uc.indexRequest(ctx, newRequest.ID)
 
 	return newRequest, nil
 }
 
-func (uc *SummariesUseCase) ModerateSummary(id string, dto ModerateRequestDto, user models.User) error {
-	if user.Role != RoleModerator {
-		return ErrNotModerator
+// ModerateSummary records the moderator's vote on a summary. The summary is approved or rejected once
+// moderation.quorum votes agree and escalated when the panel cannot reach a quorum. Escalated summaries
+// are decided directly by a user with the summary:override permission.
+func (uc *SummariesUseCase) ModerateSummary(id string, dto ModerateRequestDto, user utils.Principal) (VoteTally, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{Type: permissions.ResourceSummary, ID: id}); err != nil {
+		return VoteTally{}, err
 	}
 
 	var status string
 	switch dto.Action {
-	case "approve":
+	case VoteApprove:
 		status = StatusApproved
-	case "reject":
+	case VoteReject:
 		status = StatusRejected
 	default:
-		return ErrInvalidStatus
+		return VoteTally{}, ErrInvalidStatus
+	}
+
+	summary, err := uc.repo.GetSummaryByID(id)
+	if err != nil {
+		return VoteTally{}, err
+	}
+
+	// Appealed summaries are decided through ResolveAppeal, by a different moderator
+	if summary.Status == StatusAppealed {
+		return VoteTally{}, ErrAppealPending
+	}
+
+	if summary.Status == StatusEscalated {
+		return uc.overrideEscalation(summary, status, dto, user)
 	}
 
-	return uc.repo.UpdateSummaryStatus(id, status, user.ID, dto.Notes)
+	if err := checkTransition(summary.Status, status); err != nil {
+		return VoteTally{}, err
+	}
+
+	quorum, panelSize := uc.quorum()
+	var tally VoteTally
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		var err error
+		tally, err = repo.CastVote(ModerationVote{
+			SummaryID:   id,
+			ModeratorID: user.UserID,
+			Decision:    dto.Action,
+			Notes:       dto.Notes,
+		}, quorum, panelSize)
+		if err != nil {
+			return err
+		}
+
+		// The moderator is done with the summary, it goes back to the queue for the next vote or has left it
+		if err := repo.DeleteClaim(id); err != nil {
+			return err
+		}
+
+		_, err = uc.audit.Append(repo.db, user.UserID, audit.ActionSummaryVoted, audit.TargetSummary, id, map[string]string{
+			"vote":  dto.Action,
+			"notes": dto.Notes,
+		})
+		if err != nil || tally.Status == summary.Status {
+			return err
+		}
+
+		_, err = uc.audit.Append(repo.db, user.UserID, audit.ActionSummaryModerated, audit.TargetSummary, id, map[string]interface{}{
+			"from":       summary.Status,
+			"to":         tally.Status,
+			"approvals":  tally.Approvals,
+			"rejections": tally.Rejections,
+		})
+		return err
+	})
+	if err != nil {
+		return VoteTally{}, err
+	}
+
+	// Invalidate cache
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("summary:%s", id)
+	uc.redis.Delete(ctx, cacheKey)
+	uc.indexSummary(ctx, id)
+
+	return tally, nil
 }
 
-func (uc *SummariesUseCase) processAISummarization(requestID string) {
-	// TODO: Implement AI summarization logic using OpenAI
-	// 1. Get the request content
-	// 2. Call OpenAI API
-	// 3. Update the summary with AI response
-	// uc.repo.UpdateAIResponse(requestID, aiResponse)
+// overrideEscalation decides a summary the moderators could not agree on
+func (uc *SummariesUseCase) overrideEscalation(summary Summary, status string, dto ModerateRequestDto, user utils.Principal) (VoteTally, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryOverride, permissions.Resource{Type: permissions.ResourceSummary, ID: summary.ID}); err != nil {
+		return VoteTally{}, err
+	}
+
+	err := uc.repo.Transaction(func(repo *SummariesRepository) error {
+		if err := repo.UpdateSummaryStatus(summary.ID, StatusEscalated, status, user.UserID, dto.Notes); err != nil {
+			return err
+		}
+
+		_, err := uc.audit.Append(repo.db, user.UserID, audit.ActionSummaryModerated, audit.TargetSummary, summary.ID, map[string]interface{}{
+			"from":     StatusEscalated,
+			"to":       status,
+			"notes":    dto.Notes,
+			"override": true,
+		})
+		return err
+	})
+	if err != nil {
+		return VoteTally{}, err
+	}
+
+	votes, err := uc.repo.GetVotes(summary.ID)
+	if err != nil {
+		return VoteTally{}, err
+	}
+	quorum, panelSize := uc.quorum()
+	tally := VoteTally{SummaryID: summary.ID, Status: status, Quorum: quorum, PanelSize: panelSize}
+	tally.Approvals, tally.Rejections = countVotes(votes)
+
+	// Invalidate cache
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("summary:%s", summary.ID)
+	uc.redis.Delete(ctx, cacheKey)
+	uc.indexSummary(ctx, summary.ID)
+
+	return tally, nil
 }
 
-func (uc *SummariesUseCase) GetAllSummaries(dto models.PaginateDto) ([]Summary, error) {
-	return uc.repo.GetAllSummaries(dto)
+// fetchPostContent fetches a post from the Graph API and returns its text, including attachment titles
+// and descriptions, together with JSON metadata about the post
+func (uc *SummariesUseCase) fetchPostContent(ctx context.Context, ref facebook.PostRef) (string, string, error) {
+	// The Graph API only knows posts by their numeric owner ID, not the username in the URL
+	if ref.NeedsOwnerID() {
+		ownerID, err := uc.graph.GetObjectID(ctx, ref.OwnerID)
+		if err != nil {
+			return "", "", graphError(err)
+		}
+		if ref, err = ref.WithOwnerID(ownerID); err != nil {
+			return "", "", fmt.Errorf("%w: %v", ErrPostUnavailable, err)
+		}
+	}
+
+	post, err := uc.graph.GetPost(ctx, ref.GraphID())
+	if err != nil {
+		return "", "", graphError(err)
+	}
+
+	parts := []string{}
+	if post.Message != "" {
+		parts = append(parts, post.Message)
+	}
+	for _, attachment := range post.Attachments {
+		if attachment.Title != "" {
+			parts = append(parts, attachment.Title)
+		}
+		if attachment.Description != "" {
+			parts = append(parts, attachment.Description)
+		}
+	}
+	if len(parts) == 0 {
+		return "", "", ErrEmptyPost
+	}
+
+	metadata, err := json.Marshal(map[string]interface{}{
+		"source":        "facebook_graph",
+		"post_id":       post.ID,
+		"author_id":     post.From.ID,
+		"author_name":   post.From.Name,
+		"created_time":  post.CreatedTime,
+		"permalink_url": post.PermalinkURL,
+		"attachments":   len(post.Attachments),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return strings.Join(parts, "\n\n"), string(metadata), nil
 }
 
-func (uc *SummariesUseCase) GetAllRequests(dto models.PaginateDto) ([]SummaryRequest, error) {
-	return uc.repo.GetAllRequests(dto)
+// graphError maps an error of the Graph API client to ErrPostNotFound or ErrPostUnavailable
+func graphError(err error) error {
+	if errors.Is(err, adapters.ErrFacebookPostNotFound) {
+		return ErrPostNotFound
+	}
+	return fmt.Errorf("%w: %v", ErrPostUnavailable, err)
+}
+
+// processAISummarization summarizes a pending request and stores the result as an AI reviewed summary.
+// It is safe to run more than once for the same request, as the queue may deliver a job again.
+func (uc *SummariesUseCase) processAISummarization(ctx context.Context, requestID string) error {
+	request, err := uc.repo.GetSummaryRequestByID(requestID)
+	if err != nil {
+		return queue.Permanent(err)
+	}
+	if request.Status != StatusPending {
+		return nil
+	}
+
+	aiResponse, err := uc.summarizer.Summarize(ctx, request.Content)
+	if err != nil {
+		if errors.Is(err, ErrEmptyContent) {
+			return queue.Permanent(err)
+		}
+		return err
+	}
+
+	summary, err := uc.repo.GetSummaryByRequestID(request.ID)
+	if err != nil {
+		summary, err = uc.repo.CreateSummary(Summary{
+			RequestID:      request.ID,
+			PostID:         request.PostID,
+			Content:        request.Content,
+			Summary:        aiResponse,
+			IsSummarizedAI: true,
+			UserID:         request.UserID,
+			Status:         StatusPending,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if summary.Status == StatusPending {
+		if err := uc.repo.UpdateAIResponse(summary.ID, aiResponse); err != nil {
+			return err
+		}
+	}
+
+	if err := uc.repo.UpdateRequestStatus(request.ID, StatusAIReviewed); err != nil {
+		return err
+	}
+
+	uc.indexSummary(ctx, summary.ID)
+	uc.indexRequest(ctx, request.ID)
+	return nil
+}
+
+func (uc *SummariesUseCase) GetAllSummaries(params pagination.Params) (pagination.Page[Summary], error) {
+	return uc.repo.GetAllSummaries(params)
+}
+
+func (uc *SummariesUseCase) GetAllRequests(params pagination.Params) (pagination.Page[SummaryRequest], error) {
+	return uc.repo.GetAllRequests(params)
+}
+
+// GetSummariesByPostURL returns the approved summaries of the Facebook post the url points to. Summaries
+// are matched by the post's canonical ID, so a pfbid URL doesn't find summaries requested with the
+// numeric URL of the same post, or the other way around.
+func (uc *SummariesUseCase) GetSummariesByPostURL(postURL string) (PostSummariesResponse, error) {
+	post, err := facebook.ParsePostURL(postURL)
+	if err != nil {
+		return PostSummariesResponse{}, ErrInvalidPostURL
+	}
+
+	summaries, err := uc.repo.GetApprovedSummariesByPostID(post.ID)
+	if err != nil {
+		return PostSummariesResponse{}, err
+	}
+
+	return PostSummariesResponse{Post: post, Summaries: summaries}, nil
 }
 
 func (uc *SummariesUseCase) GetSummaryByID(id string) (Summary, error) {
@@ -108,59 +397,108 @@ func (uc *SummariesUseCase) GetSummaryByID(id string) (Summary, error) {
 		return Summary{}, err
 	}
 
+	ratings, err := uc.repo.GetRatingAggregate(id)
+	if err != nil {
+		return Summary{}, err
+	}
+	summary.Ratings = &ratings
+
 	// Cache the result
 	uc.redis.Set(ctx, cacheKey, summary, 30*time.Minute)
 	return summary, nil
 }
 
-func (uc *SummariesUseCase) RateSummary(id string, dto RateSummaryDto) error {
-	return uc.repo.UpdateSummaryRating(id, dto.Rating)
+// RateSummary records the user's rating of a summary. Rating again replaces the user's previous vote.
+func (uc *SummariesUseCase) RateSummary(id string, dto RateSummaryDto, user utils.Principal) (RatingAggregate, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryRate, permissions.Resource{Type: permissions.ResourceSummary, ID: id}); err != nil {
+		return RatingAggregate{}, err
+	}
+
+	if _, err := uc.repo.GetSummaryByID(id); err != nil {
+		return RatingAggregate{}, err
+	}
+
+	aggregate, err := uc.repo.UpsertSummaryRating(SummaryRating{
+		SummaryID: id,
+		UserID:    user.UserID,
+		Rating:    dto.Rating,
+	})
+	if err != nil {
+		return RatingAggregate{}, err
+	}
+
+	// Invalidate cache
+	ctx := context.Background()
+	cacheKey := fmt.Sprintf("summary:%s", id)
+	uc.redis.Delete(ctx, cacheKey)
+
+	return aggregate, nil
 }
 
-// EditSummary allows moderators to edit a summary's content and keeps track of edit history
-func (uc *SummariesUseCase) EditSummary(id string, dto EditSummaryDto, user models.User) error {
-	if user.Role != RoleModerator {
-		return ErrNotModerator
+// EditSummary allows moderators to edit a summary's content and keeps track of edit history. The edit must
+// be made on the summary's current version, so concurrent edits can't overwrite each other.
+func (uc *SummariesUseCase) EditSummary(id string, dto EditSummaryDto, user utils.Principal) (SummaryEdit, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryEdit, permissions.Resource{Type: permissions.ResourceSummary, ID: id}); err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if dto.ExpectedVersion == 0 {
+		return SummaryEdit{}, ErrVersionRequired
 	}
 
-	summary, err := uc.repo.GetSummaryWithResources(id)
+	summary, err := uc.repo.GetSummaryByID(id)
 	if err != nil {
-		return err
+		return SummaryEdit{}, err
 	}
 
-	// Create edit history entry
+	if err := checkEditable(summary.Status); err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if summary.CurrentVersion != dto.ExpectedVersion {
+		return SummaryEdit{}, &VersionConflictError{Expected: dto.ExpectedVersion, Current: summary.CurrentVersion}
+	}
+
+	// The edit history entry becomes the current content
 	edit := SummaryEdit{
 		ID:          uuid.New().String(),
 		SummaryID:   summary.ID,
 		Content:     dto.Content,
-		EditedBy:    user.ID,
+		EditedBy:    user.UserID,
 		EditedAt:    time.Now(),
-		Version:     summary.CurrentVersion + 1,
+		Version:     dto.ExpectedVersion + 1,
 		EditMessage: dto.EditMessage,
+		Status:      EditApplied,
 	}
 
-	if err := uc.repo.CreateSummaryEdit(edit); err != nil {
-		return err
-	}
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		if err := repo.CreateSummaryVersion(edit); err != nil {
+			return err
+		}
 
-	// Update summary content
-	err = uc.repo.UpdateSummaryContent(id, dto.Content, edit.Version)
-	if err != nil {
+		_, err := uc.audit.Append(repo.db, user.UserID, audit.ActionSummaryEdited, audit.TargetSummary, id, map[string]interface{}{
+			"version":      edit.Version,
+			"edit_message": dto.EditMessage,
+		})
 		return err
+	})
+	if err != nil {
+		return SummaryEdit{}, err
 	}
 
 	// Invalidate cache
 	ctx := context.Background()
 	cacheKey := fmt.Sprintf("summary:%s", id)
 	uc.redis.Delete(ctx, cacheKey)
+	uc.indexSummary(ctx, id)
 
-	return nil
+	return edit, nil
 }
 
 // AddResourceLink adds a resource link to a summary
-func (uc *SummariesUseCase) AddResourceLink(summaryID string, dto ResourceLinkDto, user models.User) error {
-	if user.Role != RoleModerator {
-		return ErrNotModerator
+func (uc *SummariesUseCase) AddResourceLink(summaryID string, dto ResourceLinkDto, user utils.Principal) error {
+	if err := uc.policy.Authorize(user, permissions.ResourceAdd, permissions.Resource{Type: permissions.ResourceSummary, ID: summaryID}); err != nil {
+		return err
 	}
 
 	link := ResourceLink{
@@ -170,18 +508,61 @@ func (uc *SummariesUseCase) AddResourceLink(summaryID string, dto ResourceLinkDt
 		Description: dto.Description,
 		SummaryID:   summaryID,
 		CreatedAt:   time.Now(),
-		CreatedBy:   user.ID,
+		CreatedBy:   user.UserID,
+	}
+
+	err := uc.repo.Transaction(func(repo *SummariesRepository) error {
+		if err := repo.AddResourceLink(link); err != nil {
+			return err
+		}
+
+		_, err := uc.audit.Append(repo.db, user.UserID, audit.ActionResourceAdded, audit.TargetResourceLink, link.ID, map[string]string{
+			"summary_id": summaryID,
+			"url":        link.URL,
+		})
+		return err
+	})
+	if err != nil {
+		return err
 	}
 
-	return uc.repo.AddResourceLink(link)
+	uc.indexSummary(context.Background(), summaryID)
+	return nil
 }
 
 // RemoveResourceLink removes a resource link from a summary
-func (uc *SummariesUseCase) RemoveResourceLink(linkID string, user models.User) error {
-	if user.Role != RoleModerator {
-		return ErrNotModerator
+func (uc *SummariesUseCase) RemoveResourceLink(linkID string, user utils.Principal) error {
+	if err := uc.policy.Authorize(user, permissions.ResourceDelete, permissions.Resource{Type: permissions.ResourceLink, ID: linkID}); err != nil {
+		return err
+	}
+
+	link, err := uc.repo.GetResourceLinkByID(linkID)
+	if err != nil {
+		return ErrInvalidResource
+	}
+
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		if err := repo.RemoveResourceLink(linkID); err != nil {
+			return err
+		}
+
+		_, err := uc.audit.Append(repo.db, user.UserID, audit.ActionResourceRemoved, audit.TargetResourceLink, linkID, nil)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	uc.indexSummary(context.Background(), link.SummaryID)
+	return nil
+}
+
+// GetSummaryTransitions returns every status change of a summary, oldest first
+func (uc *SummariesUseCase) GetSummaryTransitions(id string) ([]SummaryTransition, error) {
+	if _, err := uc.repo.GetSummaryByID(id); err != nil {
+		return nil, err
 	}
-	return uc.repo.RemoveResourceLink(linkID)
+	return uc.repo.GetSummaryTransitions(id)
 }
 
 // GetSummaryWithResources returns a summary with its resources and edit history
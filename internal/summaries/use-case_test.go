@@ -0,0 +1,405 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/diff"
+	"github.com/mwelwankuta/facebook-notes/pkg/facebook"
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/search"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+// newTestUseCase returns a use case on a new in-memory database and an in-memory redis, whose moderators
+// decide with quorum of panelSize votes
+func newTestUseCase(t *testing.T, quorum int, panelSize int) (*SummariesUseCase, *SummariesRepository) {
+	t.Helper()
+
+	repo := newTestRepository(t)
+	policy, err := permissions.NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config.Config
+	cfg.Moderation.Quorum = quorum
+	cfg.Moderation.PanelSize = panelSize
+
+	redis := adapters.NewRedisClient(miniredis.RunT(t).Addr(), "", 0)
+	return NewSummariesUseCase(*repo, cfg, redis, nil, nil, audit.NewLog(repo.db), policy, search.NewMemoryIndex()), repo
+}
+
+func moderator(id string) utils.Principal {
+	return utils.Principal{UserID: id, Role: models.RoleModerator}
+}
+
+func auditActions(t *testing.T, uc *SummariesUseCase, targetID string) []string {
+	t.Helper()
+	page, err := uc.audit.Query(audit.Filter{TargetID: targetID}, pagination.Params{Limit: pagination.MaxLimit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := page.Items
+	actions := make([]string, len(entries))
+	for i, entry := range entries {
+		// Query returns the newest entry first
+		actions[len(entries)-1-i] = entry.Action
+	}
+	return actions
+}
+
+func TestFetchPostContentResolvesOwner(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.Path)
+		switch r.URL.Path {
+		case "/zuck":
+			w.Write([]byte(`{"id": "4"}`))
+		case "/4_123":
+			w.Write([]byte(`{"id": "4_123", "message": "Hello", "attachments": {"data": [{"title": "A link"}]}}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": {"message": "Unsupported get request", "code": 100}}`))
+		}
+	}))
+	defer server.Close()
+
+	uc := NewSummariesUseCase(SummariesRepository{}, config.Config{FacebookGraphURL: server.URL}, nil, nil, nil, nil, nil, nil)
+
+	post, err := facebook.ParsePostURL("https://www.facebook.com/zuck/posts/123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, metadata, err := uc.fetchPostContent(context.Background(), post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if content != "Hello\n\nA link" {
+		t.Errorf("content = %q", content)
+	}
+	if !strings.Contains(metadata, `"post_id":"4_123"`) {
+		t.Errorf("metadata = %s", metadata)
+	}
+	if strings.Join(requested, " ") != "/zuck /4_123" {
+		t.Errorf("requested %v", requested)
+	}
+
+	missing, err := facebook.ParsePostURL("https://www.facebook.com/zuck/posts/999")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := uc.fetchPostContent(context.Background(), missing); !errors.Is(err, ErrPostNotFound) {
+		t.Errorf("fetching a missing post returned %v", err)
+	}
+}
+
+func TestModerateSummaryReachesQuorum(t *testing.T) {
+	uc, repo := newTestUseCase(t, 2, 3)
+	summary := createTestSummary(t, repo, StatusAIReviewed)
+
+	if _, err := uc.ModerateSummary(summary.ID, ModerateRequestDto{Action: VoteApprove}, utils.Principal{UserID: "user", Role: models.RoleUser}); !errors.Is(err, permissions.ErrForbidden) {
+		t.Errorf("a user's vote returned %v, want ErrForbidden", err)
+	}
+
+	votes := []struct {
+		moderator string
+		action    string
+		want      string
+	}{
+		{"moderator-a", VoteApprove, StatusAIReviewed},
+		{"moderator-b", VoteReject, StatusAIReviewed},
+		{"moderator-c", VoteApprove, StatusApproved},
+	}
+	for _, vote := range votes {
+		tally, err := uc.ModerateSummary(summary.ID, ModerateRequestDto{Action: vote.action, Notes: "Checked the sources"}, moderator(vote.moderator))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tally.Status != vote.want {
+			t.Errorf("after %s's vote the summary is %s, want %s", vote.moderator, tally.Status, vote.want)
+		}
+		if vote.moderator == "moderator-a" {
+			if _, err := uc.ModerateSummary(summary.ID, ModerateRequestDto{Action: VoteApprove}, moderator(vote.moderator)); !errors.Is(err, ErrAlreadyVoted) {
+				t.Errorf("a second vote returned %v, want ErrAlreadyVoted", err)
+			}
+		}
+	}
+
+	decided, err := uc.GetSummaryByID(summary.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decided.Status != StatusApproved || decided.ModeratorID == nil || *decided.ModeratorID != "moderator-c" {
+		t.Errorf("summary = %+v, want approved by moderator-c", decided)
+	}
+
+	want := []string{audit.ActionSummaryVoted, audit.ActionSummaryVoted, audit.ActionSummaryVoted, audit.ActionSummaryModerated}
+	if actions := auditActions(t, uc, summary.ID); strings.Join(actions, " ") != strings.Join(want, " ") {
+		t.Errorf("audit actions = %v, want %v", actions, want)
+	}
+	if report, err := uc.audit.Verify(); err != nil || !report.OK() {
+		t.Errorf("audit chain: %+v, %v", report, err)
+	}
+}
+
+func TestAppeal(t *testing.T) {
+	uc, repo := newTestUseCase(t, 1, 1)
+	summary := createTestSummary(t, repo, StatusAIReviewed)
+	author := utils.Principal{UserID: summary.UserID, Role: models.RoleUser}
+	appealDto := FileAppealDto{Justification: "The summary is backed by the linked report"}
+
+	if _, err := uc.ModerateSummary(summary.ID, ModerateRequestDto{Action: VoteReject, Notes: "No sources"}, moderator("moderator-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := uc.FileAppeal(summary.ID, appealDto, utils.Principal{UserID: "someone-else", Role: models.RoleUser}); !errors.Is(err, ErrNotAuthor) {
+		t.Errorf("an appeal by another user returned %v, want ErrNotAuthor", err)
+	}
+	appeal, err := uc.FileAppeal(summary.ID, appealDto, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appeal.OriginalModeratorID != "moderator-a" || appeal.Status != AppealPending {
+		t.Errorf("appeal = %+v", appeal)
+	}
+
+	pending, err := uc.GetPendingAppeals(moderator("moderator-b"), pagination.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending.Items) != 1 || pending.Items[0].ID != appeal.ID {
+		t.Errorf("pending appeals = %+v", pending.Items)
+	}
+
+	resolve := ResolveAppealDto{Outcome: "uphold", Notes: "Still no sources"}
+	if _, err := uc.ResolveAppeal(appeal.ID, resolve, moderator("moderator-a")); !errors.Is(err, ErrReviewerConflict) {
+		t.Errorf("review by the rejecting moderator returned %v, want ErrReviewerConflict", err)
+	}
+	resolved, err := uc.ResolveAppeal(appeal.ID, resolve, moderator("moderator-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.Status != AppealUpheld {
+		t.Errorf("appeal status = %s, want upheld", resolved.Status)
+	}
+	if _, err := uc.ResolveAppeal(appeal.ID, resolve, moderator("moderator-c")); !errors.Is(err, ErrAppealNotPending) {
+		t.Errorf("resolving a resolved appeal returned %v, want ErrAppealNotPending", err)
+	}
+
+	// An upheld appeal is final
+	if _, err := uc.FileAppeal(summary.ID, appealDto, author); !errors.Is(err, ErrAppealNotAllowed) {
+		t.Errorf("appealing an upheld rejection returned %v, want ErrAppealNotAllowed", err)
+	}
+
+	if actions := auditActions(t, uc, appeal.ID); strings.Join(actions, " ") != audit.ActionAppealFiled+" "+audit.ActionAppealResolved {
+		t.Errorf("appeal audit actions = %v", actions)
+	}
+}
+
+func TestAppealRejectionWithoutTransition(t *testing.T) {
+	uc, repo := newTestUseCase(t, 1, 1)
+	summary := createTestSummary(t, repo, StatusRejected)
+	if err := repo.db.Model(&Summary{}).Where("id = ?", summary.ID).Update("moderator_id", "moderator-a").Error; err != nil {
+		t.Fatal(err)
+	}
+	author := utils.Principal{UserID: summary.UserID, Role: models.RoleUser}
+
+	appeal, err := uc.FileAppeal(summary.ID, FileAppealDto{Justification: "The summary is backed by the linked report"}, author)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if appeal.DecisionID != summary.ID || appeal.OriginalModeratorID != "moderator-a" {
+		t.Errorf("appeal = %+v, want the summary as decision and moderator-a as moderator", appeal)
+	}
+
+	overturned, err := uc.ResolveAppeal(appeal.ID, ResolveAppealDto{Outcome: "overturn", Notes: "Sources check out"}, moderator("moderator-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overturned.Status != AppealOverturned {
+		t.Errorf("appeal status = %s, want overturned", overturned.Status)
+	}
+	decided, err := repo.GetSummaryByID(summary.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decided.Status != StatusApproved {
+		t.Errorf("summary status = %s, want approved", decided.Status)
+	}
+}
+
+func TestEditSummaryVersions(t *testing.T) {
+	uc, repo := newTestUseCase(t, 1, 1)
+	summary := createTestSummary(t, repo, StatusApproved)
+	editor := moderator("moderator-a")
+
+	edit, err := uc.EditSummary(summary.ID, EditSummaryDto{Content: "A corrected post", EditMessage: "Fix a typo", ExpectedVersion: 1}, editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if edit.Version != 2 {
+		t.Errorf("edit version = %d, want 2", edit.Version)
+	}
+
+	_, err = uc.EditSummary(summary.ID, EditSummaryDto{Content: "A concurrent edit", EditMessage: "Rewrite", ExpectedVersion: 1}, moderator("moderator-b"))
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Current != 2 {
+		t.Errorf("an edit of version 1 returned %v, want a conflict at version 2", err)
+	}
+
+	if _, err := uc.RevertSummary(summary.ID, 1, 0, editor); !errors.Is(err, ErrVersionRequired) {
+		t.Errorf("a revert without the expected version returned %v, want ErrVersionRequired", err)
+	}
+	reverted, err := uc.RevertSummary(summary.ID, 1, 2, editor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Version != 3 || reverted.Content != summary.Content {
+		t.Errorf("revert = %+v, want version 3 with the original content", reverted)
+	}
+
+	versions, err := uc.GetSummaryVersions(summary.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(versions) != 3 || versions[0].Version != 3 {
+		t.Errorf("versions = %+v, want 3 newest first", versions)
+	}
+
+	want := audit.ActionSummaryEdited + " " + audit.ActionSummaryReverted
+	if actions := auditActions(t, uc, summary.ID); strings.Join(actions, " ") != want {
+		t.Errorf("audit actions = %v, want %s", actions, want)
+	}
+}
+
+func TestSuggestEdit(t *testing.T) {
+	uc, repo := newTestUseCase(t, 1, 1)
+	summary := createTestSummary(t, repo, StatusApproved)
+	user := utils.Principal{UserID: "reader", Role: models.RoleUser}
+
+	if _, err := uc.SuggestEdit(summary.ID, SuggestEditDto{Content: summary.Content, EditMessage: "No change"}, user); !errors.Is(err, ErrSuggestionUnchanged) {
+		t.Errorf("an unchanged suggestion returned %v, want ErrSuggestionUnchanged", err)
+	}
+	suggestion, err := uc.SuggestEdit(summary.ID, SuggestEditDto{Content: "The post, clarified", EditMessage: "Clarify"}, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := uc.GetPendingSuggestions(summary.ID, moderator("moderator-a"), pagination.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending.Items) != 1 || pending.Items[0].ID != suggestion.ID || len(pending.Items[0].Words) == 0 {
+		t.Errorf("pending suggestions = %+v", pending.Items)
+	}
+
+	if _, err := uc.AcceptSuggestion(suggestion.ID, 0, user); !errors.Is(err, permissions.ErrForbidden) {
+		t.Errorf("a user accepting a suggestion returned %v, want ErrForbidden", err)
+	}
+	if _, err := uc.AcceptSuggestion(suggestion.ID, 0, moderator("moderator-a")); !errors.Is(err, ErrVersionRequired) {
+		t.Errorf("accepting without the expected version returned %v, want ErrVersionRequired", err)
+	}
+	if _, err := uc.AcceptSuggestion(suggestion.ID, 2, moderator("moderator-a")); !errors.Is(err, ErrVersionConflict) {
+		t.Errorf("accepting onto version 2 returned %v, want ErrVersionConflict", err)
+	}
+	accepted, err := uc.AcceptSuggestion(suggestion.ID, 1, moderator("moderator-a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.Version != 2 || accepted.EditedBy != user.UserID {
+		t.Errorf("accepted = %+v, want version 2 credited to the reader", accepted)
+	}
+	if _, err := uc.AcceptSuggestion(suggestion.ID, 0, moderator("moderator-b")); !errors.Is(err, ErrSuggestionNotPending) {
+		t.Errorf("accepting twice returned %v, want ErrSuggestionNotPending", err)
+	}
+
+	current, err := repo.GetSummaryByID(summary.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current.Content != "The post, clarified" || current.CurrentVersion != 2 {
+		t.Errorf("summary content %q at version %d", current.Content, current.CurrentVersion)
+	}
+}
+
+func TestAcceptStaleSuggestion(t *testing.T) {
+	uc, repo := newTestUseCase(t, 1, 1)
+	summary := createTestSummary(t, repo, StatusApproved)
+	user := utils.Principal{UserID: "reader", Role: models.RoleUser}
+
+	suggestion, err := uc.SuggestEdit(summary.ID, SuggestEditDto{Content: "The post, clarified", EditMessage: "Clarify"}, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.EditSummary(summary.ID, EditSummaryDto{Content: "A corrected post", EditMessage: "Fix a typo", ExpectedVersion: 1}, moderator("moderator-a")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Reviewed against its base version, the suggestion would silently undo the edit made since
+	_, err = uc.AcceptSuggestion(suggestion.ID, suggestion.BaseVersion, moderator("moderator-b"))
+	var conflict *VersionConflictError
+	if !errors.As(err, &conflict) || conflict.Current != 2 {
+		t.Fatalf("accepting a suggestion on version 1 returned %v, want a conflict at version 2", err)
+	}
+
+	accepted, err := uc.AcceptSuggestion(suggestion.ID, 2, moderator("moderator-b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accepted.Version != 3 || accepted.Content != "The post, clarified" {
+		t.Errorf("accepted = %+v, want version 3 with the suggested content", accepted)
+	}
+}
+
+func TestSuggestionDiffIsStored(t *testing.T) {
+	uc, repo := newTestUseCase(t, 1, 1)
+	summary := createTestSummary(t, repo, StatusApproved)
+
+	suggestion, err := uc.SuggestEdit(summary.ID, SuggestEditDto{Content: "The post, clarified", EditMessage: "Clarify"}, utils.Principal{UserID: "reader", Role: models.RoleUser})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := repo.GetSuggestionByID(suggestion.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Diff == nil || len(stored.Diff.Lines) == 0 || len(stored.Diff.Words) == 0 {
+		t.Fatalf("stored diff = %+v", stored.Diff)
+	}
+
+	// An edit made after the suggestion leaves its diff against the base version
+	if _, err := uc.EditSummary(summary.ID, EditSummaryDto{Content: "A moderator's rewrite", EditMessage: "Rewrite", ExpectedVersion: 1}, moderator("moderator-a")); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := uc.GetPendingSuggestions(summary.ID, moderator("moderator-a"), pagination.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending.Items) != 1 {
+		t.Fatalf("pending suggestions = %+v", pending.Items)
+	}
+	item := pending.Items[0]
+	if item.BaseVersion != 1 || item.CurrentVersion != 2 {
+		t.Errorf("base version %d, current version %d, want 1 and 2", item.BaseVersion, item.CurrentVersion)
+	}
+	var base strings.Builder
+	for _, chunk := range item.Words {
+		if chunk.Op != diff.Insert {
+			base.WriteString(chunk.Text)
+		}
+	}
+	if base.String() != summary.Content {
+		t.Errorf("the diff is against %q, want the base content %q", base.String(), summary.Content)
+	}
+}
@@ -0,0 +1,142 @@
+package summaries
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/diff"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+// InitialVersionMessage is the edit message of version 1, the content a summary was created with
+const InitialVersionMessage = "Initial version"
+
+var (
+	ErrVersionNotFound = errors.New("summary version not found")
+	ErrInvalidVersion  = errors.New("version must be a positive number")
+	ErrRevertUnchanged = errors.New("the version has the same content as the current one")
+	ErrVersionConflict = errors.New("summary was edited since the expected version")
+	ErrVersionRequired = errors.New("expected_version or an If-Match header is required")
+	ErrInvalidETag     = errors.New("If-Match must be the ETag of a summary version")
+)
+
+// VersionConflictError is returned when an edit was made on a version that is no longer the summary's
+// current version. Clients rebase their edit on Current and retry.
+type VersionConflictError struct {
+	Expected int
+	Current  int
+}
+
+func (e *VersionConflictError) Error() string {
+	return fmt.Sprintf("summary is at version %d, not at the expected version %d", e.Current, e.Expected)
+}
+
+// Is lets callers match any VersionConflictError with errors.Is(err, ErrVersionConflict)
+func (e *VersionConflictError) Is(target error) bool {
+	return target == ErrVersionConflict
+}
+
+// GetSummaryVersions returns every version of a summary, newest first. Summaries edited before versions
+// were recorded on creation have no version 1.
+func (uc *SummariesUseCase) GetSummaryVersions(id string) ([]SummaryEdit, error) {
+	if _, err := uc.repo.GetSummaryByID(id); err != nil {
+		return nil, err
+	}
+	return uc.repo.GetSummaryEdits(id)
+}
+
+// GetSummaryVersion returns one version of a summary
+func (uc *SummariesUseCase) GetSummaryVersion(id string, version int) (SummaryEdit, error) {
+	if _, err := uc.repo.GetSummaryByID(id); err != nil {
+		return SummaryEdit{}, err
+	}
+	return uc.repo.GetSummaryEdit(id, version)
+}
+
+// DiffSummaryVersions compares the content of two versions of a summary line by line and word by word
+func (uc *SummariesUseCase) DiffSummaryVersions(id string, from int, to int) (VersionDiff, error) {
+	fromVersion, err := uc.GetSummaryVersion(id, from)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+	toVersion, err := uc.repo.GetSummaryEdit(id, to)
+	if err != nil {
+		return VersionDiff{}, err
+	}
+
+	return VersionDiff{
+		SummaryID: id,
+		From:      from,
+		To:        to,
+		Lines:     diff.Lines(fromVersion.Content, toVersion.Content),
+		Words:     diff.Words(fromVersion.Content, toVersion.Content),
+	}, nil
+}
+
+// RevertSummary restores the content of an earlier version as a new version, so the history is kept.
+// expectedVersion is the version the revert was decided on; like an edit, a revert must name it.
+func (uc *SummariesUseCase) RevertSummary(id string, version int, expectedVersion int, user utils.Principal) (SummaryEdit, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryEdit, permissions.Resource{Type: permissions.ResourceSummary, ID: id}); err != nil {
+		return SummaryEdit{}, err
+	}
+
+	summary, err := uc.repo.GetSummaryByID(id)
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if err := checkEditable(summary.Status); err != nil {
+		return SummaryEdit{}, err
+	}
+
+	if expectedVersion == 0 {
+		return SummaryEdit{}, ErrVersionRequired
+	}
+	if summary.CurrentVersion != expectedVersion {
+		return SummaryEdit{}, &VersionConflictError{Expected: expectedVersion, Current: summary.CurrentVersion}
+	}
+
+	target, err := uc.repo.GetSummaryEdit(id, version)
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+	if target.Content == summary.Content {
+		return SummaryEdit{}, ErrRevertUnchanged
+	}
+
+	edit := SummaryEdit{
+		ID:          uuid.New().String(),
+		SummaryID:   id,
+		Content:     target.Content,
+		EditedBy:    user.UserID,
+		EditedAt:    time.Now(),
+		Version:     summary.CurrentVersion + 1,
+		EditMessage: fmt.Sprintf("Revert to version %d", version),
+		Status:      EditApplied,
+	}
+	err = uc.repo.Transaction(func(repo *SummariesRepository) error {
+		if err := repo.CreateSummaryVersion(edit); err != nil {
+			return err
+		}
+
+		_, err := uc.audit.Append(repo.db, user.UserID, audit.ActionSummaryReverted, audit.TargetSummary, id, map[string]interface{}{
+			"version":          edit.Version,
+			"reverted_version": version,
+		})
+		return err
+	})
+	if err != nil {
+		return SummaryEdit{}, err
+	}
+
+	ctx := context.Background()
+	uc.redis.Delete(ctx, fmt.Sprintf("summary:%s", id))
+	uc.indexSummary(ctx, id)
+
+	return edit, nil
+}
@@ -0,0 +1,115 @@
+package summaries
+
+import (
+	"errors"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+var ErrAlreadyVoted = errors.New("you have already voted on this summary")
+
+// quorum returns how many agreeing votes decide a summary and how many moderators may vote before it is
+// escalated. Without moderation.quorum a single vote decides, as before votes were introduced.
+func (uc *SummariesUseCase) quorum() (int, int) {
+	quorum := uc.config.Moderation.Quorum
+	if quorum < 1 {
+		quorum = 1
+	}
+	panelSize := uc.config.Moderation.PanelSize
+	if panelSize < quorum {
+		panelSize = quorum
+	}
+	return quorum, panelSize
+}
+
+func countVotes(votes []ModerationVote) (int, int) {
+	approvals, rejections := 0, 0
+	for _, vote := range votes {
+		switch vote.Decision {
+		case VoteApprove:
+			approvals++
+		case VoteReject:
+			rejections++
+		}
+	}
+	return approvals, rejections
+}
+
+// voteStatus returns the status a vote moves a summary to once it has the quorum
+func voteStatus(decision string) string {
+	if decision == VoteReject {
+		return StatusRejected
+	}
+	return StatusApproved
+}
+
+// quorumOutcome returns the status the votes decide: approved or rejected once either side reaches the
+// quorum, escalated once the remaining seats on the panel cannot give either side a quorum, and an empty
+// string while the vote is still open.
+func quorumOutcome(approvals int, rejections int, quorum int, panelSize int) string {
+	switch {
+	case approvals >= quorum:
+		return StatusApproved
+	case rejections >= quorum:
+		return StatusRejected
+	}
+
+	remaining := panelSize - approvals - rejections
+	if approvals+remaining < quorum && rejections+remaining < quorum {
+		return StatusEscalated
+	}
+	return ""
+}
+
+// GetSummaryVotes returns the moderators' votes on a summary
+func (uc *SummariesUseCase) GetSummaryVotes(id string, user utils.Principal) ([]ModerationVote, error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryModerate, permissions.Resource{Type: permissions.ResourceSummary, ID: id}); err != nil {
+		return nil, err
+	}
+
+	if _, err := uc.repo.GetSummaryByID(id); err != nil {
+		return nil, err
+	}
+	return uc.repo.GetVotes(id)
+}
+
+// GetEscalatedSummaries lists the summaries whose moderators disagreed, with their votes, for an admin to decide
+func (uc *SummariesUseCase) GetEscalatedSummaries(user utils.Principal, params pagination.Params) (pagination.Page[EscalatedSummary], error) {
+	if err := uc.policy.Authorize(user, permissions.SummaryOverride, permissions.Resource{}); err != nil {
+		return pagination.Page[EscalatedSummary]{}, err
+	}
+
+	page, err := uc.repo.GetEscalatedSummaries(params)
+	if err != nil {
+		return pagination.Page[EscalatedSummary]{}, err
+	}
+
+	ids := make([]string, 0, len(page.Items))
+	for _, summary := range page.Items {
+		ids = append(ids, summary.ID)
+	}
+	votes, err := uc.repo.GetVotesBySummaryIDs(ids)
+	if err != nil {
+		return pagination.Page[EscalatedSummary]{}, err
+	}
+	votesBySummary := map[string][]ModerationVote{}
+	for _, vote := range votes {
+		votesBySummary[vote.SummaryID] = append(votesBySummary[vote.SummaryID], vote)
+	}
+
+	escalated := pagination.Page[EscalatedSummary]{
+		Items:      make([]EscalatedSummary, 0, len(page.Items)),
+		NextCursor: page.NextCursor,
+		Total:      page.Total,
+	}
+	for _, summary := range page.Items {
+		summaryVotes := votesBySummary[summary.ID]
+		if summaryVotes == nil {
+			summaryVotes = []ModerationVote{}
+		}
+		escalated.Items = append(escalated.Items, EscalatedSummary{Summary: summary, Votes: summaryVotes})
+	}
+	return escalated, nil
+}
@@ -0,0 +1,70 @@
+package summaries
+
+import (
+	"testing"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+)
+
+func TestQuorumOutcome(t *testing.T) {
+	tests := []struct {
+		name       string
+		votes      []string
+		quorum     int
+		panelSize  int
+		wantStatus string
+	}{
+		{"single vote approves", []string{VoteApprove}, 1, 1, StatusApproved},
+		{"single vote rejects", []string{VoteReject}, 1, 1, StatusRejected},
+		{"2 of 3 after one vote", []string{VoteApprove}, 2, 3, ""},
+		{"2 of 3 split stays open", []string{VoteApprove, VoteReject}, 2, 3, ""},
+		{"2 of 3 approves A,R,A", []string{VoteApprove, VoteReject, VoteApprove}, 2, 3, StatusApproved},
+		{"2 of 3 rejects A,R,R", []string{VoteApprove, VoteReject, VoteReject}, 2, 3, StatusRejected},
+		{"2 of 3 agreeing votes", []string{VoteReject, VoteReject}, 2, 3, StatusRejected},
+		{"panel of quorum escalates a split", []string{VoteApprove, VoteReject}, 2, 2, StatusEscalated},
+		{"3 of 4 escalates once out of reach", []string{VoteApprove, VoteApprove, VoteReject, VoteReject}, 3, 4, StatusEscalated},
+		{"3 of 4 open while reachable", []string{VoteApprove, VoteApprove, VoteReject}, 3, 4, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var votes []ModerationVote
+			for _, decision := range tt.votes {
+				votes = append(votes, ModerationVote{Decision: decision})
+			}
+			approvals, rejections := countVotes(votes)
+			if status := quorumOutcome(approvals, rejections, tt.quorum, tt.panelSize); status != tt.wantStatus {
+				t.Errorf("quorumOutcome(%d, %d, %d, %d) = %q, want %q", approvals, rejections, tt.quorum, tt.panelSize, status, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestQuorum(t *testing.T) {
+	tests := []struct {
+		name          string
+		quorum        int
+		panelSize     int
+		wantQuorum    int
+		wantPanelSize int
+	}{
+		{"unset decides with one vote", 0, 0, 1, 1},
+		{"negative quorum defaults to one", -2, 3, 1, 3},
+		{"panel smaller than quorum grows to it", 3, 2, 3, 3},
+		{"configured", 2, 3, 2, 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg config.Config
+			cfg.Moderation.Quorum = tt.quorum
+			cfg.Moderation.PanelSize = tt.panelSize
+			uc := SummariesUseCase{config: cfg}
+
+			quorum, panelSize := uc.quorum()
+			if quorum != tt.wantQuorum || panelSize != tt.wantPanelSize {
+				t.Errorf("quorum() = %d, %d, want %d, %d", quorum, panelSize, tt.wantQuorum, tt.wantPanelSize)
+			}
+		})
+	}
+}
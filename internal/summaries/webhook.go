@@ -0,0 +1,136 @@
+package summaries
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/facebook"
+)
+
+// ActorWebhook is recorded as the author of summary requests created from page webhooks
+const ActorWebhook = "system:facebook-webhook"
+
+// webhookDedupeTTL is how long a delivered post is remembered; Facebook retries failed deliveries for up to a day
+const webhookDedupeTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvalidWebhookToken     = errors.New("invalid webhook verify token")
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+	ErrSummaryRequestNotFound  = errors.New("summary request not found")
+)
+
+// VerifyWebhookSubscription implements the hub.challenge handshake Facebook runs when a webhook is subscribed
+func (uc *SummariesUseCase) VerifyWebhookSubscription(mode string, token string, challenge string) (string, error) {
+	if mode != "subscribe" || uc.config.FacebookWebhookToken == "" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(uc.config.FacebookWebhookToken)) != 1 {
+		return "", ErrInvalidWebhookToken
+	}
+	return challenge, nil
+}
+
+// VerifyWebhookSignature checks that a webhook body was signed with the app secret
+func (uc *SummariesUseCase) VerifyWebhookSignature(body []byte, signature string) error {
+	if !facebook.VerifySignature(body, signature, uc.config.OpenGraphClientSecret) {
+		return ErrInvalidWebhookSignature
+	}
+	return nil
+}
+
+// isMonitoredPage reports whether requests should be created for posts on the page
+func (uc *SummariesUseCase) isMonitoredPage(pageID string) bool {
+	for _, page := range uc.config.FacebookMonitoredPages {
+		if page == pageID {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleFeedChange creates a summary request for a new post on a monitored page and reports whether it did.
+// Every post is only ever turned into one request, however many times Facebook delivers it.
+func (uc *SummariesUseCase) HandleFeedChange(ctx context.Context, pageID string, change facebook.FeedChange) (bool, error) {
+	if !change.IsNewPost() || !uc.isMonitoredPage(pageID) {
+		return false, nil
+	}
+
+	post, err := facebook.PostRefFromGraphID(change.PostID)
+	if err != nil {
+		return false, nil
+	}
+
+	// Claim the post first so concurrent deliveries of the same change don't both create a request
+	dedupeKey := fmt.Sprintf("webhook:facebook:post:%s", post.ID)
+	claimed, err := uc.redis.SetNX(ctx, dedupeKey, time.Now(), webhookDedupeTTL)
+	if err != nil {
+		return false, err
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	// The claim may have expired, so the database has the final say on whether the post was seen before.
+	// If it can't be asked, the claim is released and the error makes Facebook deliver the change again.
+	_, err = uc.repo.GetSummaryRequestByPostID(post.ID)
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, ErrSummaryRequestNotFound) {
+		uc.redis.Delete(ctx, dedupeKey)
+		return false, err
+	}
+
+	created, err := uc.createWebhookRequest(ctx, pageID, post, change)
+	if err != nil {
+		// Release the claim so Facebook's retry of this delivery can create the request
+		uc.redis.Delete(ctx, dedupeKey)
+		return false, err
+	}
+	return created, nil
+}
+
+func (uc *SummariesUseCase) createWebhookRequest(ctx context.Context, pageID string, post facebook.PostRef, change facebook.FeedChange) (bool, error) {
+	content := change.Message
+	metadata, err := json.Marshal(map[string]interface{}{
+		"source":       "facebook_webhook",
+		"page_id":      pageID,
+		"post_id":      change.PostID,
+		"item":         change.Item,
+		"author_id":    change.From.ID,
+		"author_name":  change.From.Name,
+		"link":         change.Link,
+		"created_time": time.Unix(change.CreatedTime, 0).UTC(),
+	})
+	if err != nil {
+		return false, err
+	}
+
+	// Photos and shares often arrive without a message, in which case the post is fetched from the Graph API
+	if content == "" {
+		var fetchedMetadata string
+		content, fetchedMetadata, err = uc.fetchPostContent(ctx, post)
+		if errors.Is(err, ErrEmptyPost) || errors.Is(err, ErrPostNotFound) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		metadata = []byte(fetchedMetadata)
+	}
+
+	_, err = uc.submitRequest(ctx, SummaryRequest{
+		Content:  content,
+		Metadata: string(metadata),
+		PostID:   post.ID,
+		PostURL:  post.CanonicalURL,
+		UserID:   ActorWebhook,
+		Status:   StatusPending,
+	})
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,215 @@
+package summaries
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/mwelwankuta/facebook-notes/pkg/audit"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+	"github.com/mwelwankuta/facebook-notes/pkg/facebook"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/queue"
+	"github.com/mwelwankuta/facebook-notes/pkg/search"
+)
+
+const (
+	testAppSecret   = "app-secret"
+	testVerifyToken = "verify-token"
+	testPageID      = "4"
+)
+
+type webhookServer struct {
+	e     *echo.Echo
+	uc    *SummariesUseCase
+	repo  *SummariesRepository
+	redis *miniredis.Miniredis
+	jobs  *queue.Queue
+}
+
+// newWebhookServer serves the webhook routes of a use case that monitors testPageID
+func newWebhookServer(t *testing.T, verifyToken string) webhookServer {
+	t.Helper()
+
+	repo := newTestRepository(t)
+	policy, err := permissions.NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg config.Config
+	cfg.OpenGraphClientSecret = testAppSecret
+	cfg.FacebookWebhookToken = verifyToken
+	cfg.FacebookMonitoredPages = []string{testPageID}
+
+	server := miniredis.RunT(t)
+	redis := adapters.NewRedisClient(server.Addr(), "", 0)
+	jobs := queue.NewQueue(redis, "summaries", queue.Options{})
+	uc := NewSummariesUseCase(*repo, cfg, redis, nil, jobs, audit.NewLog(repo.db), policy, search.NewMemoryIndex())
+
+	e := echo.New()
+	handler := NewSummariesHandler(*uc, "")
+	e.GET("/api/webhooks/facebook", handler.VerifyWebhookHandler)
+	e.POST("/api/webhooks/facebook", handler.ReceiveWebhookHandler)
+	return webhookServer{e: e, uc: uc, repo: repo, redis: server, jobs: jobs}
+}
+
+func sign(body string, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s webhookServer) deliver(body string, signature string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/api/webhooks/facebook", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	if signature != "" {
+		req.Header.Set(facebook.SignatureHeader, signature)
+	}
+	rec := httptest.NewRecorder()
+	s.e.ServeHTTP(rec, req)
+	return rec
+}
+
+// newPostEvent is a feed change adding the post "4_123" to the page
+func newPostEvent(pageID string) string {
+	return `{"object": "page", "entry": [{"id": "` + pageID + `", "time": 1714559400, "changes": [{"field": "feed", "value": {
+		"item": "status", "verb": "add", "post_id": "` + pageID + `_123", "message": "The moon landing was staged in a studio",
+		"created_time": 1714559400, "from": {"id": "` + pageID + `", "name": "Page"}
+	}}]}]}`
+}
+
+func TestVerifyWebhookSubscription(t *testing.T) {
+	tests := []struct {
+		name        string
+		verifyToken string
+		mode        string
+		token       string
+		status      int
+	}{
+		{"subscribe", testVerifyToken, "subscribe", testVerifyToken, http.StatusOK},
+		{"wrong verify token", testVerifyToken, "subscribe", "guess", http.StatusForbidden},
+		{"no verify token", testVerifyToken, "subscribe", "", http.StatusForbidden},
+		{"other mode", testVerifyToken, "unsubscribe", testVerifyToken, http.StatusForbidden},
+		{"verify token not configured", "", "subscribe", "", http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newWebhookServer(t, test.verifyToken)
+			query := url.Values{"hub.mode": {test.mode}, "hub.verify_token": {test.token}, "hub.challenge": {"1158201444"}}
+			req := httptest.NewRequest(http.MethodGet, "/api/webhooks/facebook?"+query.Encode(), nil)
+			rec := httptest.NewRecorder()
+			server.e.ServeHTTP(rec, req)
+
+			if rec.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+			if test.status == http.StatusOK && rec.Body.String() != "1158201444" {
+				t.Errorf("body = %q, want the challenge", rec.Body)
+			}
+		})
+	}
+}
+
+func TestReceiveWebhookSignature(t *testing.T) {
+	body := newPostEvent(testPageID)
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		status    int
+	}{
+		{"unsigned", body, "", http.StatusUnauthorized},
+		{"signed with another secret", body, sign(body, "other-secret"), http.StatusUnauthorized},
+		{"signature of another body", body, sign(newPostEvent("5"), testAppSecret), http.StatusUnauthorized},
+		{"malformed signature", body, "sha1=abc", http.StatusUnauthorized},
+		{"signed", body, sign(body, testAppSecret), http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newWebhookServer(t, testVerifyToken)
+			rec := server.deliver(test.body, test.signature)
+			if rec.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, test.status, rec.Body)
+			}
+
+			stats, err := server.jobs.Stats(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if created := test.status == http.StatusOK; (stats.Ready == 1) != created {
+				t.Errorf("%d jobs queued, want a request created only for the signed delivery", stats.Ready)
+			}
+		})
+	}
+}
+
+func TestReceiveWebhookIgnoresOtherChanges(t *testing.T) {
+	server := newWebhookServer(t, testVerifyToken)
+	comment := strings.Replace(newPostEvent(testPageID), `"item": "status"`, `"item": "comment"`, 1)
+
+	for _, body := range []string{newPostEvent("5"), comment} {
+		rec := server.deliver(body, sign(body, testAppSecret))
+		if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"created":0`) {
+			t.Errorf("delivery returned %d %s, want nothing created", rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestReceiveWebhookDuplicateDelivery(t *testing.T) {
+	server := newWebhookServer(t, testVerifyToken)
+	body := newPostEvent(testPageID)
+
+	if rec := server.deliver(body, sign(body, testAppSecret)); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"created":1`) {
+		t.Fatalf("first delivery returned %d %s, want a request created", rec.Code, rec.Body)
+	}
+	if rec := server.deliver(body, sign(body, testAppSecret)); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"created":0`) {
+		t.Errorf("redelivery returned %d %s, want nothing created", rec.Code, rec.Body)
+	}
+
+	// Once the claim expired, the stored request still stops the post from being requested again
+	server.redis.FlushAll()
+	if rec := server.deliver(body, sign(body, testAppSecret)); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"created":0`) {
+		t.Errorf("redelivery after the claim expired returned %d %s, want nothing created", rec.Code, rec.Body)
+	}
+
+	post, err := facebook.PostRefFromGraphID(testPageID + "_123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	request, err := server.repo.GetSummaryRequestByPostID(post.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if request.UserID != ActorWebhook || request.PostURL != post.CanonicalURL {
+		t.Errorf("request = %+v", request)
+	}
+}
+
+func TestReceiveWebhookLookupError(t *testing.T) {
+	server := newWebhookServer(t, testVerifyToken)
+	body := newPostEvent(testPageID)
+
+	sqlDB, err := server.repo.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.Close()
+
+	// Facebook only delivers the change again if the webhook fails
+	if rec := server.deliver(body, sign(body, testAppSecret)); rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500 when the request lookup fails", rec.Code)
+	}
+	if keys := server.redis.Keys(); len(keys) != 0 {
+		t.Errorf("redis keys = %v, want the claim released for the retry", keys)
+	}
+}
@@ -1,37 +1,66 @@
 package adapters
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strings"
+	"time"
 
 	"github.com/mwelwankuta/facebook-notes/pkg/config"
 	"github.com/mwelwankuta/facebook-notes/pkg/models"
 )
 
-// GetFacebookUserAccessToken gets the access token from Facebook
-func GetFacebookUserAccessToken(code string, clientID string, clientSecret string) (string, error) {
+// GetFacebookUserAccessToken exchanges an authorization code for an access token. redirectURI must match the
+// one the login dialog was opened with, and codeVerifier is the PKCE verifier of the challenge sent to it.
+// tokenURL defaults to the Graph API's token endpoint.
+func GetFacebookUserAccessToken(tokenURL string, code string, clientID string, clientSecret string, redirectURI string, codeVerifier string) (models.FacebookAccessToken, error) {
 	tokenReqURL := fmt.Sprintf(
-		"%s?client_id=%s&redirect_uri=%s&client_secret=%s&code=%s",
-		config.FbTokenURL, clientID, url.QueryEscape(config.RedirectURI), clientSecret, code,
+		"%s?client_id=%s&redirect_uri=%s&client_secret=%s&code=%s&code_verifier=%s",
+		facebookTokenURL(tokenURL), clientID, url.QueryEscape(redirectURI), clientSecret, url.QueryEscape(code), url.QueryEscape(codeVerifier),
 	)
+	return requestFacebookAccessToken(tokenReqURL)
+}
+
+// ExchangeLongLivedToken exchanges a user access token for a long-lived one, which lasts about 60 days.
+// Exchanging a long-lived token that hasn't expired yet returns a token with a fresh expiry.
+// tokenURL defaults to the Graph API's token endpoint.
+func ExchangeLongLivedToken(tokenURL string, accessToken string, clientID string, clientSecret string) (models.FacebookAccessToken, error) {
+	tokenReqURL := fmt.Sprintf(
+		"%s?grant_type=fb_exchange_token&client_id=%s&client_secret=%s&fb_exchange_token=%s",
+		facebookTokenURL(tokenURL), clientID, clientSecret, url.QueryEscape(accessToken),
+	)
+	return requestFacebookAccessToken(tokenReqURL)
+}
+
+// facebookTokenURL returns tokenURL, or the Graph API's token endpoint when it is empty. Pointing it
+// elsewhere, such as at an httptest server, runs the exchanges against a stub.
+func facebookTokenURL(tokenURL string) string {
+	if tokenURL == "" {
+		return config.FbTokenURL
+	}
+	return tokenURL
+}
+
+func requestFacebookAccessToken(tokenReqURL string) (models.FacebookAccessToken, error) {
+	var token models.FacebookAccessToken
+
 	resp, err := http.Get(tokenReqURL)
 	if err != nil {
-		return "", fmt.Errorf("Failed to get access token")
+		return token, fmt.Errorf("Failed to get access token")
 	}
 	defer resp.Body.Close()
 
 	body, _ := ioutil.ReadAll(resp.Body)
-	var tokenResponse map[string]interface{}
-	json.Unmarshal(body, &tokenResponse)
-
-	accessToken, ok := tokenResponse["access_token"].(string)
-	if !ok {
-		return "", fmt.Errorf("Failed to parse access token")
+	if err := json.Unmarshal(body, &token); err != nil || token.AccessToken == "" {
+		return token, fmt.Errorf("Failed to parse access token")
 	}
-	return accessToken, nil
+	return token, nil
 }
 
 // FetchUserProfile fetches the user profile from Facebook
@@ -53,3 +82,141 @@ func FetchUserProfile(accessToken string) (models.FacebookUser, error) {
 
 	return userDto, nil
 }
+
+// graphTimeLayout is the timestamp format used by the Graph API
+const graphTimeLayout = "2006-01-02T15:04:05-0700"
+
+const graphPostFields = "id,message,permalink_url,created_time,from{id,name},attachments{type,title,description,url,media,subattachments}"
+
+var ErrFacebookPostNotFound = errors.New("facebook post not found")
+
+// FacebookGraphClient reads objects from the Graph API with a page or app access token
+type FacebookGraphClient struct {
+	baseURL     string
+	accessToken string
+	httpClient  *http.Client
+}
+
+type graphAttachment struct {
+	models.FacebookPostAttachment
+	Subattachments struct {
+		Data []models.FacebookPostAttachment `json:"data"`
+	} `json:"subattachments"`
+}
+
+type graphPost struct {
+	ID           string `json:"id"`
+	Message      string `json:"message"`
+	PermalinkURL string `json:"permalink_url"`
+	CreatedTime  string `json:"created_time"`
+	From         struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"from"`
+	Attachments struct {
+		Data []graphAttachment `json:"data"`
+	} `json:"attachments"`
+}
+
+type graphError struct {
+	Error *struct {
+		Message string `json:"message"`
+		Code    int    `json:"code"`
+	} `json:"error,omitempty"`
+}
+
+// NewFacebookGraphClient creates a new FacebookGraphClient. baseURL defaults to the public Graph API,
+// and can point anywhere that serves the same API, such as an httptest server.
+func NewFacebookGraphClient(baseURL string, accessToken string) *FacebookGraphClient {
+	if baseURL == "" {
+		baseURL = config.FbGraphURL
+	}
+
+	return &FacebookGraphClient{
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		accessToken: accessToken,
+		httpClient:  &http.Client{Timeout: 15 * time.Second},
+	}
+}
+
+// GetPost fetches a post's message, attachments, author and created time
+func (f *FacebookGraphClient) GetPost(ctx context.Context, postID string) (models.FacebookPost, error) {
+	var post models.FacebookPost
+
+	var raw graphPost
+	if err := f.getNode(ctx, postID, graphPostFields, &raw); err != nil {
+		return post, err
+	}
+
+	post.ID = raw.ID
+	post.Message = raw.Message
+	post.PermalinkURL = raw.PermalinkURL
+	post.From.ID = raw.From.ID
+	post.From.Name = raw.From.Name
+	if raw.CreatedTime != "" {
+		if createdTime, err := time.Parse(graphTimeLayout, raw.CreatedTime); err == nil {
+			post.CreatedTime = createdTime
+		}
+	}
+
+	// Albums nest their photos as subattachments, which are flattened into the post's attachments
+	for _, attachment := range raw.Attachments.Data {
+		post.Attachments = append(post.Attachments, attachment.FacebookPostAttachment)
+		post.Attachments = append(post.Attachments, attachment.Subattachments.Data...)
+	}
+
+	return post, nil
+}
+
+// GetObjectID returns the numeric ID of a page or profile given by its username
+func (f *FacebookGraphClient) GetObjectID(ctx context.Context, username string) (string, error) {
+	var object struct {
+		ID string `json:"id"`
+	}
+	if err := f.getNode(ctx, username, "id", &object); err != nil {
+		return "", err
+	}
+	return object.ID, nil
+}
+
+// getNode reads the fields of a node into dest. Nodes that don't exist or aren't visible to the token
+// return ErrFacebookPostNotFound.
+func (f *FacebookGraphClient) getNode(ctx context.Context, node string, fields string, dest interface{}) error {
+	nodeURL := fmt.Sprintf("%s/%s?fields=%s&access_token=%s",
+		f.baseURL, url.PathEscape(node), url.QueryEscape(fields), url.QueryEscape(f.accessToken))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, nodeURL, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Failed to fetch facebook object")
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var graphErr graphError
+	if err := json.Unmarshal(body, &graphErr); err != nil {
+		return fmt.Errorf("Failed to parse facebook object")
+	}
+	if graphErr.Error != nil {
+		// Code 100 is returned for IDs that don't exist or aren't visible to the token
+		if graphErr.Error.Code == 100 || resp.StatusCode == http.StatusNotFound {
+			return ErrFacebookPostNotFound
+		}
+		return fmt.Errorf("Graph API returned %d: %s", resp.StatusCode, graphErr.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Graph API returned %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, dest); err != nil {
+		return fmt.Errorf("Failed to parse facebook object")
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package adapters
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newGraphServer(t *testing.T, handler http.HandlerFunc) *FacebookGraphClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewFacebookGraphClient(server.URL, "app|secret")
+}
+
+func TestGetPost(t *testing.T) {
+	client := newGraphServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/4_123" {
+			t.Errorf("requested %s, want /4_123", r.URL.Path)
+		}
+		if got := r.URL.Query().Get("access_token"); got != "app|secret" {
+			t.Errorf("access_token = %q", got)
+		}
+		if got := r.URL.Query().Get("fields"); got != graphPostFields {
+			t.Errorf("fields = %q", got)
+		}
+
+		w.Write([]byte(`{
+			"id": "4_123",
+			"message": "Hello",
+			"permalink_url": "https://www.facebook.com/4/posts/123",
+			"created_time": "2024-05-01T10:30:00+0000",
+			"from": {"id": "4", "name": "Page"},
+			"attachments": {"data": [
+				{"type": "album", "title": "Album", "subattachments": {"data": [
+					{"type": "photo", "description": "First"},
+					{"type": "photo", "description": "Second"}
+				]}}
+			]}
+		}`))
+	})
+
+	post, err := client.GetPost(context.Background(), "4_123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if post.ID != "4_123" || post.Message != "Hello" || post.From.ID != "4" || post.From.Name != "Page" {
+		t.Errorf("post = %+v", post)
+	}
+	if want := time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC); !post.CreatedTime.Equal(want) {
+		t.Errorf("CreatedTime = %v, want %v", post.CreatedTime, want)
+	}
+
+	// The album and both of its photos
+	if len(post.Attachments) != 3 {
+		t.Fatalf("got %d attachments, want 3", len(post.Attachments))
+	}
+	if post.Attachments[0].Title != "Album" || post.Attachments[2].Description != "Second" {
+		t.Errorf("attachments = %+v", post.Attachments)
+	}
+}
+
+func TestGetPostErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		body     string
+		notFound bool
+		message  string
+	}{
+		{
+			name:     "unknown id",
+			status:   http.StatusBadRequest,
+			body:     `{"error": {"message": "Unsupported get request", "code": 100}}`,
+			notFound: true,
+		},
+		{
+			name:     "not found status",
+			status:   http.StatusNotFound,
+			body:     `{"error": {"message": "Not found", "code": 803}}`,
+			notFound: true,
+		},
+		{
+			name:    "expired token",
+			status:  http.StatusBadRequest,
+			body:    `{"error": {"message": "Session has expired", "code": 190}}`,
+			message: "Session has expired",
+		},
+		{
+			name:    "server error",
+			status:  http.StatusInternalServerError,
+			body:    `{}`,
+			message: "500",
+		},
+		{
+			name:    "invalid json",
+			status:  http.StatusOK,
+			body:    `<html>`,
+			message: "Failed to parse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newGraphServer(t, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			})
+
+			_, err := client.GetPost(context.Background(), "4_123")
+			if err == nil {
+				t.Fatal("GetPost returned no error")
+			}
+			if got := errors.Is(err, ErrFacebookPostNotFound); got != tt.notFound {
+				t.Errorf("error %v is ErrFacebookPostNotFound: %v, want %v", err, got, tt.notFound)
+			}
+			if tt.message != "" && !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("error %q does not mention %q", err, tt.message)
+			}
+		})
+	}
+}
+
+func TestGetObjectID(t *testing.T) {
+	client := newGraphServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/zuck" || r.URL.Query().Get("fields") != "id" {
+			t.Errorf("requested %s", r.URL)
+		}
+		w.Write([]byte(`{"id": "4"}`))
+	})
+
+	id, err := client.GetObjectID(context.Background(), "zuck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "4" {
+		t.Errorf("id = %q, want 4", id)
+	}
+}
+
+func TestExchangeLongLivedToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("grant_type") != "fb_exchange_token" || query.Get("fb_exchange_token") != "short+lived" {
+			t.Errorf("query = %v", query)
+		}
+		if query.Get("client_id") != "client" || query.Get("client_secret") != "secret" {
+			t.Errorf("client credentials = %q, %q", query.Get("client_id"), query.Get("client_secret"))
+		}
+		w.Write([]byte(`{"access_token": "long-lived", "token_type": "bearer", "expires_in": 5184000}`))
+	}))
+	defer server.Close()
+
+	token, err := ExchangeLongLivedToken(server.URL, "short+lived", "client", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "long-lived" || token.ExpiresIn != 5184000 {
+		t.Errorf("token = %+v", token)
+	}
+}
+
+func TestExchangeLongLivedTokenError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "Error validating access token", "code": 190}}`))
+	}))
+	defer server.Close()
+
+	if _, err := ExchangeLongLivedToken(server.URL, "expired", "client", "secret"); err == nil {
+		t.Error("an error response returned a token")
+	}
+}
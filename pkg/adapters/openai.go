@@ -0,0 +1,114 @@
+package adapters
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+)
+
+const summarizePrompt = "You write short, neutral community notes. Summarize the following content in at most three sentences. Do not add opinions or facts that are not in the content."
+
+// OpenAIClient calls any API that implements the OpenAI chat completions endpoint
+type OpenAIClient struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	httpClient *http.Client
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatCompletionRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatCompletionResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+// NewOpenAIClient creates a new OpenAIClient, using the public OpenAI API and default model when left empty
+func NewOpenAIClient(baseURL, apiKey, model string) *OpenAIClient {
+	if baseURL == "" {
+		baseURL = config.OpenAIBaseURL
+	}
+	if model == "" {
+		model = config.OpenAIModel
+	}
+
+	return &OpenAIClient{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		apiKey:     apiKey,
+		model:      model,
+		httpClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Summarize asks the model for a short summary of content
+func (o *OpenAIClient) Summarize(ctx context.Context, content string) (string, error) {
+	body, err := json.Marshal(chatCompletionRequest{
+		Model: o.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: summarizePrompt},
+			{Role: "user", Content: content},
+		},
+		Temperature: 0.2,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.baseURL+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if o.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+o.apiKey)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Failed to call summarization API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+
+	var completion chatCompletionResponse
+	if err := json.Unmarshal(respBody, &completion); err != nil {
+		return "", fmt.Errorf("Failed to parse summarization response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		if completion.Error != nil {
+			return "", fmt.Errorf("Summarization API returned %d: %s", resp.StatusCode, completion.Error.Message)
+		}
+		return "", fmt.Errorf("Summarization API returned %d", resp.StatusCode)
+	}
+
+	if len(completion.Choices) == 0 {
+		return "", fmt.Errorf("Summarization API returned no choices")
+	}
+
+	return strings.TrimSpace(completion.Choices[0].Message.Content), nil
+}
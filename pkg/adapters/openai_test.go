@@ -0,0 +1,103 @@
+package adapters
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+)
+
+func newOpenAIServer(t *testing.T, apiKey string, handler http.HandlerFunc) *OpenAIClient {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	// A trailing slash on the base URL is tolerated
+	return NewOpenAIClient(server.URL+"/v1/", apiKey, "test-model")
+}
+
+func TestOpenAISummarize(t *testing.T) {
+	client := newOpenAIServer(t, "sk-test", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/chat/completions" {
+			t.Errorf("requested %s %s, want POST /v1/chat/completions", r.Method, r.URL.Path)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer sk-test" {
+			t.Errorf("Authorization = %q", got)
+		}
+
+		var request chatCompletionRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+		if request.Model != "test-model" || len(request.Messages) != 2 {
+			t.Fatalf("request = %+v", request)
+		}
+		if request.Messages[0].Role != "system" || request.Messages[0].Content != summarizePrompt {
+			t.Errorf("system message = %+v", request.Messages[0])
+		}
+		if request.Messages[1].Role != "user" || request.Messages[1].Content != "The post to summarize." {
+			t.Errorf("user message = %+v", request.Messages[1])
+		}
+
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "  A short summary.\n"}}]}`))
+	})
+
+	summary, err := client.Summarize(context.Background(), "The post to summarize.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary != "A short summary." {
+		t.Errorf("summary = %q", summary)
+	}
+}
+
+func TestOpenAISummarizeWithoutKey(t *testing.T) {
+	// Self-hosted OpenAI compatible servers often don't take a key
+	client := newOpenAIServer(t, "", func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "" {
+			t.Errorf("Authorization = %q, want none", got)
+		}
+		w.Write([]byte(`{"choices": [{"message": {"role": "assistant", "content": "Summary"}}]}`))
+	})
+
+	if _, err := client.Summarize(context.Background(), "content"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenAISummarizeErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"api error", http.StatusTooManyRequests, `{"error": {"message": "Rate limit reached"}}`, "429: Rate limit reached"},
+		{"error without a message", http.StatusBadGateway, `{}`, "returned 502"},
+		{"no choices", http.StatusOK, `{"choices": []}`, "no choices"},
+		{"not json", http.StatusOK, `<html>`, "Failed to parse"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newOpenAIServer(t, "sk-test", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			})
+
+			_, err := client.Summarize(context.Background(), "content")
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("Summarize returned %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestNewOpenAIClientDefaults(t *testing.T) {
+	client := NewOpenAIClient("", "sk-test", "")
+	if client.baseURL != config.OpenAIBaseURL || client.model != config.OpenAIModel {
+		t.Errorf("client uses %s with %s, want the public API and default model", client.baseURL, client.model)
+	}
+}
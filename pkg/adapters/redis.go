@@ -42,6 +42,31 @@ func (r *RedisClient) Get(ctx context.Context, key string, dest interface{}) err
 	return json.Unmarshal([]byte(val), dest)
 }
 
+// GetDel reads and deletes a key in one step, so the value can only ever be read once
+func (r *RedisClient) GetDel(ctx context.Context, key string, dest interface{}) error {
+	val, err := r.client.GetDel(ctx, key).Result()
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal([]byte(val), dest)
+}
+
+// SetNX sets the key only if it does not exist yet, and reports whether it was set
+func (r *RedisClient) SetNX(ctx context.Context, key string, value interface{}, expiration time.Duration) (bool, error) {
+	json, err := json.Marshal(value)
+	if err != nil {
+		return false, err
+	}
+
+	return r.client.SetNX(ctx, key, json, expiration).Result()
+}
+
 func (r *RedisClient) Delete(ctx context.Context, key string) error {
 	return r.client.Del(ctx, key).Err()
 }
+
+// Client returns the underlying redis client for callers that need commands beyond the JSON helpers
+func (r *RedisClient) Client() *redis.Client {
+	return r.client
+}
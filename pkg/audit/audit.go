@@ -0,0 +1,364 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ActionUserRoleChanged    = "user.role_changed"
+	ActionUserStatusChanged  = "user.status_changed"
+	ActionSummaryModerated   = "summary.moderated"
+	ActionSummaryVoted       = "summary.voted"
+	ActionSummaryEdited      = "summary.edited"
+	ActionSummaryReverted    = "summary.reverted"
+	ActionResourceAdded      = "resource.added"
+	ActionResourceRemoved    = "resource.removed"
+	ActionAppealFiled        = "appeal.filed"
+	ActionAppealResolved     = "appeal.resolved"
+	ActionSuggestionFiled    = "suggestion.filed"
+	ActionSuggestionAccepted = "suggestion.accepted"
+	ActionSuggestionDeclined = "suggestion.declined"
+
+	TargetUser         = "user"
+	TargetSummary      = "summary"
+	TargetResourceLink = "resource_link"
+	TargetAppeal       = "appeal"
+	TargetSuggestion   = "suggestion"
+
+	// genesisHash is the previous hash of the first entry in the chain
+	genesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+	maxAppendAttempts = 5
+	verifyBatchSize   = 500
+)
+
+var (
+	ErrAppendConflict = errors.New("could not append audit entry after repeated conflicts")
+	errHeadMoved      = errors.New("the head of the audit chain moved")
+)
+
+// Entry is a single append-only audit record. Every entry stores the hash of the entry before it,
+// so removing or changing any entry breaks the chain from that point on.
+type Entry struct {
+	Sequence   uint64    `json:"sequence" gorm:"primarykey;autoIncrement:false"`
+	ActorID    string    `json:"actor_id" gorm:"index"`
+	Action     string    `json:"action" gorm:"index"`
+	TargetType string    `json:"target_type"`
+	TargetID   string    `json:"target_id" gorm:"index"`
+	Details    string    `json:"details"`
+	CreatedAt  time.Time `json:"created_at"`
+	PrevHash   string    `json:"prev_hash"`
+	Hash       string    `json:"hash"`
+}
+
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// hashedEntry is what an entry's hash is computed over. Encoding it as JSON keeps the fields apart, so no
+// text can move from one field to the next without changing the hash.
+type hashedEntry struct {
+	Sequence   uint64 `json:"sequence"`
+	PrevHash   string `json:"prev_hash"`
+	ActorID    string `json:"actor_id"`
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id"`
+	Details    string `json:"details"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// computeHash hashes every field of the entry together with the previous hash
+func (e Entry) computeHash() string {
+	encoded, _ := json.Marshal(hashedEntry{
+		Sequence:   e.Sequence,
+		PrevHash:   e.PrevHash,
+		ActorID:    e.ActorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Details:    e.Details,
+		CreatedAt:  e.CreatedAt.UTC().Format(time.RFC3339),
+	})
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// Head is the newest entry of the chain. It is kept in its own table, updated with every append, so
+// deleting the newest entries is noticed: the chain no longer ends at the head.
+type Head struct {
+	ID       int    `json:"-" gorm:"primarykey;autoIncrement:false"`
+	Sequence uint64 `json:"sequence"`
+	Hash     string `json:"hash"`
+}
+
+func (Head) TableName() string {
+	return "audit_head"
+}
+
+// headID is the ID of the single row of the audit_head table
+const headID = 1
+
+// Filter narrows down a query of the audit log. Empty fields match everything.
+type Filter struct {
+	ActorID    string
+	Action     string
+	TargetType string
+	TargetID   string
+}
+
+// Log is the append-only audit log stored in the audit_entries table
+type Log struct {
+	db *gorm.DB
+}
+
+func NewLog(db *gorm.DB) *Log {
+	return &Log{db: db}
+}
+
+// Append adds an entry to the end of the chain as part of tx, the transaction that makes the change being
+// recorded, so the entry is only kept when the change is committed. details is stored as JSON.
+func (l *Log) Append(tx *gorm.DB, actorID string, action string, targetType string, targetID string, details interface{}) (Entry, error) {
+	encoded, err := json.Marshal(details)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	for attempt := 0; attempt < maxAppendAttempts; attempt++ {
+		var entry Entry
+		// Every attempt runs in a savepoint, so a failed insert can be retried without aborting tx
+		err := tx.Transaction(func(tx *gorm.DB) error {
+			var head Head
+			// A locking read sees the newest committed head instead of the snapshot tx started with
+			result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", headID).Limit(1).Find(&head)
+			if result.Error != nil {
+				return result.Error
+			}
+
+			entry = Entry{
+				Sequence:   1,
+				ActorID:    actorID,
+				Action:     action,
+				TargetType: targetType,
+				TargetID:   targetID,
+				Details:    string(encoded),
+				// Stored with second precision so the hash survives a round trip through any database
+				CreatedAt: time.Now().UTC().Truncate(time.Second),
+				PrevHash:  genesisHash,
+			}
+			if result.RowsAffected > 0 {
+				entry.Sequence = head.Sequence + 1
+				entry.PrevHash = head.Hash
+			}
+			entry.Hash = entry.computeHash()
+
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+
+			if result.RowsAffected == 0 {
+				return tx.Create(&Head{ID: headID, Sequence: entry.Sequence, Hash: entry.Hash}).Error
+			}
+			// Moving the head only from where it was read catches appends the locking read did not block
+			moved := tx.Model(&Head{}).Where("id = ? AND sequence = ?", headID, head.Sequence).Updates(map[string]interface{}{
+				"sequence": entry.Sequence,
+				"hash":     entry.Hash,
+			})
+			if moved.Error != nil {
+				return moved.Error
+			}
+			if moved.RowsAffected == 0 {
+				return errHeadMoved
+			}
+			return nil
+		})
+		if err == nil {
+			return entry, nil
+		}
+
+		// The sequence is the primary key, so a concurrent append of the same sequence fails and is retried
+		if !errors.Is(err, errHeadMoved) {
+			var existing Entry
+			result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sequence = ?", entry.Sequence).Limit(1).Find(&existing)
+			if result.Error != nil || result.RowsAffected == 0 {
+				return Entry{}, err
+			}
+		}
+	}
+
+	return Entry{}, ErrAppendConflict
+}
+
+// chainOrder lists entries newest first in the order of the chain. The sequence is unique, so the
+// creation time never breaks a tie.
+var chainOrder = pagination.Order{Rank: "sequence", Time: "created_at", ID: "sequence"}
+
+func entryCursor(entry Entry) pagination.Cursor {
+	return pagination.Cursor{Rank: float64(entry.Sequence), Time: entry.CreatedAt, ID: strconv.FormatUint(entry.Sequence, 10)}
+}
+
+// Query returns a page of the entries matching the filter, newest first
+func (l *Log) Query(filter Filter, params pagination.Params) (pagination.Page[Entry], error) {
+	query := l.db.Model(&Entry{})
+	if filter.ActorID != "" {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+
+	return pagination.FindOrdered(query, params, chainOrder, entryCursor)
+}
+
+const (
+	ProblemGap        = "gap"
+	ProblemBrokenLink = "broken_link"
+	ProblemTampered   = "tampered"
+	ProblemTruncated  = "truncated"
+)
+
+// Problem describes an entry that breaks the chain
+type Problem struct {
+	Sequence uint64 `json:"sequence"`
+	Kind     string `json:"kind"`
+	Message  string `json:"message"`
+}
+
+// VerifyReport is the outcome of walking the whole chain. Head is the newest entry the chain should end
+// at; publishing it elsewhere lets a later check notice a rewrite of both the entries and the head.
+type VerifyReport struct {
+	Entries  int       `json:"entries"`
+	Head     Head      `json:"head"`
+	Problems []Problem `json:"problems"`
+}
+
+// OK reports whether the chain is intact
+func (r VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// Verify walks the chain from the first entry and reports missing sequences, entries whose
+// previous hash does not match the entry before them, entries whose content no longer matches their hash
+// and a chain that does not end at the head
+func (l *Log) Verify() (VerifyReport, error) {
+	var report VerifyReport
+	expectedSequence := uint64(1)
+	previousHash := genesisHash
+
+	// The head is read first: entries appended while the chain is walked only make it longer
+	head := l.db.Where("id = ?", headID).Limit(1).Find(&report.Head)
+	if head.Error != nil {
+		return VerifyReport{}, head.Error
+	}
+	if head.RowsAffected == 0 {
+		report.Head.Hash = genesisHash
+	}
+
+	var batch []Entry
+	err := l.db.Order("sequence asc").FindInBatches(&batch, verifyBatchSize, func(tx *gorm.DB, _ int) error {
+		for _, entry := range batch {
+			report.Entries++
+
+			if entry.Sequence != expectedSequence {
+				report.Problems = append(report.Problems, Problem{
+					Sequence: entry.Sequence,
+					Kind:     ProblemGap,
+					Message:  fmt.Sprintf("expected sequence %d, found %d", expectedSequence, entry.Sequence),
+				})
+			}
+			if entry.PrevHash != previousHash {
+				report.Problems = append(report.Problems, Problem{
+					Sequence: entry.Sequence,
+					Kind:     ProblemBrokenLink,
+					Message:  "previous hash does not match the hash of the entry before it",
+				})
+			}
+			if entry.computeHash() != entry.Hash {
+				report.Problems = append(report.Problems, Problem{
+					Sequence: entry.Sequence,
+					Kind:     ProblemTampered,
+					Message:  "entry content does not match its hash",
+				})
+			}
+
+			if entry.Sequence == report.Head.Sequence && entry.Hash != report.Head.Hash {
+				report.Problems = append(report.Problems, Problem{
+					Sequence: entry.Sequence,
+					Kind:     ProblemTampered,
+					Message:  "entry hash does not match the head of the chain",
+				})
+			}
+
+			expectedSequence = entry.Sequence + 1
+			previousHash = entry.Hash
+		}
+		return nil
+	}).Error
+	if err != nil {
+		return VerifyReport{}, err
+	}
+
+	if head.RowsAffected == 0 && report.Entries > 0 {
+		report.Problems = append(report.Problems, Problem{
+			Kind:    ProblemTruncated,
+			Message: "the head of the chain is missing",
+		})
+	}
+	if expectedSequence <= report.Head.Sequence {
+		report.Problems = append(report.Problems, Problem{
+			Sequence: report.Head.Sequence,
+			Kind:     ProblemTruncated,
+			Message:  fmt.Sprintf("chain ends at sequence %d, before its head at %d", expectedSequence-1, report.Head.Sequence),
+		})
+	}
+
+	return report, nil
+}
+
+// VerifyAgainst verifies the chain like Verify and also reports a chain that does not contain the head
+// published by an earlier check: the log was rolled back, or rewritten together with its head.
+func (l *Log) VerifyAgainst(published Head) (VerifyReport, error) {
+	report, err := l.Verify()
+	if err != nil || published.Sequence == 0 {
+		return report, err
+	}
+
+	if published.Sequence > report.Head.Sequence {
+		report.Problems = append(report.Problems, Problem{
+			Sequence: published.Sequence,
+			Kind:     ProblemTruncated,
+			Message:  fmt.Sprintf("head is at sequence %d, before the published head at %d", report.Head.Sequence, published.Sequence),
+		})
+		return report, nil
+	}
+
+	var entry Entry
+	result := l.db.Where("sequence = ?", published.Sequence).Limit(1).Find(&entry)
+	if result.Error != nil {
+		return VerifyReport{}, result.Error
+	}
+	if result.RowsAffected == 0 || entry.Hash != published.Hash {
+		report.Problems = append(report.Problems, Problem{
+			Sequence: published.Sequence,
+			Kind:     ProblemTampered,
+			Message:  "entry does not match the published head",
+		})
+	}
+	return report, nil
+}
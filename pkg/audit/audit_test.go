@@ -0,0 +1,314 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"gorm.io/gorm"
+)
+
+// newTestLog returns a log on a new in-memory SQLite database with the audit migrations applied
+func newTestLog(t *testing.T) (*Log, *gorm.DB) {
+	t.Helper()
+
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+
+	migrations, err := Migrations(db.Dialect(database))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := migrate.New(database, MigrationsName, migrations).Up(); err != nil {
+		t.Fatal(err)
+	}
+
+	return NewLog(database), database
+}
+
+// appendEntries appends count entries, each in a transaction of its own
+func appendEntries(t *testing.T, log *Log, database *gorm.DB, count int) {
+	t.Helper()
+	for i := 0; i < count; i++ {
+		err := database.Transaction(func(tx *gorm.DB) error {
+			_, err := log.Append(tx, "moderator", ActionSummaryModerated, TargetSummary, fmt.Sprintf("summary-%d", i), map[string]string{"notes": "checked | twice"})
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func verify(t *testing.T, log *Log) VerifyReport {
+	t.Helper()
+	report, err := log.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func hasProblem(report VerifyReport, sequence uint64, kind string) bool {
+	for _, problem := range report.Problems {
+		if problem.Sequence == sequence && problem.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAppend(t *testing.T) {
+	log, database := newTestLog(t)
+	appendEntries(t, log, database, 3)
+
+	var entries []Entry
+	if err := database.Order("sequence asc").Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("%d entries, want 3", len(entries))
+	}
+	previousHash := genesisHash
+	for i, entry := range entries {
+		if entry.Sequence != uint64(i+1) || entry.PrevHash != previousHash {
+			t.Errorf("entry %d = %+v, want sequence %d after %s", i, entry, i+1, previousHash)
+		}
+		previousHash = entry.Hash
+	}
+
+	report := verify(t, log)
+	if !report.OK() || report.Entries != 3 {
+		t.Errorf("report = %+v, want 3 intact entries", report)
+	}
+	if report.Head.Sequence != 3 || report.Head.Hash != entries[2].Hash {
+		t.Errorf("head = %+v, want the third entry", report.Head)
+	}
+}
+
+func TestQuery(t *testing.T) {
+	log, database := newTestLog(t)
+	appendEntries(t, log, database, 5)
+
+	// Entries appended within a second share a creation time, the sequence alone orders them
+	var sequences []uint64
+	params := pagination.Params{Limit: 2, WithTotal: true}
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatalf("no last page after %v", sequences)
+		}
+		page, err := log.Query(Filter{ActorID: "moderator"}, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total == nil || *page.Total != 5 {
+			t.Errorf("total = %v, want 5", page.Total)
+		}
+		for _, entry := range page.Items {
+			sequences = append(sequences, entry.Sequence)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		cursor, err := pagination.DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params.After = &cursor
+	}
+	if fmt.Sprint(sequences) != "[5 4 3 2 1]" {
+		t.Errorf("sequences = %v, want newest first", sequences)
+	}
+
+	page, err := log.Query(Filter{TargetID: "summary-3"}, pagination.Params{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 1 || page.Items[0].Sequence != 4 {
+		t.Errorf("entries of summary-3 = %+v, want the fourth entry", page.Items)
+	}
+}
+
+func TestAppendRollsBackWithTransaction(t *testing.T) {
+	log, database := newTestLog(t)
+	appendEntries(t, log, database, 1)
+
+	failed := errors.New("change failed")
+	err := database.Transaction(func(tx *gorm.DB) error {
+		if _, err := log.Append(tx, "moderator", ActionSummaryModerated, TargetSummary, "summary", nil); err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("transaction returned %v", err)
+	}
+
+	report := verify(t, log)
+	if !report.OK() || report.Entries != 1 || report.Head.Sequence != 1 {
+		t.Errorf("report = %+v, want the single committed entry", report)
+	}
+}
+
+func TestAppendConcurrently(t *testing.T) {
+	log, database := newTestLog(t)
+
+	const appends = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, appends)
+	for i := 0; i < appends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- database.Transaction(func(tx *gorm.DB) error {
+				_, err := log.Append(tx, fmt.Sprintf("moderator-%d", i), ActionSummaryVoted, TargetSummary, "summary", nil)
+				return err
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	report := verify(t, log)
+	if !report.OK() || report.Entries != appends || report.Head.Sequence != appends {
+		t.Errorf("report = %+v, want %d intact entries", report, appends)
+	}
+}
+
+func TestVerifyGap(t *testing.T) {
+	log, database := newTestLog(t)
+	appendEntries(t, log, database, 3)
+
+	if err := database.Delete(&Entry{}, "sequence = ?", 2).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report := verify(t, log)
+	if !hasProblem(report, 3, ProblemGap) || !hasProblem(report, 3, ProblemBrokenLink) {
+		t.Errorf("problems = %+v, want a gap and a broken link at 3", report.Problems)
+	}
+}
+
+func TestVerifyEditedEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates map[string]interface{}
+	}{
+		{"details", map[string]interface{}{"details": `{"notes":"approved"}`}},
+		// Joined with "|", these fields hashed the same as the original ones
+		{"text moved between fields", map[string]interface{}{"target_id": `summary-1|{"notes":"checked `, "details": ` twice"}`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			log, database := newTestLog(t)
+			appendEntries(t, log, database, 3)
+
+			if err := database.Model(&Entry{}).Where("sequence = ?", 2).Updates(test.updates).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			report := verify(t, log)
+			if len(report.Problems) != 1 || !hasProblem(report, 2, ProblemTampered) {
+				t.Errorf("problems = %+v, want entry 2 tampered", report.Problems)
+			}
+		})
+	}
+}
+
+func TestVerifyBrokenLink(t *testing.T) {
+	log, database := newTestLog(t)
+	appendEntries(t, log, database, 3)
+
+	// Entry 3 is rehashed after its link is changed, so only the link is wrong
+	var entry Entry
+	if err := database.First(&entry, "sequence = ?", 3).Error; err != nil {
+		t.Fatal(err)
+	}
+	entry.PrevHash = genesisHash
+	entry.Hash = entry.computeHash()
+	if err := database.Save(&entry).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Model(&Head{}).Where("id = ?", headID).Update("hash", entry.Hash).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report := verify(t, log)
+	if len(report.Problems) != 1 || !hasProblem(report, 3, ProblemBrokenLink) {
+		t.Errorf("problems = %+v, want a broken link at 3", report.Problems)
+	}
+}
+
+func TestVerifyTruncated(t *testing.T) {
+	log, database := newTestLog(t)
+	appendEntries(t, log, database, 3)
+
+	if err := database.Delete(&Entry{}, "sequence = ?", 3).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	report := verify(t, log)
+	if len(report.Problems) != 1 || !hasProblem(report, 3, ProblemTruncated) {
+		t.Errorf("problems = %+v, want the chain truncated before 3", report.Problems)
+	}
+
+	if err := database.Delete(&Head{}, "id = ?", headID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if report := verify(t, log); report.OK() {
+		t.Error("a chain without its head verified")
+	}
+}
+
+func TestVerifyAgainstPublishedHead(t *testing.T) {
+	log, database := newTestLog(t)
+	appendEntries(t, log, database, 3)
+	published := verify(t, log).Head
+
+	report, err := log.VerifyAgainst(published)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !report.OK() {
+		t.Errorf("problems = %+v, want none", report.Problems)
+	}
+
+	// Removing the newest entry together with the head leaves a chain that is consistent on its own
+	var second Entry
+	if err := database.First(&second, "sequence = ?", 2).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Delete(&Entry{}, "sequence = ?", 3).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Save(&Head{ID: headID, Sequence: 2, Hash: second.Hash}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if report := verify(t, log); !report.OK() {
+		t.Fatalf("problems = %+v, want none without the published head", report.Problems)
+	}
+
+	report, err = log.VerifyAgainst(published)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasProblem(report, 3, ProblemTruncated) {
+		t.Errorf("problems = %+v, want the chain truncated before the published head", report.Problems)
+	}
+}
@@ -0,0 +1,50 @@
+package audit
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+type Handler struct {
+	log *Log
+}
+
+func NewHandler(log *Log) *Handler {
+	return &Handler{log: log}
+}
+
+// QueryHandler lists audit entries, newest first, filtered by the actor, action, target_type and target
+// query params. Entries are paged with the limit, cursor and total query params.
+func (h *Handler) QueryHandler(c echo.Context) error {
+	params, err := pagination.FromQuery(c)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, utils.NewErrorResponse(err.Error()))
+	}
+
+	filter := Filter{
+		ActorID:    c.QueryParam("actor"),
+		Action:     c.QueryParam("action"),
+		TargetType: c.QueryParam("target_type"),
+		TargetID:   c.QueryParam("target"),
+	}
+
+	page, err := h.log.Query(filter, params)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, page)
+}
+
+// VerifyHandler walks the chain and reports any gaps or tampered entries
+func (h *Handler) VerifyHandler(c echo.Context) error {
+	report, err := h.log.Verify()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse(err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, report)
+}
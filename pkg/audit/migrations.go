@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"embed"
+	"path"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/migrate"
+)
+
+// MigrationsName is the name the audit log's migrations are recorded under, next to those of the service
+const MigrationsName = "audit"
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migrations returns the schema migrations of the audit_entries table for the given db dialect. Every
+// service database that holds an audit log applies them, once, whichever services share it.
+func Migrations(dialect string) ([]migrate.Migration, error) {
+	return migrate.Load(migrationFiles, path.Join("migrations", dialect))
+}
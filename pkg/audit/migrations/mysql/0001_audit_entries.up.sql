@@ -0,0 +1,23 @@
+-- The hash-chained audit log, shared by the services that write to the database
+CREATE TABLE audit_entries (
+  sequence BIGINT UNSIGNED NOT NULL,
+  actor_id VARCHAR(191) NOT NULL,
+  action VARCHAR(191) NOT NULL,
+  target_type VARCHAR(191) NOT NULL,
+  target_id VARCHAR(191) NOT NULL,
+  details LONGTEXT,
+  created_at DATETIME(3) NULL,
+  prev_hash CHAR(64) NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (sequence),
+  KEY idx_audit_entries_actor_id (actor_id),
+  KEY idx_audit_entries_action (action),
+  KEY idx_audit_entries_target_id (target_id)
+);
+-- The newest entry of the chain, so deleting entries from its end is noticed
+CREATE TABLE audit_head (
+  id INT NOT NULL,
+  sequence BIGINT UNSIGNED NOT NULL,
+  hash CHAR(64) NOT NULL,
+  PRIMARY KEY (id)
+);
@@ -0,0 +1,23 @@
+-- The hash-chained audit log, shared by the services that write to the database
+CREATE TABLE audit_entries (
+  sequence INTEGER NOT NULL,
+  actor_id TEXT NOT NULL,
+  action TEXT NOT NULL,
+  target_type TEXT NOT NULL,
+  target_id TEXT NOT NULL,
+  details TEXT,
+  created_at DATETIME NULL,
+  prev_hash TEXT NOT NULL,
+  hash TEXT NOT NULL,
+  PRIMARY KEY (sequence)
+);
+CREATE INDEX idx_audit_entries_actor_id ON audit_entries (actor_id);
+CREATE INDEX idx_audit_entries_action ON audit_entries (action);
+CREATE INDEX idx_audit_entries_target_id ON audit_entries (target_id);
+-- The newest entry of the chain, so deleting entries from its end is noticed
+CREATE TABLE audit_head (
+  id INTEGER NOT NULL,
+  sequence INTEGER NOT NULL,
+  hash TEXT NOT NULL,
+  PRIMARY KEY (id)
+);
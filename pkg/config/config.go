@@ -2,6 +2,7 @@ package config
 
 import (
 	"os"
+	"time"
 
 	_ "github.com/joho/godotenv/autoload"
 	yaml "gopkg.in/yaml.v3"
@@ -12,21 +13,66 @@ const (
 	FbAuthURL   = "https://www.facebook.com/v16.0/dialog/oauth"
 	FbTokenURL  = "https://graph.facebook.com/v16.0/oauth/access_token"
 	FbGraphAPI  = "https://graph.facebook.com/me"
+	FbGraphURL  = "https://graph.facebook.com/v16.0"
+
+	OpenAIBaseURL = "https://api.openai.com/v1"
+	OpenAIModel   = "gpt-4o-mini"
 )
 
 type Config struct {
-	Port                  string `yaml:"port"`
-	OpenGraphClientSecret string `yaml:"open_graph_client_secret"`
-	OpenGraphClientID     string `yaml:"open_graph_client_id"`
-	Database              string `yaml:"database"`
-	RedisToken            string `yaml:"redis_token"`
-	RedisUrl              string `yaml:"redis_url"`
-	JwtSecret             string `yaml:"jwt_secret"`
-	Redis                 struct {
+	Port                   string              `yaml:"port"`
+	OpenGraphClientSecret  string              `yaml:"open_graph_client_secret"`
+	OpenGraphClientID      string              `yaml:"open_graph_client_id"`
+	Database               string              `yaml:"database"`
+	DatabaseDriver         string              `yaml:"database_driver"`
+	RedisToken             string              `yaml:"redis_token"`
+	RedisUrl               string              `yaml:"redis_url"`
+	JwtKeys                []JwtKey            `yaml:"jwt_keys"`
+	JwtKeyOverlap          time.Duration       `yaml:"jwt_key_overlap"`
+	JwksURL                string              `yaml:"jwks_url"`
+	JwksRefreshInterval    time.Duration       `yaml:"jwks_refresh_interval"`
+	TokenVaultKey          string              `yaml:"token_vault_key"`
+	Roles                  map[string][]string `yaml:"roles"`
+	RedirectURI            string              `yaml:"redirect_uri"`
+	FacebookGraphURL       string              `yaml:"facebook_graph_url"`
+	FacebookTokenURL       string              `yaml:"facebook_token_url"`
+	FacebookAccessToken    string              `yaml:"facebook_access_token"`
+	FacebookWebhookToken   string              `yaml:"facebook_webhook_verify_token"`
+	FacebookMonitoredPages []string            `yaml:"facebook_monitored_pages"`
+	Summarizer             string              `yaml:"summarizer"`
+	OpenAIKey              string              `yaml:"open_ai_key"`
+	OpenAIBaseURL          string              `yaml:"open_ai_base_url"`
+	OpenAIModel            string              `yaml:"open_ai_model"`
+	Redis                  struct {
 		Addr     string `yaml:"addr"`
 		Password string `yaml:"password"`
 		DB       int    `yaml:"db"`
 	} `yaml:"redis"`
+	Queue struct {
+		Name              string        `yaml:"name"`
+		Workers           int           `yaml:"workers"`
+		VisibilityTimeout time.Duration `yaml:"visibility_timeout"`
+		MaxAttempts       int           `yaml:"max_attempts"`
+		BaseBackoff       time.Duration `yaml:"base_backoff"`
+		MaxBackoff        time.Duration `yaml:"max_backoff"`
+	} `yaml:"queue"`
+	Moderation struct {
+		ClaimLease time.Duration `yaml:"claim_lease"`
+		Quorum     int           `yaml:"quorum"`
+		PanelSize  int           `yaml:"panel_size"`
+	} `yaml:"moderation"`
+	Search struct {
+		Index           string        `yaml:"index"`
+		RefreshInterval time.Duration `yaml:"refresh_interval"`
+	} `yaml:"search"`
+}
+
+// JwtKey is an Ed25519 signing key of the auth service. The key without a retired_at signs new tokens;
+// retired keys only verify tokens that were signed before the rotation, for jwt_key_overlap after retired_at.
+type JwtKey struct {
+	Kid            string    `yaml:"kid"`
+	PrivateKeyFile string    `yaml:"private_key_file"`
+	RetiredAt      time.Time `yaml:"retired_at"`
 }
 
 // LoadConfig loads the configuration from a file or environment variables if the file is not found
@@ -54,14 +100,50 @@ func LoadConfig(path string) (*Config, error) {
 	if database := os.Getenv("database"); database != "" {
 		cfg.Database = database
 	}
+	if databaseDriver := os.Getenv("database_driver"); databaseDriver != "" {
+		cfg.DatabaseDriver = databaseDriver
+	}
 	if redisToken := os.Getenv("redis_token"); redisToken != "" {
 		cfg.RedisToken = redisToken
 	}
 	if redisUrl := os.Getenv("redis_url"); redisUrl != "" {
 		cfg.RedisUrl = redisUrl
 	}
-	if jwtSecret := os.Getenv("jwt_secret"); jwtSecret != "" {
-		cfg.JwtSecret = jwtSecret
+	if jwksURL := os.Getenv("jwks_url"); jwksURL != "" {
+		cfg.JwksURL = jwksURL
+	}
+	if tokenVaultKey := os.Getenv("token_vault_key"); tokenVaultKey != "" {
+		cfg.TokenVaultKey = tokenVaultKey
+	}
+	if redirectURI := os.Getenv("redirect_uri"); redirectURI != "" {
+		cfg.RedirectURI = redirectURI
+	}
+	if cfg.RedirectURI == "" {
+		cfg.RedirectURI = RedirectURI
+	}
+	if facebookGraphURL := os.Getenv("facebook_graph_url"); facebookGraphURL != "" {
+		cfg.FacebookGraphURL = facebookGraphURL
+	}
+	if facebookTokenURL := os.Getenv("facebook_token_url"); facebookTokenURL != "" {
+		cfg.FacebookTokenURL = facebookTokenURL
+	}
+	if facebookAccessToken := os.Getenv("facebook_access_token"); facebookAccessToken != "" {
+		cfg.FacebookAccessToken = facebookAccessToken
+	}
+	if facebookWebhookToken := os.Getenv("facebook_webhook_verify_token"); facebookWebhookToken != "" {
+		cfg.FacebookWebhookToken = facebookWebhookToken
+	}
+	if summarizer := os.Getenv("summarizer"); summarizer != "" {
+		cfg.Summarizer = summarizer
+	}
+	if openAIKey := os.Getenv("open_ai_key"); openAIKey != "" {
+		cfg.OpenAIKey = openAIKey
+	}
+	if openAIBaseURL := os.Getenv("open_ai_base_url"); openAIBaseURL != "" {
+		cfg.OpenAIBaseURL = openAIBaseURL
+	}
+	if openAIModel := os.Getenv("open_ai_model"); openAIModel != "" {
+		cfg.OpenAIModel = openAIModel
 	}
 
 	return &cfg, nil
@@ -2,16 +2,90 @@ package db
 
 import (
 	"fmt"
+	"log"
+	"strings"
 
+	"github.com/glebarez/sqlite"
 	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func InitializeDatabase(dsn string) *gorm.DB {
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+// Dialects are the names of the supported databases, as returned by Dialect
+const (
+	DialectMySQL    = "mysql"
+	DialectPostgres = "postgres"
+	DialectSQLite   = "sqlite"
+)
+
+// MemoryDSN opens a private in-memory SQLite database, for tests and local experiments
+const MemoryDSN = "sqlite://:memory:"
+
+// InitializeDatabase opens the database or panics. driver is one of the dialects; when it is empty the
+// driver is taken from the DSN scheme, see Open.
+func InitializeDatabase(driver, dsn string) *gorm.DB {
+	db, err := Open(driver, dsn)
 	if err != nil {
-		fmt.Errorf(err.Error())
+		log.Println(err)
 		panic("There was a database issue db.go")
 	}
 	return db
 }
+
+// Open opens the database the DSN points to. Without a driver the DSN scheme selects it: postgres:// or
+// postgresql:// for PostgreSQL, sqlite://<path> for SQLite and mysql:// or no scheme for MySQL, so plain
+// go-sql-driver DSNs such as user:pass@tcp(host:3306)/notes keep working.
+func Open(driver, dsn string) (*gorm.DB, error) {
+	if driver == "" {
+		driver = driverOf(dsn)
+	}
+
+	var dialector gorm.Dialector
+	switch driver {
+	case DialectMySQL:
+		dialector = mysql.Open(strings.TrimPrefix(dsn, "mysql://"))
+	case DialectPostgres:
+		dialector = postgres.Open(dsn)
+	case DialectSQLite:
+		dialector = sqlite.Open(strings.TrimPrefix(dsn, "sqlite://"))
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+
+	if driver == DialectSQLite {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		// Every connection to :memory: gets its own empty database, and SQLite allows a single writer anyway
+		sqlDB.SetMaxOpenConns(1)
+	}
+
+	return db, nil
+}
+
+// OpenMemory opens a new, empty in-memory SQLite database
+func OpenMemory() (*gorm.DB, error) {
+	return Open(DialectSQLite, MemoryDSN)
+}
+
+// Dialect returns the dialect of an open database
+func Dialect(db *gorm.DB) string {
+	return db.Dialector.Name()
+}
+
+func driverOf(dsn string) string {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://"), strings.HasPrefix(dsn, "postgresql://"):
+		return DialectPostgres
+	case strings.HasPrefix(dsn, "sqlite://"):
+		return DialectSQLite
+	default:
+		return DialectMySQL
+	}
+}
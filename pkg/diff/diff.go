@@ -0,0 +1,178 @@
+package diff
+
+import (
+	"strings"
+	"unicode"
+)
+
+type Op string
+
+const (
+	Equal  Op = "equal"
+	Insert Op = "insert"
+	Delete Op = "delete"
+)
+
+// Chunk is a run of text that is in both texts, only in the new one or only in the old one. Joining the
+// equal and delete chunks gives the old text, joining the equal and insert chunks the new one.
+type Chunk struct {
+	Op   Op     `json:"op"`
+	Text string `json:"text"`
+}
+
+// Lines diffs two texts line by line. Lines keep their line break.
+func Lines(a, b string) []Chunk {
+	return Tokens(splitLines(a), splitLines(b))
+}
+
+// Words diffs two texts word by word. Runs of whitespace are tokens of their own, so changes in spacing
+// show up as well.
+func Words(a, b string) []Chunk {
+	return Tokens(splitWords(a), splitWords(b))
+}
+
+// Tokens diffs two token sequences with Myers' algorithm, which finds a shortest edit script. It uses the
+// linear space variant: rather than keeping every step of the search, it finds the middle snake of the edit
+// script and recurses on the parts before and after it. Adjacent tokens with the same op are joined into one
+// chunk.
+func Tokens(a, b []string) []Chunk {
+	size := 2*((len(a)+len(b)+1)/2) + 3
+	d := differ{a: a, b: b, forward: make([]int, size), backward: make([]int, size), chunks: []Chunk{}}
+	d.compare(0, len(a), 0, len(b))
+	return d.chunks
+}
+
+type differ struct {
+	a, b []string
+	// forward[k+offset] is the furthest x reached on diagonal k from the start of a part, backward the same
+	// from its end. They are shared by every part, as a part is done with them before recursing.
+	forward, backward []int
+	chunks            []Chunk
+}
+
+// compare diffs a[aLo:aHi] against b[bLo:bHi].
+func (d *differ) compare(aLo, aHi, bLo, bHi int) {
+	prefix := aLo
+	for aLo < aHi && bLo < bHi && d.a[aLo] == d.b[bLo] {
+		aLo++
+		bLo++
+	}
+	d.emitRange(Equal, d.a, prefix, aLo)
+
+	suffix := aHi
+	for aLo < aHi && bLo < bHi && d.a[aHi-1] == d.b[bHi-1] {
+		aHi--
+		bHi--
+	}
+
+	switch {
+	case aLo == aHi:
+		d.emitRange(Insert, d.b, bLo, bHi)
+	case bLo == bHi:
+		d.emitRange(Delete, d.a, aLo, aHi)
+	default:
+		// Both parts are non-empty and differ at both ends, so the script has at least two edits and the
+		// parts either side of the middle snake are strictly smaller.
+		x, y, u, v := d.middleSnake(aLo, aHi, bLo, bHi)
+		d.compare(aLo, x, bLo, y)
+		d.emitRange(Equal, d.a, x, u)
+		d.compare(u, aHi, v, bHi)
+	}
+	d.emitRange(Equal, d.a, aHi, suffix)
+}
+
+// middleSnake searches from both ends of a[aLo:aHi] and b[bLo:bHi] at once until the paths overlap, and
+// returns the start (x, y) and end (u, v) of the snake where they meet. The snake lies on a shortest edit
+// script.
+func (d *differ) middleSnake(aLo, aHi, bLo, bHi int) (x, y, u, v int) {
+	n, m := aHi-aLo, bHi-bLo
+	delta := n - m
+	odd := delta%2 != 0
+	offset := (n+m+1)/2 + 1
+	forward, backward := d.forward, d.backward
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	for steps := 0; steps <= (n+m+1)/2; steps++ {
+		for k := -steps; k <= steps; k += 2 {
+			var x int
+			if k == -steps || (k != steps && forward[k-1+offset] < forward[k+1+offset]) {
+				x = forward[k+1+offset]
+			} else {
+				x = forward[k-1+offset] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aLo+x] == d.b[bLo+y] {
+				x++
+				y++
+			}
+			forward[k+offset] = x
+			if back := delta - k; odd && back >= -(steps-1) && back <= steps-1 && x+backward[back+offset] >= n {
+				return aLo + startX, bLo + startY, aLo + x, bLo + y
+			}
+		}
+
+		for k := -steps; k <= steps; k += 2 {
+			var x int
+			if k == -steps || (k != steps && backward[k-1+offset] < backward[k+1+offset]) {
+				x = backward[k+1+offset]
+			} else {
+				x = backward[k-1+offset] + 1
+			}
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && d.a[aHi-x-1] == d.b[bHi-y-1] {
+				x++
+				y++
+			}
+			backward[k+offset] = x
+			if ahead := delta - k; !odd && ahead >= -steps && ahead <= steps && x+forward[ahead+offset] >= n {
+				return aHi - x, bHi - y, aHi - startX, bHi - startY
+			}
+		}
+	}
+	panic("diff: no middle snake")
+}
+
+func (d *differ) emitRange(op Op, tokens []string, from, to int) {
+	if from == to {
+		return
+	}
+	text := strings.Join(tokens[from:to], "")
+	if last := len(d.chunks) - 1; last >= 0 && d.chunks[last].Op == op {
+		d.chunks[last].Text += text
+		return
+	}
+	d.chunks = append(d.chunks, Chunk{Op: op, Text: text})
+}
+
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func splitWords(text string) []string {
+	var tokens []string
+	start := 0
+	for i, r := range text {
+		if i > start && unicode.IsSpace(r) != isSpaceAt(text, start) {
+			tokens = append(tokens, text[start:i])
+			start = i
+		}
+	}
+	if start < len(text) {
+		tokens = append(tokens, text[start:])
+	}
+	return tokens
+}
+
+func isSpaceAt(text string, i int) bool {
+	for _, r := range text[i:] {
+		return unicode.IsSpace(r)
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package diff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+// texts joins the chunks back into the old and the new text.
+func texts(chunks []Chunk) (string, string) {
+	var a, b strings.Builder
+	for _, chunk := range chunks {
+		if chunk.Op != Insert {
+			a.WriteString(chunk.Text)
+		}
+		if chunk.Op != Delete {
+			b.WriteString(chunk.Text)
+		}
+	}
+	return a.String(), b.String()
+}
+
+// edits counts the tokens inserted and deleted by a diff of single character tokens.
+func edits(chunks []Chunk) int {
+	count := 0
+	for _, chunk := range chunks {
+		if chunk.Op != Equal {
+			count += len(chunk.Text)
+		}
+	}
+	return count
+}
+
+// shortestEdits is the length of a shortest edit script from a to b, found with the textbook longest
+// common subsequence table.
+func shortestEdits(a, b []string) int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	return len(a) + len(b) - 2*lcs[0][0]
+}
+
+func TestTokens(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []Chunk
+	}{
+		{"both empty", "", "", []Chunk{}},
+		{"equal", "abc", "abc", []Chunk{{Equal, "abc"}}},
+		{"all inserted", "", "abc", []Chunk{{Insert, "abc"}}},
+		{"all deleted", "abc", "", []Chunk{{Delete, "abc"}}},
+		{"insert in the middle", "ac", "abc", []Chunk{{Equal, "a"}, {Insert, "b"}, {Equal, "c"}}},
+		{"delete at the end", "abc", "ab", []Chunk{{Equal, "ab"}, {Delete, "c"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := Tokens(strings.Split(test.a, ""), strings.Split(test.b, ""))
+			if len(got) != len(test.want) {
+				t.Fatalf("Tokens(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("Tokens(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestTokensShortest(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		text := make([]byte, random.Intn(30))
+		for i := range text {
+			text[i] = "abcd"[random.Intn(4)]
+		}
+		return string(text)
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomText(), randomText()
+		aTokens, bTokens := strings.Split(a, ""), strings.Split(b, "")
+		chunks := Tokens(aTokens, bTokens)
+
+		if gotA, gotB := texts(chunks); gotA != a || gotB != b {
+			t.Fatalf("Tokens(%q, %q) rebuilds %q and %q", a, b, gotA, gotB)
+		}
+		if got, want := edits(chunks), shortestEdits(aTokens, bTokens); got != want {
+			t.Fatalf("Tokens(%q, %q) takes %d edits, want %d", a, b, got, want)
+		}
+		for j := 1; j < len(chunks); j++ {
+			if chunks[j].Op == chunks[j-1].Op {
+				t.Fatalf("Tokens(%q, %q) has adjacent %s chunks", a, b, chunks[j].Op)
+			}
+		}
+	}
+}
+
+func TestTokensLarge(t *testing.T) {
+	// Enough tokens that keeping a copy of the search state per edit, as the quadratic space variant
+	// does, would take gigabytes
+	a := make([]string, 50000)
+	b := make([]string, 0, len(a))
+	for i := range a {
+		a[i] = string(rune('a' + i%26))
+		if i%100 != 0 {
+			b = append(b, a[i])
+		}
+		if i%250 == 0 {
+			b = append(b, "!")
+		}
+	}
+
+	chunks := Tokens(a, b)
+	gotA, gotB := texts(chunks)
+	if gotA != strings.Join(a, "") || gotB != strings.Join(b, "") {
+		t.Fatal("Tokens does not rebuild the texts")
+	}
+	if got := edits(chunks); got > 700 {
+		t.Errorf("Tokens takes %d edits, want at most 700", got)
+	}
+}
+
+func TestLines(t *testing.T) {
+	chunks := Lines("one\ntwo\nthree\n", "one\n2\nthree")
+	want := []Chunk{{Equal, "one\n"}, {Delete, "two\nthree\n"}, {Insert, "2\nthree"}}
+	if len(chunks) != len(want) {
+		t.Fatalf("Lines = %v, want %v", chunks, want)
+	}
+	for i := range chunks {
+		if chunks[i] != want[i] {
+			t.Errorf("Lines = %v, want %v", chunks, want)
+			break
+		}
+	}
+}
+
+func TestWords(t *testing.T) {
+	chunks := Words("the quick  fox", "the slow fox")
+	a, b := texts(chunks)
+	if a != "the quick  fox" || b != "the slow fox" {
+		t.Errorf("Words rebuilds %q and %q", a, b)
+	}
+	for _, chunk := range chunks {
+		if chunk.Op != Equal && strings.Contains(chunk.Text, "the") {
+			t.Errorf("Words changes the unchanged word: %v", chunks)
+		}
+	}
+}
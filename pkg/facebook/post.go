@@ -0,0 +1,240 @@
+package facebook
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	KindPost      = "post"
+	KindGroupPost = "group_post"
+	KindPhoto     = "photo"
+	KindVideo     = "video"
+
+	baseURL = "https://www.facebook.com"
+)
+
+var (
+	ErrNotFacebookURL     = errors.New("not a facebook url")
+	ErrUnsupportedPostURL = errors.New("facebook url does not point to a post")
+)
+
+// PostRef identifies a Facebook post independently of the URL it was shared with
+type PostRef struct {
+	// ID is the canonical ID "{kind}:{post}". It is the same for every URL that carries the same post ID,
+	// whether the owner in the URL is a username or a numeric ID. A post shared both with its pfbid and
+	// with its numeric story_fbid gets two IDs, as a pfbid can't be mapped to the numeric ID offline.
+	ID           string `json:"id"`
+	OwnerID      string `json:"owner_id,omitempty"`
+	PostID       string `json:"post_id"`
+	Kind         string `json:"kind"`
+	CanonicalURL string `json:"canonical_url"`
+}
+
+var (
+	numericID = regexp.MustCompile(`^[0-9]+$`)
+	// pfbid IDs are the obfuscated post IDs Facebook has used in URLs since 2022
+	postID = regexp.MustCompile(`^([0-9]+|pfbid[0-9A-Za-z]+)$`)
+	// owner is a numeric profile/page ID or a username
+	ownerID = regexp.MustCompile(`^[0-9A-Za-z.\-]+$`)
+)
+
+var facebookHosts = map[string]bool{
+	"facebook.com":          true,
+	"www.facebook.com":      true,
+	"m.facebook.com":        true,
+	"mbasic.facebook.com":   true,
+	"web.facebook.com":      true,
+	"touch.facebook.com":    true,
+	"business.facebook.com": true,
+	"fb.com":                true,
+	"www.fb.com":            true,
+}
+
+// ParsePostURL extracts a PostRef from any of the URL shapes Facebook uses for posts, photos and videos:
+//
+//	/{owner}/posts/{id}
+//	/permalink.php?story_fbid={id}&id={owner} and /story.php?story_fbid={id}&id={owner}
+//	/groups/{group}/posts/{id} and /groups/{group}/permalink/{id}
+//	/photo.php?fbid={id}, /photo/?fbid={id} and /{owner}/photos/{album}/{id}
+//	/watch/?v={id}, /video.php?v={id}, /reel/{id} and /{owner}/videos/[{slug}/]{id}
+//
+// on www, m, mbasic, web and the other Facebook hosts, including links wrapped by l.facebook.com.
+func ParsePostURL(raw string) (PostRef, error) {
+	raw = strings.TrimSpace(raw)
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return PostRef{}, ErrNotFacebookURL
+	}
+
+	host := strings.ToLower(u.Hostname())
+
+	// Outbound link wrappers carry the real URL in the u parameter
+	if host == "l.facebook.com" || host == "lm.facebook.com" {
+		if target := u.Query().Get("u"); target != "" {
+			return ParsePostURL(target)
+		}
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+
+	// Short links only resolve through a redirect, so the post they point to isn't known offline
+	if host == "fb.watch" || host == "fb.me" {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+
+	if !facebookHosts[host] {
+		return PostRef{}, ErrNotFacebookURL
+	}
+
+	query := u.Query()
+	segments := strings.FieldsFunc(u.Path, func(r rune) bool { return r == '/' })
+	if len(segments) == 0 {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+
+	switch strings.ToLower(segments[0]) {
+	case "permalink.php", "story.php":
+		return newPost(query.Get("id"), query.Get("story_fbid"))
+	case "photo.php", "photo":
+		return newPhoto(query.Get("fbid"))
+	case "watch", "video.php":
+		return newVideo(query.Get("v"))
+	case "reel":
+		if len(segments) >= 2 {
+			return newVideo(segments[1])
+		}
+	case "groups":
+		if len(segments) >= 4 && (segments[2] == "posts" || segments[2] == "permalink") {
+			return newGroupPost(segments[1], segments[3])
+		}
+	default:
+		if len(segments) >= 3 {
+			switch segments[1] {
+			case "posts":
+				return newPost(segments[0], segments[2])
+			case "videos":
+				return newVideo(segments[len(segments)-1])
+			case "photos":
+				return newPhoto(segments[len(segments)-1])
+			}
+		}
+	}
+
+	return PostRef{}, ErrUnsupportedPostURL
+}
+
+// GraphID returns the ID the Graph API knows the post by, or "" when the URL didn't carry one. Photos
+// and videos are looked up by their object ID, posts by "{owner}_{post}" with a numeric owner; see
+// NeedsOwnerID for posts shared with the owner's username.
+func (p PostRef) GraphID() string {
+	switch {
+	case p.Kind == KindPhoto || p.Kind == KindVideo:
+		return p.PostID
+	case numericID.MatchString(p.OwnerID):
+		return p.OwnerID + "_" + p.PostID
+	default:
+		return ""
+	}
+}
+
+// NeedsOwnerID reports whether the post's owner is a username, which has to be resolved to the owner's
+// numeric ID before the post can be fetched from the Graph API
+func (p PostRef) NeedsOwnerID() bool {
+	return p.OwnerID != "" && !numericID.MatchString(p.OwnerID)
+}
+
+// WithOwnerID returns the post with the owner's username replaced by its numeric ID. The canonical ID
+// doesn't change.
+func (p PostRef) WithOwnerID(ownerID string) (PostRef, error) {
+	if !numericID.MatchString(ownerID) {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+	if p.Kind == KindGroupPost {
+		return newGroupPost(ownerID, p.PostID)
+	}
+	return newPost(ownerID, p.PostID)
+}
+
+// PostRefFromGraphID builds a PostRef from a Graph API post ID of the form "{owner}_{post}"
+func PostRefFromGraphID(id string) (PostRef, error) {
+	owner, post, found := strings.Cut(id, "_")
+	if !found {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+	return newPost(owner, post)
+}
+
+func newPost(owner string, post string) (PostRef, error) {
+	if !postID.MatchString(post) || !ownerID.MatchString(owner) {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+
+	owner = normalizeOwner(owner)
+	return PostRef{
+		ID:           canonicalID(KindPost, post),
+		OwnerID:      owner,
+		PostID:       post,
+		Kind:         KindPost,
+		CanonicalURL: baseURL + "/" + owner + "/posts/" + post,
+	}, nil
+}
+
+func newGroupPost(group string, post string) (PostRef, error) {
+	if !postID.MatchString(post) || !ownerID.MatchString(group) {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+
+	group = normalizeOwner(group)
+	return PostRef{
+		ID:           canonicalID(KindGroupPost, post),
+		OwnerID:      group,
+		PostID:       post,
+		Kind:         KindGroupPost,
+		CanonicalURL: baseURL + "/groups/" + group + "/posts/" + post,
+	}, nil
+}
+
+func newPhoto(id string) (PostRef, error) {
+	if !numericID.MatchString(id) {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+
+	return PostRef{
+		ID:           canonicalID(KindPhoto, id),
+		PostID:       id,
+		Kind:         KindPhoto,
+		CanonicalURL: baseURL + "/photo/?fbid=" + id,
+	}, nil
+}
+
+func newVideo(id string) (PostRef, error) {
+	if !numericID.MatchString(id) {
+		return PostRef{}, ErrUnsupportedPostURL
+	}
+
+	return PostRef{
+		ID:           canonicalID(KindVideo, id),
+		PostID:       id,
+		Kind:         KindVideo,
+		CanonicalURL: baseURL + "/watch/?v=" + id,
+	}, nil
+}
+
+// canonicalID prefixes the object ID with its kind, so a photo and a video can never share an ID
+func canonicalID(kind string, id string) string {
+	return kind + ":" + id
+}
+
+// normalizeOwner lower cases usernames, which Facebook treats case insensitively
+func normalizeOwner(owner string) string {
+	if numericID.MatchString(owner) {
+		return owner
+	}
+	return strings.ToLower(owner)
+}
@@ -0,0 +1,241 @@
+package facebook
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePostURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		url       string
+		id        string
+		kind      string
+		canonical string
+		graphID   string
+	}{
+		{
+			name:      "owner posts",
+			url:       "https://www.facebook.com/4/posts/123",
+			id:        "post:123",
+			kind:      KindPost,
+			canonical: "https://www.facebook.com/4/posts/123",
+			graphID:   "4_123",
+		},
+		{
+			name:      "vanity owner posts",
+			url:       "https://www.facebook.com/Zuck/posts/123",
+			id:        "post:123",
+			kind:      KindPost,
+			canonical: "https://www.facebook.com/zuck/posts/123",
+		},
+		{
+			name:      "pfbid posts",
+			url:       "https://www.facebook.com/zuck/posts/pfbid02abcDEF",
+			id:        "post:pfbid02abcDEF",
+			kind:      KindPost,
+			canonical: "https://www.facebook.com/zuck/posts/pfbid02abcDEF",
+		},
+		{
+			name:      "pfbid posts of a numeric owner",
+			url:       "https://www.facebook.com/4/posts/pfbid02abcDEF",
+			id:        "post:pfbid02abcDEF",
+			kind:      KindPost,
+			canonical: "https://www.facebook.com/4/posts/pfbid02abcDEF",
+			graphID:   "4_pfbid02abcDEF",
+		},
+		{
+			name:      "permalink.php",
+			url:       "https://m.facebook.com/permalink.php?story_fbid=123&id=4",
+			id:        "post:123",
+			kind:      KindPost,
+			canonical: "https://www.facebook.com/4/posts/123",
+			graphID:   "4_123",
+		},
+		{
+			name:      "story.php",
+			url:       "https://mbasic.facebook.com/story.php?story_fbid=123&id=4",
+			id:        "post:123",
+			kind:      KindPost,
+			canonical: "https://www.facebook.com/4/posts/123",
+			graphID:   "4_123",
+		},
+		{
+			name:      "group posts",
+			url:       "https://www.facebook.com/groups/my.group/posts/456",
+			id:        "group_post:456",
+			kind:      KindGroupPost,
+			canonical: "https://www.facebook.com/groups/my.group/posts/456",
+		},
+		{
+			name:      "group permalink",
+			url:       "https://www.facebook.com/groups/77/permalink/456/",
+			id:        "group_post:456",
+			kind:      KindGroupPost,
+			canonical: "https://www.facebook.com/groups/77/posts/456",
+			graphID:   "77_456",
+		},
+		{
+			name:      "photo.php",
+			url:       "https://www.facebook.com/photo.php?fbid=789&set=a.1",
+			id:        "photo:789",
+			kind:      KindPhoto,
+			canonical: "https://www.facebook.com/photo/?fbid=789",
+			graphID:   "789",
+		},
+		{
+			name:      "photo",
+			url:       "https://www.facebook.com/photo/?fbid=789",
+			id:        "photo:789",
+			kind:      KindPhoto,
+			canonical: "https://www.facebook.com/photo/?fbid=789",
+			graphID:   "789",
+		},
+		{
+			name:      "owner photos",
+			url:       "https://www.facebook.com/zuck/photos/a.1/789",
+			id:        "photo:789",
+			kind:      KindPhoto,
+			canonical: "https://www.facebook.com/photo/?fbid=789",
+			graphID:   "789",
+		},
+		{
+			name:      "watch",
+			url:       "https://www.facebook.com/watch/?v=789",
+			id:        "video:789",
+			kind:      KindVideo,
+			canonical: "https://www.facebook.com/watch/?v=789",
+			graphID:   "789",
+		},
+		{
+			name:      "video.php",
+			url:       "https://www.facebook.com/video.php?v=789",
+			id:        "video:789",
+			kind:      KindVideo,
+			canonical: "https://www.facebook.com/watch/?v=789",
+			graphID:   "789",
+		},
+		{
+			name:      "reel",
+			url:       "https://www.facebook.com/reel/789",
+			id:        "video:789",
+			kind:      KindVideo,
+			canonical: "https://www.facebook.com/watch/?v=789",
+			graphID:   "789",
+		},
+		{
+			name:      "owner videos with slug",
+			url:       "https://web.facebook.com/zuck/videos/some-title/789/",
+			id:        "video:789",
+			kind:      KindVideo,
+			canonical: "https://www.facebook.com/watch/?v=789",
+			graphID:   "789",
+		},
+		{
+			name:      "without scheme",
+			url:       "facebook.com/4/posts/123",
+			id:        "post:123",
+			kind:      KindPost,
+			canonical: "https://www.facebook.com/4/posts/123",
+			graphID:   "4_123",
+		},
+		{
+			name:      "link wrapper",
+			url:       "https://l.facebook.com/l.php?u=https%3A%2F%2Fwww.facebook.com%2Fwatch%2F%3Fv%3D789",
+			id:        "video:789",
+			kind:      KindVideo,
+			canonical: "https://www.facebook.com/watch/?v=789",
+			graphID:   "789",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			post, err := ParsePostURL(tt.url)
+			if err != nil {
+				t.Fatalf("ParsePostURL(%q) returned %v", tt.url, err)
+			}
+			if post.ID != tt.id {
+				t.Errorf("ID = %q, want %q", post.ID, tt.id)
+			}
+			if post.Kind != tt.kind {
+				t.Errorf("Kind = %q, want %q", post.Kind, tt.kind)
+			}
+			if post.CanonicalURL != tt.canonical {
+				t.Errorf("CanonicalURL = %q, want %q", post.CanonicalURL, tt.canonical)
+			}
+			if got := post.GraphID(); got != tt.graphID {
+				t.Errorf("GraphID() = %q, want %q", got, tt.graphID)
+			}
+		})
+	}
+}
+
+func TestParsePostURLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		url  string
+		err  error
+	}{
+		{"other host", "https://example.com/4/posts/123", ErrNotFacebookURL},
+		{"profile", "https://www.facebook.com/zuck", ErrUnsupportedPostURL},
+		{"short link", "https://fb.watch/abc", ErrUnsupportedPostURL},
+		{"non numeric photo", "https://www.facebook.com/photo/?fbid=abc", ErrUnsupportedPostURL},
+		{"missing story id", "https://www.facebook.com/permalink.php?id=4", ErrUnsupportedPostURL},
+		{"wrapper without target", "https://l.facebook.com/l.php", ErrUnsupportedPostURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParsePostURL(tt.url); !errors.Is(err, tt.err) {
+				t.Errorf("ParsePostURL(%q) returned %v, want %v", tt.url, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSamePostFromEveryURLShape(t *testing.T) {
+	// Every shape carrying the numeric post ID; the pfbid of the same post would give another ID
+	urls := []string{
+		"https://www.facebook.com/4/posts/123",
+		"https://www.facebook.com/zuck/posts/123",
+		"https://www.facebook.com/permalink.php?story_fbid=123&id=4",
+		"https://m.facebook.com/story.php?story_fbid=123&id=zuck",
+	}
+
+	fromGraph, err := PostRefFromGraphID("4_123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, url := range urls {
+		post, err := ParsePostURL(url)
+		if err != nil {
+			t.Fatalf("ParsePostURL(%q) returned %v", url, err)
+		}
+		if post.ID != fromGraph.ID {
+			t.Errorf("%s has ID %q, the graph ID gives %q", url, post.ID, fromGraph.ID)
+		}
+	}
+}
+
+func TestWithOwnerID(t *testing.T) {
+	post, err := ParsePostURL("https://www.facebook.com/zuck/posts/123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !post.NeedsOwnerID() {
+		t.Fatal("a post shared with the owner's username needs the owner's ID")
+	}
+
+	resolved, err := post.WithOwnerID("4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resolved.NeedsOwnerID() || resolved.GraphID() != "4_123" || resolved.ID != post.ID {
+		t.Errorf("resolved post = %+v", resolved)
+	}
+
+	if _, err := post.WithOwnerID("zuck"); !errors.Is(err, ErrUnsupportedPostURL) {
+		t.Errorf("WithOwnerID with a username returned %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package facebook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	SignatureHeader = "X-Hub-Signature-256"
+
+	FieldFeed = "feed"
+	VerbAdd   = "add"
+)
+
+// WebhookEvent is the body Facebook posts to a webhook subscription
+type WebhookEvent struct {
+	Object string         `json:"object"`
+	Entry  []WebhookEntry `json:"entry"`
+}
+
+// WebhookEntry holds the changes to a single page
+type WebhookEntry struct {
+	ID      string          `json:"id"`
+	Time    int64           `json:"time"`
+	Changes []WebhookChange `json:"changes"`
+}
+
+// WebhookChange is a single change to a subscribed field
+type WebhookChange struct {
+	Field string     `json:"field"`
+	Value FeedChange `json:"value"`
+}
+
+// FeedChange is the value of a change to a page's feed
+type FeedChange struct {
+	Item        string `json:"item"`
+	Verb        string `json:"verb"`
+	PostID      string `json:"post_id"`
+	Message     string `json:"message"`
+	Link        string `json:"link"`
+	CreatedTime int64  `json:"created_time"`
+	From        struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"from"`
+}
+
+// postItems are the feed items that create a new post, as opposed to comments, reactions and likes
+var postItems = map[string]bool{
+	"status": true,
+	"post":   true,
+	"photo":  true,
+	"video":  true,
+	"share":  true,
+}
+
+// IsNewPost reports whether the change is a post being added to the feed
+func (c FeedChange) IsNewPost() bool {
+	return c.Verb == VerbAdd && postItems[c.Item] && c.PostID != ""
+}
+
+// VerifySignature checks the X-Hub-Signature-256 header, an HMAC-SHA256 of the raw body keyed with the app secret
+func VerifySignature(body []byte, header string, appSecret string) bool {
+	signature, found := strings.CutPrefix(header, "sha256=")
+	if !found || appSecret == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,21 @@
+package jwks
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type Handler struct {
+	keys *KeySet
+}
+
+func NewHandler(keys *KeySet) *Handler {
+	return &Handler{keys: keys}
+}
+
+// JWKSHandler publishes the public keys at /.well-known/jwks.json
+func (h *Handler) JWKSHandler(c echo.Context) error {
+	c.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return c.JSON(http.StatusOK, h.keys.JWKS())
+}
@@ -0,0 +1,184 @@
+package jwks
+
+import (
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/mwelwankuta/facebook-notes/pkg/config"
+)
+
+// DefaultOverlap is how long a retired key keeps verifying tokens when jwt_key_overlap is not set.
+// It has to be longer than the lifetime of an access token.
+const DefaultOverlap = time.Hour
+
+var (
+	ErrNoActiveKey  = errors.New("exactly one jwt key must have no retired_at")
+	ErrUnknownKey   = errors.New("token was signed with an unknown key")
+	ErrMissingKeyID = errors.New("token has no kid header")
+)
+
+// SigningKey is an Ed25519 key of the keyset identified by its kid
+type SigningKey struct {
+	Kid        string
+	PrivateKey ed25519.PrivateKey
+	// RetiredAt is zero for the active key
+	RetiredAt time.Time
+}
+
+// KeySet signs access tokens with its active key and publishes the public half of every key that
+// can still verify a token. Rotating adds a new active key and retires the old one; the old key stays
+// published for the overlap window so tokens it signed remain valid until they expire.
+type KeySet struct {
+	active  SigningKey
+	keys    []SigningKey
+	overlap time.Duration
+}
+
+func NewKeySet(keys []SigningKey, overlap time.Duration) (*KeySet, error) {
+	if overlap <= 0 {
+		overlap = DefaultOverlap
+	}
+
+	set := &KeySet{keys: keys, overlap: overlap}
+	activeKeys := 0
+	for _, key := range keys {
+		if key.RetiredAt.IsZero() {
+			set.active = key
+			activeKeys++
+		}
+	}
+	if activeKeys != 1 {
+		return nil, ErrNoActiveKey
+	}
+
+	return set, nil
+}
+
+// LoadKeySet reads the keys listed under jwt_keys in the config
+func LoadKeySet(cfg config.Config) (*KeySet, error) {
+	keys := make([]SigningKey, 0, len(cfg.JwtKeys))
+	for _, key := range cfg.JwtKeys {
+		privateKey, err := LoadPrivateKey(key.PrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %s: %w", key.Kid, err)
+		}
+		keys = append(keys, SigningKey{Kid: key.Kid, PrivateKey: privateKey, RetiredAt: key.RetiredAt})
+	}
+
+	return NewKeySet(keys, cfg.JwtKeyOverlap)
+}
+
+// LoadPrivateKey reads a PKCS #8 PEM encoded Ed25519 private key
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("key is not an Ed25519 private key")
+	}
+
+	return privateKey, nil
+}
+
+// Sign signs the claims with the active key and sets the kid header
+func (k *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = k.active.Kid
+	return token.SignedString(k.active.PrivateKey)
+}
+
+// published returns the keys that still verify tokens
+func (k *KeySet) published(now time.Time) []SigningKey {
+	var keys []SigningKey
+	for _, key := range k.keys {
+		if key.RetiredAt.IsZero() || now.Before(key.RetiredAt.Add(k.overlap)) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// JWKS returns the public keys as a JSON Web Key Set
+func (k *KeySet) JWKS() Document {
+	document := Document{Keys: []JSONWebKey{}}
+	for _, key := range k.published(time.Now()) {
+		document.Keys = append(document.Keys, newJSONWebKey(key.Kid, key.PrivateKey.Public().(ed25519.PublicKey)))
+	}
+	return document
+}
+
+// Keyfunc looks up the public key of a token by its kid, for services that verify with the keyset itself
+func (k *KeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, ErrMissingKeyID
+	}
+
+	for _, key := range k.published(time.Now()) {
+		if key.Kid == kid {
+			return key.PrivateKey.Public(), nil
+		}
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// Document is a JSON Web Key Set as served from /.well-known/jwks.json
+type Document struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// JSONWebKey is an Ed25519 public key in the OKP form of RFC 8037
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+}
+
+func newJSONWebKey(kid string, publicKey ed25519.PublicKey) JSONWebKey {
+	return JSONWebKey{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   base64.RawURLEncoding.EncodeToString(publicKey),
+		Kid: kid,
+		Use: "sig",
+		Alg: jwt.SigningMethodEdDSA.Alg(),
+	}
+}
+
+// publicKey decodes the key, returning false for keys that are not Ed25519 signing keys
+func (j JSONWebKey) publicKey() (ed25519.PublicKey, bool) {
+	if j.Kty != "OKP" || j.Crv != "Ed25519" || (j.Use != "" && j.Use != "sig") {
+		return nil, false
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(j.X)
+	if err != nil || len(x) != ed25519.PublicKeySize {
+		return nil, false
+	}
+
+	return ed25519.PublicKey(x), true
+}
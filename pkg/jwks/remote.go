@@ -0,0 +1,141 @@
+package jwks
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// DefaultRefreshInterval is how long a fetched key set is used before it is fetched again
+	DefaultRefreshInterval = 10 * time.Minute
+	// minRefetchInterval limits how often an unknown kid triggers a fetch, so forged kids can't flood the auth service
+	minRefetchInterval = 30 * time.Second
+)
+
+// RemoteKeySet verifies tokens with the keys published by the auth service. Keys are cached and
+// fetched again after the refresh interval, or early when a token names a kid that isn't cached yet,
+// which is how a rotated key is picked up.
+type RemoteKeySet struct {
+	url             string
+	client          *http.Client
+	refreshInterval time.Duration
+	minRefetch      time.Duration
+	// fetches collapses concurrent fetches into one, which every caller waits for
+	fetches singleflight.Group
+
+	mu          sync.RWMutex
+	keys        map[string]ed25519.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+func NewRemoteKeySet(url string, refreshInterval time.Duration) *RemoteKeySet {
+	if refreshInterval <= 0 {
+		refreshInterval = DefaultRefreshInterval
+	}
+
+	return &RemoteKeySet{
+		url:             url,
+		client:          &http.Client{Timeout: 10 * time.Second},
+		refreshInterval: refreshInterval,
+		minRefetch:      minRefetchInterval,
+		keys:            map[string]ed25519.PublicKey{},
+	}
+}
+
+// Keyfunc looks up the public key of a token by its kid, fetching the key set when needed
+func (r *RemoteKeySet) Keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, ErrMissingKeyID
+	}
+
+	r.mu.RLock()
+	key, found := r.keys[kid]
+	stale := time.Since(r.fetchedAt) > r.refreshInterval
+	r.mu.RUnlock()
+
+	if found && !stale {
+		return key, nil
+	}
+
+	if err := r.refresh(context.Background()); err != nil {
+		// A stale key is still better than failing every request while the auth service is unreachable
+		log.Printf("could not fetch jwks from %s: %v", r.url, err)
+	}
+
+	r.mu.RLock()
+	key, found = r.keys[kid]
+	r.mu.RUnlock()
+
+	if !found {
+		return nil, ErrUnknownKey
+	}
+	return key, nil
+}
+
+// refresh fetches the key set unless another request just did. The lock is only held to read and swap
+// the keys, so requests with a cached key aren't held up by a slow auth service.
+func (r *RemoteKeySet) refresh(ctx context.Context) error {
+	_, err, _ := r.fetches.Do(r.url, func() (interface{}, error) {
+		r.mu.Lock()
+		if time.Since(r.lastAttempt) < r.minRefetch {
+			r.mu.Unlock()
+			return nil, nil
+		}
+		r.lastAttempt = time.Now()
+		r.mu.Unlock()
+
+		keys, err := r.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		r.keys = keys
+		r.fetchedAt = time.Now()
+		r.mu.Unlock()
+		return nil, nil
+	})
+	return err
+}
+
+// fetch downloads the key set and decodes its Ed25519 keys
+func (r *RemoteKeySet) fetch(ctx context.Context) (map[string]ed25519.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var document Document
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]ed25519.PublicKey{}
+	for _, jwk := range document.Keys {
+		if publicKey, ok := jwk.publicKey(); ok && jwk.Kid != "" {
+			keys[jwk.Kid] = publicKey
+		}
+	}
+	return keys, nil
+}
@@ -0,0 +1,198 @@
+package jwks
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newSigningKey(t *testing.T, kid string, retiredAt time.Time) SigningKey {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SigningKey{Kid: kid, PrivateKey: privateKey, RetiredAt: retiredAt}
+}
+
+func newKeySet(t *testing.T, overlap time.Duration, keys ...SigningKey) *KeySet {
+	t.Helper()
+	set, err := NewKeySet(keys, overlap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+// jwksServer serves the JWKS of whichever keyset was set last and counts the fetches
+type jwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    *KeySet
+	fetches atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, keys *KeySet) *jwksServer {
+	t.Helper()
+	server := &jwksServer{keys: keys}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.fetches.Add(1)
+		server.mu.Lock()
+		document := server.keys.JWKS()
+		server.mu.Unlock()
+		json.NewEncoder(w).Encode(document)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func (s *jwksServer) setKeys(keys *KeySet) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func sign(t *testing.T, keys *KeySet) string {
+	t.Helper()
+	token, err := keys.Sign(jwt.RegisteredClaims{Subject: "user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func verify(remote *RemoteKeySet, token string) error {
+	_, err := jwt.Parse(token, remote.Keyfunc, jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}))
+	return err
+}
+
+func TestRemoteKeySetRefetchesUnknownKid(t *testing.T) {
+	old := newSigningKey(t, "2024-01", time.Time{})
+	server := newJWKSServer(t, newKeySet(t, 0, old))
+	remote := NewRemoteKeySet(server.URL, time.Hour)
+	remote.minRefetch = 0
+
+	if err := verify(remote, sign(t, newKeySet(t, 0, old))); err != nil {
+		t.Fatal(err)
+	}
+
+	// The auth service rotates; the first token signed with the new key makes the set fetch again
+	old.RetiredAt = time.Now()
+	rotated := newSigningKey(t, "2024-06", time.Time{})
+	server.setKeys(newKeySet(t, 0, old, rotated))
+
+	if err := verify(remote, sign(t, newKeySet(t, 0, rotated))); err != nil {
+		t.Fatalf("a token signed with the rotated key returned %v", err)
+	}
+	if got := server.fetches.Load(); got != 2 {
+		t.Errorf("%d fetches, want 2", got)
+	}
+	if err := verify(remote, sign(t, newKeySet(t, 0, rotated))); err != nil || server.fetches.Load() != 2 {
+		t.Errorf("a cached key returned %v after %d fetches", err, server.fetches.Load())
+	}
+}
+
+func TestRemoteKeySetThrottlesRefetches(t *testing.T) {
+	active := newSigningKey(t, "2024-06", time.Time{})
+	server := newJWKSServer(t, newKeySet(t, 0, active))
+	remote := NewRemoteKeySet(server.URL, time.Hour)
+
+	if err := verify(remote, sign(t, newKeySet(t, 0, active))); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := sign(t, newKeySet(t, 0, newSigningKey(t, "forged", time.Time{})))
+	for i := 0; i < 5; i++ {
+		if err := verify(remote, forged); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("a forged kid returned %v, want ErrUnknownKey", err)
+		}
+	}
+	if got := server.fetches.Load(); got != 1 {
+		t.Errorf("%d fetches, want forged kids throttled to the first fetch", got)
+	}
+}
+
+func TestRemoteKeySetSharesFetches(t *testing.T) {
+	active := newSigningKey(t, "2024-06", time.Time{})
+	document := newKeySet(t, 0, active).JWKS()
+	release := make(chan struct{})
+	requested := make(chan struct{}, 10)
+	var fetches atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		requested <- struct{}{}
+		<-release
+		json.NewEncoder(w).Encode(document)
+	}))
+	defer server.Close()
+	remote := NewRemoteKeySet(server.URL, time.Hour)
+	remote.minRefetch = 0
+
+	// A key cached before the fetch started
+	cached := newSigningKey(t, "2024-01", time.Time{})
+	remote.keys[cached.Kid] = cached.PrivateKey.Public().(ed25519.PublicKey)
+	remote.fetchedAt = time.Now()
+
+	token := sign(t, newKeySet(t, 0, active))
+	cachedToken := sign(t, newKeySet(t, 0, cached))
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func() { errs <- verify(remote, token) }()
+	}
+	<-requested
+
+	// The fetch in flight doesn't hold up tokens whose key is cached
+	verified := make(chan error, 1)
+	go func() { verified <- verify(remote, cachedToken) }()
+	select {
+	case err := <-verified:
+		if err != nil {
+			t.Errorf("a cached key returned %v during a fetch", err)
+		}
+	case <-time.After(time.Second):
+		t.Error("a cached key waited for the fetch")
+	}
+
+	close(release)
+	for i := 0; i < 10; i++ {
+		if err := <-errs; err != nil {
+			t.Errorf("a request waiting for the fetch returned %v", err)
+		}
+	}
+	if got := fetches.Load(); got != 1 {
+		t.Errorf("%d fetches, want concurrent requests to share one", got)
+	}
+}
+
+func TestRemoteKeySetRetiredKey(t *testing.T) {
+	const overlap = 200 * time.Millisecond
+	retired := newSigningKey(t, "2024-01", time.Now())
+	active := newSigningKey(t, "2024-06", time.Time{})
+	server := newJWKSServer(t, newKeySet(t, overlap, retired, active))
+	remote := NewRemoteKeySet(server.URL, 50*time.Millisecond)
+	remote.minRefetch = 0
+
+	retired.RetiredAt = time.Time{}
+	token := sign(t, newKeySet(t, 0, retired))
+	if err := verify(remote, token); err != nil {
+		t.Fatalf("a key retired within the overlap window returned %v", err)
+	}
+
+	// Once the overlap window passed, the next fetch no longer has the key
+	time.Sleep(overlap + 50*time.Millisecond)
+	if err := verify(remote, token); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("a key retired past the overlap window returned %v, want ErrUnknownKey", err)
+	}
+	if err := verify(remote, sign(t, newKeySet(t, 0, active))); err != nil {
+		t.Errorf("the active key returned %v", err)
+	}
+}
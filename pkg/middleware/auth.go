@@ -1,25 +1,100 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/permissions"
+	"github.com/mwelwankuta/facebook-notes/pkg/tokens"
 	"github.com/mwelwankuta/facebook-notes/pkg/utils"
 )
 
-func RequireRole(roles ...string) echo.MiddlewareFunc {
+// RevocationList reports whether an access token was revoked, by its jti or by a newer token generation
+// of its user. It is implemented by tokens.Store.
+type RevocationList interface {
+	IsRevoked(ctx context.Context, userID string, jti string, generation int64) (bool, error)
+}
+
+var _ RevocationList = (*tokens.Store)(nil)
+
+// AuthConfig configures how Authenticate verifies access tokens
+type AuthConfig struct {
+	// Keys returns the public key a token was signed with, by its kid header
+	Keys jwt.Keyfunc
+	// Tokens is checked for revoked tokens. Revocation is not checked when it is nil.
+	Tokens RevocationList
+}
+
+// Authenticate verifies the bearer token of the request, rejects revoked tokens and stores the
+// caller as a utils.Principal in the context. Failures are answered with a 401 JSON error.
+func Authenticate(cfg AuthConfig) echo.MiddlewareFunc {
+	parser := jwt.NewParser(jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}))
+
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
 		return func(c echo.Context) error {
-			user, err := utils.GetUserFromContext(c)
-			if err != nil {
-				return echo.NewHTTPError(401, "unauthorized")
+			raw := bearerToken(c.Request())
+			if raw == "" {
+				return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("missing bearer token"))
 			}
 
-			for _, role := range roles {
-				if user.Role == role {
-					return next(c)
+			claims := new(utils.JwtCustomClaims)
+			if _, err := parser.ParseWithClaims(raw, claims, cfg.Keys); err != nil {
+				if errors.Is(err, jwt.ErrTokenExpired) {
+					return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("token has expired"))
+				}
+				return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("invalid token"))
+			}
+			// claims.ID is the user ID, the jti is the ID of the embedded registered claims
+			if claims.ID == "" || claims.RegisteredClaims.ID == "" || claims.ExpiresAt == nil {
+				return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("invalid token"))
+			}
+
+			principal := utils.NewPrincipal(claims)
+
+			if cfg.Tokens != nil {
+				revoked, err := cfg.Tokens.IsRevoked(c.Request().Context(), principal.UserID, principal.TokenID, principal.Generation)
+				if err != nil {
+					return c.JSON(http.StatusInternalServerError, utils.NewErrorResponse("could not check token revocation"))
+				}
+				if revoked {
+					return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("token has been revoked"))
 				}
 			}
 
-			return echo.NewHTTPError(403, "forbidden")
+			utils.SetPrincipal(c, principal)
+			return next(c)
+		}
+	}
+}
+
+// bearerToken reads the token from the Authorization header. The "Bearer" scheme is optional
+// because clients of the summaries service have been sending the bare token.
+func bearerToken(r *http.Request) string {
+	header := strings.TrimSpace(r.Header.Get(echo.HeaderAuthorization))
+	if scheme, token, found := strings.Cut(header, " "); found && strings.EqualFold(scheme, "bearer") {
+		return strings.TrimSpace(token)
+	}
+	return header
+}
+
+// RequirePermission rejects callers whose role is not granted the permission. It must run after Authenticate.
+func RequirePermission(policy *permissions.Policy, permission permissions.Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, err := utils.GetPrincipal(c)
+			if err != nil {
+				return c.JSON(http.StatusUnauthorized, utils.NewErrorResponse("unauthorized"))
+			}
+
+			if err := policy.Authorize(principal, permission, permissions.Resource{}); err != nil {
+				return c.JSON(http.StatusForbidden, utils.NewErrorResponse("forbidden"))
+			}
+
+			return next(c)
 		}
 	}
 }
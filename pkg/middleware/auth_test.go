@@ -0,0 +1,213 @@
+package middleware
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+	"github.com/mwelwankuta/facebook-notes/pkg/jwks"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+// revocations is an in-memory RevocationList with the same rules as tokens.Store
+type revocations struct {
+	revoked     map[string]bool
+	generations map[string]int64
+}
+
+func (r revocations) IsRevoked(_ context.Context, userID string, jti string, generation int64) (bool, error) {
+	return r.revoked[jti] || generation < r.generations[userID], nil
+}
+
+func newKey(t *testing.T, kid string, retiredAt time.Time) jwks.SigningKey {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return jwks.SigningKey{Kid: kid, PrivateKey: privateKey, RetiredAt: retiredAt}
+}
+
+func newKeySet(t *testing.T, keys ...jwks.SigningKey) *jwks.KeySet {
+	t.Helper()
+	keySet, err := jwks.NewKeySet(keys, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keySet
+}
+
+func claims(jti string, generation int64, expiresAt time.Time) *utils.JwtCustomClaims {
+	return &utils.JwtCustomClaims{
+		ID:         "user-1",
+		Role:       "user",
+		Generation: generation,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(time.Now().Add(-time.Minute)),
+		},
+	}
+}
+
+func sign(t *testing.T, keySet *jwks.KeySet, claims jwt.Claims) string {
+	t.Helper()
+	token, err := keySet.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// signWith signs the claims with the key, whether or not it is retired
+func signWith(t *testing.T, key jwks.SigningKey, claims jwt.Claims) string {
+	t.Helper()
+	key.RetiredAt = time.Time{}
+	return sign(t, newKeySet(t, key), claims)
+}
+
+func TestAuthenticate(t *testing.T) {
+	active := newKey(t, "2024-06", time.Time{})
+	// Retired within the overlap window, so its tokens still verify
+	previous := newKey(t, "2024-05", time.Now().Add(-10*time.Minute))
+	// Retired longer ago than the overlap window
+	expired := newKey(t, "2024-04", time.Now().Add(-2*time.Hour))
+	keySet := newKeySet(t, active, previous, expired)
+
+	list := revocations{
+		revoked:     map[string]bool{"revoked-jti": true},
+		generations: map[string]int64{"user-1": 2},
+	}
+	valid := time.Now().Add(15 * time.Minute)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+		error  string
+	}{
+		{
+			name:   "valid",
+			header: "Bearer " + sign(t, keySet, claims("jti", 2, valid)),
+			status: http.StatusOK,
+		},
+		{
+			name:   "bare token",
+			header: sign(t, keySet, claims("jti", 2, valid)),
+			status: http.StatusOK,
+		},
+		{
+			name:   "signed with a key in its overlap window",
+			header: "Bearer " + signWith(t, previous, claims("jti", 2, valid)),
+			status: http.StatusOK,
+		},
+		{
+			name:   "missing token",
+			status: http.StatusUnauthorized,
+			error:  "missing bearer token",
+		},
+		{
+			name:   "expired",
+			header: "Bearer " + sign(t, keySet, claims("jti", 2, time.Now().Add(-time.Minute))),
+			status: http.StatusUnauthorized,
+			error:  "token has expired",
+		},
+		{
+			name:   "revoked",
+			header: "Bearer " + sign(t, keySet, claims("revoked-jti", 2, valid)),
+			status: http.StatusUnauthorized,
+			error:  "token has been revoked",
+		},
+		{
+			name:   "wrong generation",
+			header: "Bearer " + sign(t, keySet, claims("jti", 1, valid)),
+			status: http.StatusUnauthorized,
+			error:  "token has been revoked",
+		},
+		{
+			name:   "retired key",
+			header: "Bearer " + signWith(t, expired, claims("jti", 2, valid)),
+			status: http.StatusUnauthorized,
+			error:  "invalid token",
+		},
+		{
+			name:   "signature mismatch",
+			header: "Bearer " + signWith(t, newKey(t, "2024-06", time.Time{}), claims("jti", 2, valid)),
+			status: http.StatusUnauthorized,
+			error:  "invalid token",
+		},
+		{
+			name:   "unknown kid",
+			header: "Bearer " + signWith(t, newKey(t, "2023-01", time.Time{}), claims("jti", 2, valid)),
+			status: http.StatusUnauthorized,
+			error:  "invalid token",
+		},
+		{
+			name:   "no jti",
+			header: "Bearer " + sign(t, keySet, claims("", 2, valid)),
+			status: http.StatusUnauthorized,
+			error:  "invalid token",
+		},
+		{
+			name:   "malformed",
+			header: "Bearer not-a-token",
+			status: http.StatusUnauthorized,
+			error:  "invalid token",
+		},
+	}
+
+	handler := Authenticate(AuthConfig{Keys: keySet.Keyfunc, Tokens: list})(func(c echo.Context) error {
+		principal, err := utils.GetPrincipal(c)
+		if err != nil {
+			return err
+		}
+		return c.String(http.StatusOK, principal.UserID)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(echo.HeaderAuthorization, tt.header)
+			}
+			rec := httptest.NewRecorder()
+
+			if err := handler(echo.New().NewContext(req, rec)); err != nil {
+				t.Fatal(err)
+			}
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body)
+			}
+			if tt.status == http.StatusOK && rec.Body.String() != "user-1" {
+				t.Errorf("principal = %q, want user-1", rec.Body)
+			}
+			if tt.error != "" && !strings.Contains(rec.Body.String(), tt.error) {
+				t.Errorf("body = %s, want %q", rec.Body, tt.error)
+			}
+		})
+	}
+}
+
+func TestAuthenticateWithoutRevocationList(t *testing.T) {
+	keySet := newKeySet(t, newKey(t, "2024-06", time.Time{}))
+	handler := Authenticate(AuthConfig{Keys: keySet.Keyfunc})(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+sign(t, keySet, claims("revoked-jti", 0, time.Now().Add(time.Minute))))
+	rec := httptest.NewRecorder()
+	if err := handler(echo.New().NewContext(req, rec)); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want 200", rec.Code)
+	}
+}
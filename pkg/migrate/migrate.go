@@ -0,0 +1,393 @@
+package migrate
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// lockTimeout is how long a lock is honoured since it was last refreshed. A migration run that died
+	// without releasing its lock is taken over after this long.
+	lockTimeout = 10 * time.Minute
+	// lockRefreshInterval is how often a running migration refreshes its lock, well within lockTimeout
+	lockRefreshInterval = time.Minute
+)
+
+var (
+	ErrLocked          = errors.New("migrations are locked by another process")
+	ErrLockLost        = errors.New("the migrations lock was taken over by another process")
+	ErrUnknownVersion  = errors.New("unknown migration version")
+	ErrVersionMismatch = errors.New("database schema version does not match this build")
+)
+
+// fileName matches migration files such as 0001_initial_schema.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Migration is one versioned schema change with the SQL to apply and to revert it
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// AppliedMigration is a row of a migrations table
+type AppliedMigration struct {
+	Version   int       `gorm:"primarykey;autoIncrement:false"`
+	Name      string    `gorm:"size:255"`
+	AppliedAt time.Time `gorm:"not null"`
+}
+
+// migrationLock is the single row of a lock table that is held while migrations run
+type migrationLock struct {
+	ID       int    `gorm:"primarykey;autoIncrement:false"`
+	LockedBy string `gorm:"size:255"`
+	LockedAt time.Time
+}
+
+// Status is a migration and whether it has been applied
+type Status struct {
+	Migration
+	Applied   bool
+	AppliedAt *time.Time
+}
+
+// Load reads the migrations in dir of fsys. Every version needs an up and a down file, and versions must
+// count up from 1 without gaps.
+func Load(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		match := fileName.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration file %s is not named <version>_<name>.<up|down>.sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = migration
+		}
+		if migration.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has files with different names", version)
+		}
+		if match[3] == "up" {
+			migration.Up = string(content)
+		} else {
+			migration.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" || migration.Down == "" {
+			return nil, fmt.Errorf("migration %d needs both an up and a down file", migration.Version)
+		}
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			return nil, fmt.Errorf("migration versions must count up from 1, found %d after %d", migration.Version, i)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrator applies and reverts one set of migrations and records the applied ones in the set's own
+// schema_migrations_<name> table, so sets sharing a database keep their versions apart
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+	table      string
+	lockTable  string
+	owner      string
+	// lockTimeout and lockRefresh are lockTimeout and lockRefreshInterval, shortened in tests
+	lockTimeout time.Duration
+	lockRefresh time.Duration
+}
+
+// New creates a Migrator for the set of migrations called name, as returned by Load. The name must be a
+// lower case identifier such as the service the migrations belong to.
+func New(db *gorm.DB, name string, migrations []Migration) *Migrator {
+	hostname, _ := os.Hostname()
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+		table:      "schema_migrations_" + name,
+		lockTable:  "schema_migrations_" + name + "_lock",
+		owner:      fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+
+		lockTimeout: lockTimeout,
+		lockRefresh: lockRefreshInterval,
+	}
+}
+
+// applied returns a query on the migrations table
+func (m *Migrator) applied(db *gorm.DB) *gorm.DB {
+	return db.Table(m.table)
+}
+
+// locks returns a query on the lock table
+func (m *Migrator) locks() *gorm.DB {
+	return m.db.Table(m.lockTable)
+}
+
+// Latest is the version the database is at once every migration is applied
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version returns the newest applied version, 0 for a database that has never been migrated
+func (m *Migrator) Version() (int, error) {
+	if !m.db.Migrator().HasTable(m.table) {
+		return 0, nil
+	}
+
+	var version int
+	err := m.applied(m.db).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Check fails with ErrVersionMismatch unless every migration of this build, and no other, is applied.
+// Services call it on start so they never run against a schema they were not built for.
+func (m *Migrator) Check() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version != m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, expected %d", ErrVersionMismatch, version, m.Latest())
+	}
+	return nil
+}
+
+// Status lists every migration and when it was applied
+func (m *Migrator) Status() ([]Status, error) {
+	applied := map[int]AppliedMigration{}
+	if m.db.Migrator().HasTable(m.table) {
+		var rows []AppliedMigration
+		if err := m.applied(m.db).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			applied[row.Version] = row
+		}
+	}
+
+	statuses := make([]Status, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = Status{Migration: migration}
+		if row, ok := applied[migration.Version]; ok {
+			appliedAt := row.AppliedAt
+			statuses[i].Applied = true
+			statuses[i].AppliedAt = &appliedAt
+		}
+	}
+	return statuses, nil
+}
+
+// Up applies every pending migration
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down reverts the newest applied migration
+func (m *Migrator) Down() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+	return m.To(version - 1)
+}
+
+// To applies or reverts migrations until the database is at the given version. Each migration runs in
+// its own transaction, but databases that commit DDL implicitly, such as MySQL, cannot roll a failed
+// migration back: fix the schema by hand and run the command again.
+func (m *Migrator) To(target int) error {
+	if target < 0 || target > m.Latest() {
+		return fmt.Errorf("%w: %d, the latest is %d", ErrUnknownVersion, target, m.Latest())
+	}
+
+	if err := m.applied(m.db).AutoMigrate(&AppliedMigration{}); err != nil {
+		return err
+	}
+	if err := m.locks().AutoMigrate(&migrationLock{}); err != nil {
+		return err
+	}
+	if err := m.lock(); err != nil {
+		return err
+	}
+	defer m.unlock()
+	stop := m.keepLocked()
+	defer stop()
+
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+
+	for version < target {
+		migration := m.migrations[version]
+		if err := m.refreshLock(); err != nil {
+			return err
+		}
+		if err := m.apply(migration, migration.Up, true); err != nil {
+			return fmt.Errorf("migration %d_%s up: %w", migration.Version, migration.Name, err)
+		}
+		version++
+	}
+
+	for version > target {
+		migration := m.migrations[version-1]
+		if err := m.refreshLock(); err != nil {
+			return err
+		}
+		if err := m.apply(migration, migration.Down, false); err != nil {
+			return fmt.Errorf("migration %d_%s down: %w", migration.Version, migration.Name, err)
+		}
+		version--
+	}
+
+	return nil
+}
+
+// apply runs the SQL of a migration and records or removes it in the migrations table
+func (m *Migrator) apply(migration Migration, sql string, up bool) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(sql) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		if up {
+			return m.applied(tx).Create(&AppliedMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		}
+		return m.applied(tx).Delete(&AppliedMigration{}, "version = ?", migration.Version).Error
+	})
+}
+
+// lock takes the migrations lock, or a lock that was not refreshed for longer than the lock timeout
+func (m *Migrator) lock() error {
+	now := time.Now()
+	result := m.locks().Clauses(clause.OnConflict{DoNothing: true}).Create(&migrationLock{ID: 1, LockedBy: m.owner, LockedAt: now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil
+	}
+
+	result = m.locks().
+		Where("id = 1 AND locked_at < ?", now.Add(-m.lockTimeout)).
+		Updates(map[string]interface{}{"locked_by": m.owner, "locked_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		var held migrationLock
+		m.locks().First(&held, "id = 1")
+		return fmt.Errorf("%w: held by %s since %s", ErrLocked, held.LockedBy, held.LockedAt.Format(time.RFC3339))
+	}
+	return nil
+}
+
+// refreshLock moves the time of the held lock to now, or fails with ErrLockLost when another process took it over
+func (m *Migrator) refreshLock() error {
+	result := m.locks().Where("id = 1 AND locked_by = ?", m.owner).Update("locked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+// keepLocked refreshes the held lock until the returned function is called, so a migration that runs
+// for longer than the lock timeout is not taken over
+func (m *Migrator) keepLocked() (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(m.lockRefresh)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.refreshLock(); err != nil {
+					log.Printf("could not refresh the lock of %s: %v", m.table, err)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+func (m *Migrator) unlock() {
+	m.locks().Delete(&migrationLock{}, "id = 1 AND locked_by = ?", m.owner)
+}
+
+// splitStatements splits SQL into statements on semicolons that end a line. Lines starting with -- are comments.
+func splitStatements(sql string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(sql, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
@@ -0,0 +1,269 @@
+package migrate
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"gorm.io/gorm"
+)
+
+func file(content string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(content)}
+}
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_add_index.up.sql":   file("CREATE INDEX idx_notes_body ON notes (body);"),
+		"migrations/0002_add_index.down.sql": file("DROP INDEX idx_notes_body;"),
+		"migrations/0001_notes.up.sql":       file("CREATE TABLE notes (id INTEGER, body TEXT);"),
+		"migrations/0001_notes.down.sql":     file("DROP TABLE notes;"),
+		"migrations/nested/ignored.txt":      file("directories are skipped"),
+	}
+
+	migrations, err := Load(fsys, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Migration{
+		{Version: 1, Name: "notes", Up: "CREATE TABLE notes (id INTEGER, body TEXT);", Down: "DROP TABLE notes;"},
+		{Version: 2, Name: "add_index", Up: "CREATE INDEX idx_notes_body ON notes (body);", Down: "DROP INDEX idx_notes_body;"},
+	}
+	if !reflect.DeepEqual(migrations, want) {
+		t.Errorf("Load = %+v, want %+v", migrations, want)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		fsys  fstest.MapFS
+		error string
+	}{
+		{"bad file name", fstest.MapFS{
+			"migrations/0001_notes.sql": file("CREATE TABLE notes (id INTEGER);"),
+		}, "is not named"},
+		{"missing down", fstest.MapFS{
+			"migrations/0001_notes.up.sql": file("CREATE TABLE notes (id INTEGER);"),
+		}, "needs both an up and a down file"},
+		{"different names", fstest.MapFS{
+			"migrations/0001_notes.up.sql":   file("CREATE TABLE notes (id INTEGER);"),
+			"migrations/0001_other.down.sql": file("DROP TABLE notes;"),
+		}, "different names"},
+		{"gap", fstest.MapFS{
+			"migrations/0001_notes.up.sql":   file("CREATE TABLE notes (id INTEGER);"),
+			"migrations/0001_notes.down.sql": file("DROP TABLE notes;"),
+			"migrations/0003_later.up.sql":   file("SELECT 1;"),
+			"migrations/0003_later.down.sql": file("SELECT 1;"),
+		}, "count up from 1"},
+		{"missing directory", fstest.MapFS{}, "file does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(tt.fsys, "migrations")
+			if err == nil || !strings.Contains(err.Error(), tt.error) {
+				t.Errorf("Load returned %v, want an error containing %q", err, tt.error)
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		name string
+		sql  string
+		want []string
+	}{
+		{"empty", "", nil},
+		{"comments and blank lines only", "-- nothing to do\n\n  -- really\n", nil},
+		{"one statement per line", "CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);\n", []string{
+			"CREATE TABLE a (id INTEGER);",
+			"CREATE TABLE b (id INTEGER);",
+		}},
+		{"multi-line statement", "-- notes\nCREATE TABLE notes (\n  id INTEGER,\n  body TEXT\n);\n", []string{
+			"CREATE TABLE notes (\n  id INTEGER,\n  body TEXT\n);",
+		}},
+		{"semicolon inside a line", "INSERT INTO notes (body) VALUES ('a; b');\n", []string{
+			"INSERT INTO notes (body) VALUES ('a; b');",
+		}},
+		{"trailing statement without semicolon", "DROP TABLE a;\nDROP TABLE b", []string{
+			"DROP TABLE a;",
+			"DROP TABLE b",
+		}},
+		{"semicolon followed by spaces", "DROP TABLE a;   \nDROP TABLE b;", []string{
+			"DROP TABLE a;",
+			"DROP TABLE b;",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := splitStatements(tt.sql); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitStatements(%q) = %q, want %q", tt.sql, got, tt.want)
+			}
+		})
+	}
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	return database
+}
+
+func TestMigratorUpAndDown(t *testing.T) {
+	database := newTestDB(t)
+	migrations := []Migration{
+		{Version: 1, Name: "notes", Up: "CREATE TABLE notes (id INTEGER);", Down: "DROP TABLE notes;"},
+		{Version: 2, Name: "tags", Up: "CREATE TABLE tags (id INTEGER);", Down: "DROP TABLE tags;"},
+	}
+	migrator := New(database, "notes", migrations)
+
+	if err := migrator.Check(); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Check on a new database returned %v, want ErrVersionMismatch", err)
+	}
+
+	if err := migrator.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if err := migrator.Check(); err != nil {
+		t.Errorf("Check after Up returned %v", err)
+	}
+	if !database.Migrator().HasTable("tags") || !database.Migrator().HasTable("schema_migrations_notes") {
+		t.Error("Up did not create the tables")
+	}
+
+	if err := migrator.Down(); err != nil {
+		t.Fatal(err)
+	}
+	if version, err := migrator.Version(); err != nil || version != 1 {
+		t.Errorf("Version after Down = %d, %v, want 1", version, err)
+	}
+	if database.Migrator().HasTable("tags") {
+		t.Error("Down did not drop tags")
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !statuses[0].Applied || statuses[1].Applied {
+		t.Errorf("statuses = %+v, want only version 1 applied", statuses)
+	}
+
+	if err := migrator.To(3); !errors.Is(err, ErrUnknownVersion) {
+		t.Errorf("To(3) returned %v, want ErrUnknownVersion", err)
+	}
+}
+
+func TestMigratorsShareADatabase(t *testing.T) {
+	database := newTestDB(t)
+	first := New(database, "first", []Migration{
+		{Version: 1, Name: "a", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"},
+	})
+	second := New(database, "second", []Migration{
+		{Version: 1, Name: "b", Up: "CREATE TABLE b (id INTEGER);", Down: "DROP TABLE b;"},
+		{Version: 2, Name: "c", Up: "CREATE TABLE c (id INTEGER);", Down: "DROP TABLE c;"},
+	})
+
+	if err := first.Up(); err != nil {
+		t.Fatal(err)
+	}
+	// The second set starts at version 0 although the first set's version 1 is applied
+	if err := second.Up(); err != nil {
+		t.Fatal(err)
+	}
+	if !database.Migrator().HasTable("b") || !database.Migrator().HasTable("c") {
+		t.Error("the second set was not applied")
+	}
+
+	if err := first.Check(); err != nil {
+		t.Errorf("first: %v", err)
+	}
+	if err := second.Check(); err != nil {
+		t.Errorf("second: %v", err)
+	}
+}
+
+func TestMigratorLock(t *testing.T) {
+	database := newTestDB(t)
+	migrations := []Migration{{Version: 1, Name: "a", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"}}
+	holder := New(database, "locked", migrations)
+	holder.owner = "other-host:1"
+
+	if err := holder.To(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := New(database, "locked", migrations).Up(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Up while another process holds the lock returned %v, want ErrLocked", err)
+	}
+	// Locks of other sets do not block
+	if err := New(database, "other", migrations[:0]).Up(); err != nil {
+		t.Errorf("Up of another set returned %v", err)
+	}
+
+	holder.unlock()
+	if err := New(database, "locked", migrations).Up(); err != nil {
+		t.Errorf("Up after the lock was released returned %v", err)
+	}
+}
+
+func TestMigratorKeepsLockWhileRunning(t *testing.T) {
+	database := newTestDB(t)
+	migrations := []Migration{{Version: 1, Name: "a", Up: "CREATE TABLE a (id INTEGER);", Down: "DROP TABLE a;"}}
+	newMigrator := func(owner string) *Migrator {
+		migrator := New(database, "slow", migrations)
+		migrator.owner = owner
+		migrator.lockTimeout = 100 * time.Millisecond
+		migrator.lockRefresh = 10 * time.Millisecond
+		return migrator
+	}
+
+	holder := newMigrator("running-host:1")
+	if err := holder.To(0); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A holder still running keeps refreshing its lock past the timeout
+	stop := holder.keepLocked()
+	time.Sleep(3 * holder.lockTimeout)
+	if err := newMigrator("other-host:1").Up(); !errors.Is(err, ErrLocked) {
+		t.Errorf("Up while the holder is running returned %v, want ErrLocked", err)
+	}
+
+	// Once it stopped refreshing, as a dead process does, the lock is taken over
+	stop()
+	time.Sleep(2 * holder.lockTimeout)
+	if err := newMigrator("other-host:1").Up(); err != nil {
+		t.Errorf("Up after the holder stopped refreshing returned %v", err)
+	}
+
+	// The old holder finds out before applying another migration
+	if err := newMigrator("other-host:1").lock(); err != nil {
+		t.Fatal(err)
+	}
+	if err := holder.refreshLock(); !errors.Is(err, ErrLockLost) {
+		t.Errorf("refreshing a lock taken over returned %v, want ErrLockLost", err)
+	}
+}
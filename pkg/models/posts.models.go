@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// FacebookPostAttachment is a link, photo or video attached to a Facebook post
+type FacebookPostAttachment struct {
+	Type        string `json:"type"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+	Media       struct {
+		Image struct {
+			Src string `json:"src"`
+		} `json:"image"`
+	} `json:"media"`
+}
+
+// FacebookPost is a struct that represents the post data that is returned from the Graph API
+type FacebookPost struct {
+	ID           string    `json:"id"`
+	Message      string    `json:"message"`
+	PermalinkURL string    `json:"permalink_url"`
+	CreatedTime  time.Time `json:"created_time"`
+	From         struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"from"`
+	Attachments []FacebookPostAttachment `json:"attachments"`
+}
@@ -22,6 +22,14 @@ type FacebookUser struct {
 	Picture FacebookUserPicture `json:"picture"`
 }
 
+// FacebookAccessToken is a user access token returned by the Facebook token endpoint
+type FacebookAccessToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	// ExpiresIn is the lifetime in seconds, zero for tokens that don't expire
+	ExpiresIn int64 `json:"expires_in"`
+}
+
 // User is a struct that represents the user data that is stored in the database
 
 type User struct {
@@ -33,7 +41,3 @@ type User struct {
 	Role       string `json:"role" gorm:"default:user"`
 	IsActive   bool   `json:"is_active" gorm:"default:true"`
 }
-
-func (u *User) IsModerator() bool {
-	return u.Role == RoleModerator || u.Role == RoleAdmin
-}
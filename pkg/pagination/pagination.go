@@ -0,0 +1,195 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	// MaxLimit caps the limit a client can ask for
+	MaxLimit = 100
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor is the position of a row in a listing ordered by an Order: the row's rank, its time and its ID.
+// Clients only see it encoded.
+type Cursor struct {
+	Rank float64   `json:"r,omitempty"`
+	Time time.Time `json:"t"`
+	ID   string    `json:"id"`
+}
+
+// Encode returns the opaque form of the cursor handed to clients
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a cursor returned by Encode
+func DecodeCursor(encoded string) (Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" || cursor.Time.IsZero() {
+		return Cursor{}, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+// Order is how a listing is sorted: by Time, with the ID breaking ties, after Rank when it is set. Rank is
+// a numeric column sorted highest first, Time a time column or expression and ID a unique column, id when
+// it is empty.
+type Order struct {
+	Rank      string
+	Time      string
+	ID        string
+	Ascending bool
+}
+
+func (o Order) idColumn() string {
+	if o.ID == "" {
+		return "id"
+	}
+	return o.ID
+}
+
+// NewestFirst is the order of listings that show the latest rows first
+var NewestFirst = Order{Time: "created_at"}
+
+// OldestFirst is the order of queues, which show the longest waiting rows first
+var OldestFirst = Order{Time: "created_at", Ascending: true}
+
+// after returns the condition selecting the rows that come after the cursor in the order
+func (o Order) after(cursor Cursor) (string, []interface{}) {
+	comparison := "<"
+	if o.Ascending {
+		comparison = ">"
+	}
+	condition := "(" + o.Time + " " + comparison + " ? OR (" + o.Time + " = ? AND " + o.idColumn() + " " + comparison + " ?))"
+	args := []interface{}{cursor.Time, cursor.Time, cursor.ID}
+
+	if o.Rank != "" {
+		condition = "(" + o.Rank + " < ? OR (" + o.Rank + " = ? AND " + condition + "))"
+		args = append([]interface{}{cursor.Rank, cursor.Rank}, args...)
+	}
+	return condition, args
+}
+
+// apply sorts the query in the order
+func (o Order) apply(query *gorm.DB) *gorm.DB {
+	direction := " DESC"
+	if o.Ascending {
+		direction = " ASC"
+	}
+	if o.Rank != "" {
+		query = query.Order(o.Rank + " DESC")
+	}
+	return query.Order(o.Time + direction).Order(o.idColumn() + direction)
+}
+
+// Params selects a page of a listing. Pages start after the After cursor, or at the first row when it is nil.
+type Params struct {
+	Limit     int
+	After     *Cursor
+	WithTotal bool
+}
+
+// ClampLimit returns the limit to use for a requested limit: the default when it is not positive and at most MaxLimit
+func ClampLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+// FromQuery reads the limit, cursor and total query params. total=true asks for the total number of rows.
+func FromQuery(c echo.Context) (Params, error) {
+	params := Params{Limit: DefaultLimit}
+
+	if value := c.QueryParam("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return Params{}, errors.New("limit must be a number")
+		}
+		params.Limit = ClampLimit(limit)
+	}
+
+	if value := c.QueryParam("cursor"); value != "" {
+		cursor, err := DecodeCursor(value)
+		if err != nil {
+			return Params{}, err
+		}
+		params.After = &cursor
+	}
+
+	if value := c.QueryParam("total"); value != "" {
+		withTotal, err := strconv.ParseBool(value)
+		if err != nil {
+			return Params{}, errors.New("total must be true or false")
+		}
+		params.WithTotal = withTotal
+	}
+
+	return params, nil
+}
+
+// Page is the envelope every paginated listing returns. NextCursor is empty on the last page and Total is
+// only set when it was asked for.
+type Page[T any] struct {
+	Items      []T    `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      *int64 `json:"total,omitempty"`
+}
+
+// Find loads a page of the rows query selects, newest first. The query must have its model set so the rows
+// can be counted. cursorOf returns the cursor of a row, it is used for the next cursor.
+func Find[T any](query *gorm.DB, params Params, cursorOf func(T) Cursor) (Page[T], error) {
+	return FindOrdered(query, params, NewestFirst, cursorOf)
+}
+
+// FindOrdered loads a page of the rows query selects in the order. cursorOf must fill in the cursor
+// fields the order sorts by.
+func FindOrdered[T any](query *gorm.DB, params Params, order Order, cursorOf func(T) Cursor) (Page[T], error) {
+	query = query.Session(&gorm.Session{})
+	params.Limit = ClampLimit(params.Limit)
+	page := Page[T]{Items: []T{}}
+
+	if params.WithTotal {
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			return Page[T]{}, err
+		}
+		page.Total = &total
+	}
+
+	if params.After != nil {
+		condition, args := order.after(*params.After)
+		query = query.Where(condition, args...)
+	}
+
+	// One extra row tells whether there is a next page
+	if err := order.apply(query).Limit(params.Limit + 1).Find(&page.Items).Error; err != nil {
+		return Page[T]{}, err
+	}
+
+	if len(page.Items) > params.Limit {
+		page.Items = page.Items[:params.Limit]
+		page.NextCursor = cursorOf(page.Items[len(page.Items)-1]).Encode()
+	}
+
+	return page, nil
+}
@@ -0,0 +1,181 @@
+package pagination
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/db"
+	"gorm.io/gorm"
+)
+
+type row struct {
+	ID        string `gorm:"primarykey"`
+	Rank      int64
+	CreatedAt time.Time
+}
+
+func rowCursor(r row) Cursor {
+	return Cursor{Rank: float64(r.Rank), Time: r.CreatedAt, ID: r.ID}
+}
+
+// newTestDB returns an in-memory database with the rows. Rows b, c and d share a creation time, so only
+// the ID orders them.
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	database, err := db.OpenMemory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if sqlDB, err := database.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	if err := database.AutoMigrate(&row{}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	rows := []row{
+		{ID: "a", Rank: 1, CreatedAt: start},
+		{ID: "b", Rank: 0, CreatedAt: start.Add(time.Minute)},
+		{ID: "c", Rank: 2, CreatedAt: start.Add(time.Minute)},
+		{ID: "d", Rank: 0, CreatedAt: start.Add(time.Minute)},
+		{ID: "e", Rank: 2, CreatedAt: start.Add(2 * time.Minute)},
+	}
+	if err := database.Create(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+// walk follows the next cursors from the first page to the last and returns the IDs in the order seen
+func walk(t *testing.T, database *gorm.DB, order Order, limit int) []string {
+	t.Helper()
+
+	var ids []string
+	params := Params{Limit: limit}
+	for pages := 0; ; pages++ {
+		if pages > 10 {
+			t.Fatalf("no last page after %v", ids)
+		}
+
+		page, err := FindOrdered(database.Model(&row{}), params, order, rowCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) > limit {
+			t.Fatalf("page of %d rows, limit %d", len(page.Items), limit)
+		}
+		for _, item := range page.Items {
+			ids = append(ids, item.ID)
+		}
+		if page.NextCursor == "" {
+			return ids
+		}
+
+		cursor, err := DecodeCursor(page.NextCursor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		params.After = &cursor
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	cursors := []Cursor{
+		{Time: time.Date(2026, 3, 1, 12, 0, 0, 123456789, time.UTC), ID: "a"},
+		{Rank: 7.25, Time: time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CAT", 2*60*60)), ID: "b"},
+	}
+
+	for _, cursor := range cursors {
+		decoded, err := DecodeCursor(cursor.Encode())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Rank != cursor.Rank || !decoded.Time.Equal(cursor.Time) || decoded.ID != cursor.ID {
+			t.Errorf("DecodeCursor(Encode(%+v)) = %+v", cursor, decoded)
+		}
+	}
+}
+
+func TestDecodeCursorErrors(t *testing.T) {
+	for _, encoded := range []string{
+		"",
+		"not base64!",
+		Cursor{ID: "a"}.Encode(),
+		Cursor{Time: time.Now()}.Encode(),
+		"bm90IGpzb24",
+	} {
+		if _, err := DecodeCursor(encoded); !errors.Is(err, ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) returned %v, want ErrInvalidCursor", encoded, err)
+		}
+	}
+}
+
+func TestClampLimit(t *testing.T) {
+	for limit, want := range map[int]int{-1: DefaultLimit, 0: DefaultLimit, 1: 1, MaxLimit: MaxLimit, MaxLimit + 1: MaxLimit} {
+		if got := ClampLimit(limit); got != want {
+			t.Errorf("ClampLimit(%d) = %d, want %d", limit, got, want)
+		}
+	}
+}
+
+func TestFindOrdered(t *testing.T) {
+	database := newTestDB(t)
+
+	tests := []struct {
+		name  string
+		order Order
+		want  string
+	}{
+		{"newest first breaks ties by ID", NewestFirst, "e d c b a"},
+		{"oldest first breaks ties by ID", OldestFirst, "a b c d e"},
+		{"rank before time", Order{Rank: "rank", Time: "created_at", Ascending: true}, "c e a b d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Every page size splits the rows that share a creation time differently
+			for limit := 1; limit <= 6; limit++ {
+				if ids := strings.Join(walk(t, database, tt.order, limit), " "); ids != tt.want {
+					t.Errorf("pages of %d: rows %s, want %s", limit, ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestFindTotal(t *testing.T) {
+	database := newTestDB(t)
+
+	page, err := Find(database.Model(&row{}), Params{Limit: 2}, rowCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != nil {
+		t.Errorf("total = %d without asking for it", *page.Total)
+	}
+
+	cursor, err := DecodeCursor(page.NextCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, err = Find(database.Model(&row{}).Where("id <> ?", "a"), Params{Limit: 2, After: &cursor, WithTotal: true}, rowCursor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The total counts every row the query selects, not only those after the cursor
+	if page.Total == nil || *page.Total != 4 {
+		t.Errorf("total = %v, want 4", page.Total)
+	}
+	if len(page.Items) != 2 || page.Items[0].ID != "c" || page.Items[1].ID != "b" {
+		t.Errorf("second page = %+v, want c, b", page.Items)
+	}
+	if page.NextCursor != "" {
+		t.Errorf("last page has next cursor %q", page.NextCursor)
+	}
+}
@@ -0,0 +1,129 @@
+package permissions
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+// Permission is a named capability that roles are granted
+type Permission string
+
+const (
+	SummaryRequest  Permission = "summary:request"
+	SummaryRate     Permission = "summary:rate"
+	SummaryModerate Permission = "summary:moderate"
+	SummaryEdit     Permission = "summary:edit"
+	SummaryOverride Permission = "summary:override"
+	SummaryAppeal   Permission = "summary:appeal"
+	SummarySuggest  Permission = "summary:suggest"
+	ResourceAdd     Permission = "resource:add"
+	ResourceDelete  Permission = "resource:delete"
+	UserSetRole     Permission = "user:set-role"
+	UserSetStatus   Permission = "user:set-status"
+	AuditRead       Permission = "audit:read"
+
+	// All grants every permission
+	All Permission = "*"
+)
+
+// Resource types, for the Type of a Resource
+const (
+	ResourceSummary = "summary"
+	ResourceLink    = "resource_link"
+	ResourceUser    = "user"
+	ResourceAudit   = "audit"
+)
+
+var (
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrForbidden       = errors.New("forbidden")
+)
+
+var known = map[Permission]bool{
+	SummaryRequest: true, SummaryRate: true, SummaryModerate: true, SummaryEdit: true, SummaryAppeal: true, SummarySuggest: true, SummaryOverride: true,
+	ResourceAdd: true, ResourceDelete: true, UserSetRole: true, UserSetStatus: true, AuditRead: true, All: true,
+}
+
+// Resource is the object a permission is checked against. The zero value stands for no particular object.
+type Resource struct {
+	Type string
+	ID   string
+}
+
+// DefaultRoles is the role to permission mapping used when the config does not define roles
+func DefaultRoles() map[string][]string {
+	return map[string][]string{
+		models.RoleUser: {
+			string(SummaryRequest), string(SummaryRate), string(SummaryAppeal), string(SummarySuggest),
+		},
+		models.RoleModerator: {
+			string(SummaryRequest), string(SummaryRate), string(SummaryAppeal), string(SummarySuggest), string(SummaryModerate), string(SummaryEdit),
+			string(ResourceAdd), string(ResourceDelete), string(UserSetStatus),
+		},
+		models.RoleAdmin: {
+			string(All),
+		},
+	}
+}
+
+// Policy maps roles to the permissions they are granted
+type Policy struct {
+	roles map[string]map[Permission]bool
+}
+
+// NewPolicy builds a policy from a role to permission mapping, such as the roles section of the config.
+// An empty mapping falls back to DefaultRoles.
+func NewPolicy(roles map[string][]string) (*Policy, error) {
+	if len(roles) == 0 {
+		roles = DefaultRoles()
+	}
+
+	policy := &Policy{roles: map[string]map[Permission]bool{}}
+	for role, granted := range roles {
+		policy.roles[role] = map[Permission]bool{}
+		for _, name := range granted {
+			permission := Permission(name)
+			if !known[permission] {
+				return nil, fmt.Errorf("role %s: unknown permission %q", role, name)
+			}
+			policy.roles[role][permission] = true
+		}
+	}
+
+	return policy, nil
+}
+
+// HasRole reports whether the role is defined by the policy
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[role]
+	return ok
+}
+
+// Can reports whether the role is granted the permission
+func (p *Policy) Can(role string, permission Permission) bool {
+	granted := p.roles[role]
+	return granted[All] || granted[permission]
+}
+
+// Authorize checks that the principal may use the permission on the resource. It returns
+// ErrUnauthenticated for anonymous principals and ErrForbidden when the permission is not granted.
+func (p *Policy) Authorize(principal utils.Principal, permission Permission, resource Resource) error {
+	if principal.UserID == "" {
+		return ErrUnauthenticated
+	}
+
+	if !p.Can(principal.Role, permission) {
+		return ErrForbidden
+	}
+
+	// Nobody changes their own role or status, so users can't grant themselves a role or reactivate
+	// themselves. It does not stop one admin from demoting or deactivating another.
+	if (permission == UserSetRole || permission == UserSetStatus) && resource.Type == ResourceUser && resource.ID == principal.UserID {
+		return ErrForbidden
+	}
+
+	return nil
+}
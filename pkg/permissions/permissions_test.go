@@ -0,0 +1,141 @@
+package permissions
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/models"
+	"github.com/mwelwankuta/facebook-notes/pkg/utils"
+)
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		roles map[string][]string
+		err   string
+	}{
+		{name: "nil falls back to the defaults"},
+		{name: "empty falls back to the defaults", roles: map[string][]string{}},
+		{name: "custom roles", roles: map[string][]string{"reviewer": {string(SummaryModerate)}}},
+		{name: "role without permissions", roles: map[string][]string{"guest": {}}},
+		{name: "unknown permission", roles: map[string][]string{"reviewer": {"summary:delete"}}, err: `role reviewer: unknown permission "summary:delete"`},
+		{name: "permission with the wrong case", roles: map[string][]string{"reviewer": {"Summary:Edit"}}, err: "unknown permission"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := NewPolicy(tt.roles)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("NewPolicy returned %v, want an error containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := tt.roles
+			if len(want) == 0 {
+				want = DefaultRoles()
+			}
+			for role := range want {
+				if !policy.HasRole(role) {
+					t.Errorf("role %s is missing", role)
+				}
+			}
+			if len(tt.roles) > 0 && policy.HasRole(models.RoleAdmin) {
+				t.Error("the default roles are added to a configured mapping")
+			}
+		})
+	}
+}
+
+func TestCan(t *testing.T) {
+	policy, err := NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		role       string
+		permission Permission
+		want       bool
+	}{
+		{models.RoleUser, SummaryRequest, true},
+		{models.RoleUser, SummarySuggest, true},
+		{models.RoleUser, SummaryModerate, false},
+		{models.RoleUser, UserSetRole, false},
+		{models.RoleModerator, SummaryModerate, true},
+		{models.RoleModerator, UserSetStatus, true},
+		{models.RoleModerator, SummaryOverride, false},
+		{models.RoleModerator, UserSetRole, false},
+		{models.RoleAdmin, SummaryOverride, true},
+		{models.RoleAdmin, UserSetRole, true},
+		{models.RoleAdmin, AuditRead, true},
+		{"unknown", SummaryRequest, false},
+		{"", SummaryRequest, false},
+	}
+
+	for _, tt := range tests {
+		if got := policy.Can(tt.role, tt.permission); got != tt.want {
+			t.Errorf("Can(%q, %s) = %v, want %v", tt.role, tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestCanWildcard(t *testing.T) {
+	policy, err := NewPolicy(map[string][]string{"root": {string(All)}, "auditor": {string(AuditRead)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for permission := range known {
+		if !policy.Can("root", permission) {
+			t.Errorf("* does not grant %s", permission)
+		}
+	}
+	if policy.Can("auditor", SummaryEdit) {
+		t.Error("a role without * is granted permissions it doesn't list")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	policy, err := NewPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := utils.Principal{UserID: "admin-1", Role: models.RoleAdmin}
+	moderator := utils.Principal{UserID: "moderator-1", Role: models.RoleModerator}
+	user := utils.Principal{UserID: "user-1", Role: models.RoleUser}
+
+	tests := []struct {
+		name       string
+		principal  utils.Principal
+		permission Permission
+		resource   Resource
+		err        error
+	}{
+		{"anonymous", utils.Principal{Role: models.RoleAdmin}, SummaryRequest, Resource{}, ErrUnauthenticated},
+		{"granted", user, SummaryRate, Resource{Type: ResourceSummary, ID: "summary-1"}, nil},
+		{"not granted", user, SummaryModerate, Resource{Type: ResourceSummary, ID: "summary-1"}, ErrForbidden},
+		{"admin sets another user's role", admin, UserSetRole, Resource{Type: ResourceUser, ID: "user-1"}, nil},
+		{"admin sets their own role", admin, UserSetRole, Resource{Type: ResourceUser, ID: "admin-1"}, ErrForbidden},
+		{"admin sets their own status", admin, UserSetStatus, Resource{Type: ResourceUser, ID: "admin-1"}, ErrForbidden},
+		{"moderator sets another user's status", moderator, UserSetStatus, Resource{Type: ResourceUser, ID: "user-1"}, nil},
+		{"moderator sets their own status", moderator, UserSetStatus, Resource{Type: ResourceUser, ID: "moderator-1"}, ErrForbidden},
+		{"moderator sets a role", moderator, UserSetRole, Resource{Type: ResourceUser, ID: "user-1"}, ErrForbidden},
+		{"own ID on another resource type", admin, UserSetRole, Resource{Type: ResourceSummary, ID: "admin-1"}, nil},
+		{"other permissions on themselves", admin, AuditRead, Resource{Type: ResourceUser, ID: "admin-1"}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := policy.Authorize(tt.principal, tt.permission, tt.resource); !errors.Is(err, tt.err) {
+				t.Errorf("Authorize returned %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultVisibilityTimeout = 5 * time.Minute
+	DefaultMaxAttempts       = 5
+	DefaultBaseBackoff       = 5 * time.Second
+	DefaultMaxBackoff        = 10 * time.Minute
+)
+
+var ErrJobNotOwned = errors.New("job is no longer owned by this worker")
+
+// Job is a unit of work stored in the queue
+type Job struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	Payload    json.RawMessage `json:"payload"`
+	EnqueuedAt time.Time       `json:"enqueued_at"`
+
+	// Attempts and receipt are filled in when the job is dequeued
+	Attempts int `json:"-"`
+	receipt  string
+}
+
+// DeadLetter is a job that ran out of attempts or failed permanently
+type DeadLetter struct {
+	Job      Job       `json:"job"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// Options configures retries and visibility of a queue
+type Options struct {
+	VisibilityTimeout time.Duration
+	MaxAttempts       int
+	BaseBackoff       time.Duration
+	MaxBackoff        time.Duration
+}
+
+// Queue is a durable job queue stored in redis.
+// Dequeued jobs stay in an in-flight set until they are acked; jobs whose visibility timeout
+// expires are handed out again, so a crashed worker never loses work.
+type Queue struct {
+	client *redis.Client
+	name   string
+	opts   Options
+}
+
+// NewQueue creates a new Queue, using the defaults for any option left empty
+func NewQueue(redisClient *adapters.RedisClient, name string, opts Options) *Queue {
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = DefaultBaseBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = DefaultMaxBackoff
+	}
+
+	return &Queue{
+		client: redisClient.Client(),
+		name:   name,
+		opts:   opts,
+	}
+}
+
+func (q *Queue) key(suffix string) string {
+	return fmt.Sprintf("queue:%s:%s", q.name, suffix)
+}
+
+// Enqueue stores a new job of the given type, ready to be picked up immediately
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload interface{}) (Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, err
+	}
+
+	job := Job{
+		ID:         uuid.New().String(),
+		Type:       jobType,
+		Payload:    data,
+		EnqueuedAt: time.Now(),
+	}
+
+	encoded, err := json.Marshal(job)
+	if err != nil {
+		return Job{}, err
+	}
+
+	_, err = q.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, q.key("jobs"), job.ID, encoded)
+		pipe.ZAdd(ctx, q.key("ready"), redis.Z{Score: float64(job.EnqueuedAt.UnixMilli()), Member: job.ID})
+		return nil
+	})
+	return job, err
+}
+
+// dequeueScript requeues in-flight jobs whose visibility timeout expired, then moves the
+// oldest ready job into the in-flight set. Jobs that already used all their attempts are dead lettered.
+var dequeueScript = redis.NewScript(`
+local ready, inflight, jobs, attempts, receipts, dead = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5], KEYS[6]
+local now, deadline, maxAttempts, receipt = ARGV[1], ARGV[2], tonumber(ARGV[3]), ARGV[4]
+
+for _, id in ipairs(redis.call('ZRANGEBYSCORE', inflight, '-inf', now)) do
+	redis.call('ZREM', inflight, id)
+	redis.call('HDEL', receipts, id)
+	redis.call('ZADD', ready, now, id)
+end
+
+while true do
+	local ids = redis.call('ZRANGEBYSCORE', ready, '-inf', now, 'LIMIT', 0, 1)
+	if #ids == 0 then
+		return false
+	end
+
+	local id = ids[1]
+	redis.call('ZREM', ready, id)
+	local job = redis.call('HGET', jobs, id)
+	if job then
+		local count = redis.call('HINCRBY', attempts, id, 1)
+		if count > maxAttempts then
+			redis.call('LPUSH', dead, cjson.encode({job = cjson.decode(job), attempts = count - 1, error = 'visibility timeout expired', failed_at = ARGV[5]}))
+			redis.call('HDEL', jobs, id)
+			redis.call('HDEL', attempts, id)
+		else
+			redis.call('ZADD', inflight, deadline, id)
+			redis.call('HSET', receipts, id, receipt)
+			return {job, count}
+		end
+	end
+end
+`)
+
+// Dequeue claims the next ready job. It returns nil when the queue is empty.
+func (q *Queue) Dequeue(ctx context.Context) (*Job, error) {
+	now := time.Now()
+	receipt := uuid.New().String()
+
+	result, err := dequeueScript.Run(ctx, q.client,
+		[]string{q.key("ready"), q.key("inflight"), q.key("jobs"), q.key("attempts"), q.key("receipts"), q.key("dead")},
+		now.UnixMilli(), now.Add(q.opts.VisibilityTimeout).UnixMilli(), q.opts.MaxAttempts, receipt, now.Format(time.RFC3339),
+	).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := result.([]interface{})
+	if !ok || len(values) != 2 {
+		return nil, fmt.Errorf("unexpected dequeue result %v", result)
+	}
+
+	var job Job
+	if err := json.Unmarshal([]byte(values[0].(string)), &job); err != nil {
+		return nil, err
+	}
+	job.Attempts = int(values[1].(int64))
+	job.receipt = receipt
+
+	return &job, nil
+}
+
+// ackScript removes a job if the caller still holds its receipt
+var ackScript = redis.NewScript(`
+local inflight, jobs, attempts, receipts = KEYS[1], KEYS[2], KEYS[3], KEYS[4]
+local id, receipt = ARGV[1], ARGV[2]
+
+if redis.call('HGET', receipts, id) ~= receipt then
+	return 0
+end
+
+redis.call('ZREM', inflight, id)
+redis.call('HDEL', receipts, id)
+redis.call('HDEL', jobs, id)
+redis.call('HDEL', attempts, id)
+return 1
+`)
+
+// Ack marks a job as done and removes it from the queue
+func (q *Queue) Ack(ctx context.Context, job *Job) error {
+	owned, err := ackScript.Run(ctx, q.client,
+		[]string{q.key("inflight"), q.key("jobs"), q.key("attempts"), q.key("receipts")},
+		job.ID, job.receipt,
+	).Int()
+	if err != nil {
+		return err
+	}
+	if owned == 0 {
+		return ErrJobNotOwned
+	}
+	return nil
+}
+
+// retryScript schedules a job to become ready again after a delay if the caller still holds its receipt
+var retryScript = redis.NewScript(`
+local ready, inflight, receipts = KEYS[1], KEYS[2], KEYS[3]
+local id, receipt, availableAt = ARGV[1], ARGV[2], ARGV[3]
+
+if redis.call('HGET', receipts, id) ~= receipt then
+	return 0
+end
+
+redis.call('ZREM', inflight, id)
+redis.call('HDEL', receipts, id)
+redis.call('ZADD', ready, availableAt, id)
+return 1
+`)
+
+// deadLetterScript moves a job to the dead letter list if the caller still holds its receipt
+var deadLetterScript = redis.NewScript(`
+local inflight, jobs, attempts, receipts, dead = KEYS[1], KEYS[2], KEYS[3], KEYS[4], KEYS[5]
+local id, receipt, entry = ARGV[1], ARGV[2], ARGV[3]
+
+if redis.call('HGET', receipts, id) ~= receipt then
+	return 0
+end
+
+redis.call('ZREM', inflight, id)
+redis.call('HDEL', receipts, id)
+redis.call('HDEL', jobs, id)
+redis.call('HDEL', attempts, id)
+redis.call('LPUSH', dead, entry)
+return 1
+`)
+
+// Fail records a failed attempt. The job is retried with exponential backoff until it runs out of
+// attempts, after which it is moved to the dead letter list. Permanent errors are dead lettered straight away.
+func (q *Queue) Fail(ctx context.Context, job *Job, jobErr error) error {
+	var permanent *PermanentError
+	if job.Attempts >= q.opts.MaxAttempts || errors.As(jobErr, &permanent) {
+		entry, err := json.Marshal(DeadLetter{
+			Job:      *job,
+			Attempts: job.Attempts,
+			Error:    jobErr.Error(),
+			FailedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+
+		owned, err := deadLetterScript.Run(ctx, q.client,
+			[]string{q.key("inflight"), q.key("jobs"), q.key("attempts"), q.key("receipts"), q.key("dead")},
+			job.ID, job.receipt, entry,
+		).Int()
+		if err != nil {
+			return err
+		}
+		if owned == 0 {
+			return ErrJobNotOwned
+		}
+		return nil
+	}
+
+	availableAt := time.Now().Add(q.Backoff(job.Attempts))
+	owned, err := retryScript.Run(ctx, q.client,
+		[]string{q.key("ready"), q.key("inflight"), q.key("receipts")},
+		job.ID, job.receipt, strconv.FormatInt(availableAt.UnixMilli(), 10),
+	).Int()
+	if err != nil {
+		return err
+	}
+	if owned == 0 {
+		return ErrJobNotOwned
+	}
+	return nil
+}
+
+// Backoff returns the delay before the next attempt: the base backoff doubled for every
+// attempt already made, capped at the max backoff, with up to 10% jitter
+func (q *Queue) Backoff(attempts int) time.Duration {
+	delay := q.opts.BaseBackoff
+	for i := 1; i < attempts && delay < q.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > q.opts.MaxBackoff {
+		delay = q.opts.MaxBackoff
+	}
+
+	return delay + time.Duration(rand.Int63n(int64(delay)/10+1))
+}
+
+// DeadLetters returns the most recent dead lettered jobs
+func (q *Queue) DeadLetters(ctx context.Context, limit int64) ([]DeadLetter, error) {
+	values, err := q.client.LRange(ctx, q.key("dead"), 0, limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(values))
+	for _, value := range values {
+		var letter DeadLetter
+		if err := json.Unmarshal([]byte(value), &letter); err != nil {
+			return nil, err
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// Stats reports how many jobs are ready, in flight and dead lettered
+type Stats struct {
+	Ready    int64 `json:"ready"`
+	InFlight int64 `json:"in_flight"`
+	Dead     int64 `json:"dead"`
+}
+
+// Stats returns the current size of each part of the queue
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	var ready, inflight, dead *redis.IntCmd
+	_, err := q.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		ready = pipe.ZCard(ctx, q.key("ready"))
+		inflight = pipe.ZCard(ctx, q.key("inflight"))
+		dead = pipe.LLen(ctx, q.key("dead"))
+		return nil
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return Stats{Ready: ready.Val(), InFlight: inflight.Val(), Dead: dead.Val()}, nil
+}
+
+// PermanentError marks a job failure that should not be retried
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent wraps err so the job is dead lettered instead of retried
+func Permanent(err error) error {
+	return &PermanentError{Err: err}
+}
@@ -0,0 +1,231 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+)
+
+// newTestQueue returns a queue on a new miniredis server. The queue scores jobs by the wall clock, so
+// tests use short timeouts and sleep past them.
+func newTestQueue(t *testing.T, opts Options) *Queue {
+	t.Helper()
+	server := miniredis.RunT(t)
+	return NewQueue(adapters.NewRedisClient(server.Addr(), "", 0), "test", opts)
+}
+
+func dequeue(t *testing.T, q *Queue) *Job {
+	t.Helper()
+	job, err := q.Dequeue(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return job
+}
+
+func stats(t *testing.T, q *Queue) Stats {
+	t.Helper()
+	stats, err := q.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func TestEnqueueDequeueAck(t *testing.T) {
+	q := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	first, err := q.Enqueue(ctx, "summarize", map[string]string{"request_id": "first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Millisecond)
+	if _, err := q.Enqueue(ctx, "summarize", map[string]string{"request_id": "second"}); err != nil {
+		t.Fatal(err)
+	}
+
+	job := dequeue(t, q)
+	if job == nil || job.ID != first.ID || job.Type != "summarize" || job.Attempts != 1 {
+		t.Fatalf("dequeued %+v, want the first job on its first attempt", job)
+	}
+	var payload map[string]string
+	if err := json.Unmarshal(job.Payload, &payload); err != nil || payload["request_id"] != "first" {
+		t.Errorf("payload = %s, %v", job.Payload, err)
+	}
+	if got := stats(t, q); got != (Stats{Ready: 1, InFlight: 1}) {
+		t.Errorf("stats = %+v, want one ready and one in flight", got)
+	}
+
+	if err := q.Ack(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Ack(ctx, job); !errors.Is(err, ErrJobNotOwned) {
+		t.Errorf("acking twice returned %v, want ErrJobNotOwned", err)
+	}
+
+	if second := dequeue(t, q); second == nil || second.ID == first.ID {
+		t.Fatalf("dequeued %+v, want the second job", second)
+	}
+	if job := dequeue(t, q); job != nil {
+		t.Errorf("dequeued %+v from an empty queue", job)
+	}
+}
+
+func TestVisibilityTimeoutRequeues(t *testing.T) {
+	q := newTestQueue(t, Options{VisibilityTimeout: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	if _, err := q.Enqueue(ctx, "summarize", nil); err != nil {
+		t.Fatal(err)
+	}
+	abandoned := dequeue(t, q)
+	if job := dequeue(t, q); job != nil {
+		t.Fatalf("dequeued %+v while it was in flight", job)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	redelivered := dequeue(t, q)
+	if redelivered == nil || redelivered.ID != abandoned.ID || redelivered.Attempts != 2 {
+		t.Fatalf("dequeued %+v, want the abandoned job on its second attempt", redelivered)
+	}
+
+	// The worker that timed out no longer owns the job
+	if err := q.Ack(ctx, abandoned); !errors.Is(err, ErrJobNotOwned) {
+		t.Errorf("acking with an expired receipt returned %v, want ErrJobNotOwned", err)
+	}
+	if err := q.Fail(ctx, abandoned, errors.New("late failure")); !errors.Is(err, ErrJobNotOwned) {
+		t.Errorf("failing with an expired receipt returned %v, want ErrJobNotOwned", err)
+	}
+	if err := q.Ack(ctx, redelivered); err != nil {
+		t.Fatal(err)
+	}
+	if got := stats(t, q); got != (Stats{}) {
+		t.Errorf("stats = %+v, want an empty queue", got)
+	}
+}
+
+func TestVisibilityTimeoutDeadLetters(t *testing.T) {
+	q := newTestQueue(t, Options{VisibilityTimeout: 10 * time.Millisecond, MaxAttempts: 1})
+	ctx := context.Background()
+
+	enqueued, err := q.Enqueue(ctx, "summarize", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dequeue(t, q)
+
+	time.Sleep(20 * time.Millisecond)
+	if job := dequeue(t, q); job != nil {
+		t.Fatalf("dequeued %+v after its last attempt", job)
+	}
+
+	letters, err := q.DeadLetters(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].Job.ID != enqueued.ID || letters[0].Attempts != 1 || letters[0].Error != "visibility timeout expired" {
+		t.Errorf("dead letters = %+v, want the timed out job", letters)
+	}
+	if got := stats(t, q); got != (Stats{Dead: 1}) {
+		t.Errorf("stats = %+v, want one dead letter", got)
+	}
+}
+
+func TestFailRetriesAfterBackoff(t *testing.T) {
+	q := newTestQueue(t, Options{BaseBackoff: 30 * time.Millisecond})
+	ctx := context.Background()
+
+	if _, err := q.Enqueue(ctx, "summarize", nil); err != nil {
+		t.Fatal(err)
+	}
+	job := dequeue(t, q)
+	if err := q.Fail(ctx, job, errors.New("provider unavailable")); err != nil {
+		t.Fatal(err)
+	}
+
+	if retried := dequeue(t, q); retried != nil {
+		t.Fatalf("dequeued %+v before its backoff passed", retried)
+	}
+	if got := stats(t, q); got != (Stats{Ready: 1}) {
+		t.Errorf("stats = %+v, want the job waiting to be retried", got)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	retried := dequeue(t, q)
+	if retried == nil || retried.ID != job.ID || retried.Attempts != 2 {
+		t.Errorf("dequeued %+v, want the job on its second attempt", retried)
+	}
+}
+
+func TestFailDeadLetters(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		attempts int
+	}{
+		{"out of attempts", errors.New("provider unavailable"), 2},
+		{"permanent error", Permanent(errors.New("request not found")), 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q := newTestQueue(t, Options{MaxAttempts: 2, BaseBackoff: time.Millisecond})
+			ctx := context.Background()
+
+			if _, err := q.Enqueue(ctx, "summarize", nil); err != nil {
+				t.Fatal(err)
+			}
+			var job *Job
+			for attempt := 1; attempt <= test.attempts; attempt++ {
+				time.Sleep(5 * time.Millisecond)
+				if job = dequeue(t, q); job == nil {
+					t.Fatalf("attempt %d was not dequeued", attempt)
+				}
+				if err := q.Fail(ctx, job, test.err); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			letters, err := q.DeadLetters(ctx, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(letters) != 1 || letters[0].Job.ID != job.ID || letters[0].Attempts != test.attempts || letters[0].Error != test.err.Error() {
+				t.Errorf("dead letters = %+v, want the job after %d attempts", letters, test.attempts)
+			}
+			if got := stats(t, q); got != (Stats{Dead: 1}) {
+				t.Errorf("stats = %+v, want only the dead letter", got)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	q := &Queue{opts: Options{BaseBackoff: time.Second, MaxBackoff: 10 * time.Second}}
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			// Up to 10% jitter is added on top of the delay
+			if got := q.Backoff(test.attempts); got < test.want || got > test.want+test.want/10 {
+				t.Errorf("Backoff(%d) = %s, want %s plus at most 10%%", test.attempts, got, test.want)
+				break
+			}
+		}
+	}
+}
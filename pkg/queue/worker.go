@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+const DefaultPollInterval = time.Second
+
+// Handler processes a single job. Returning an error schedules a retry.
+type Handler func(ctx context.Context, job Job) error
+
+// WorkerPool drains a queue with a fixed number of concurrent workers
+type WorkerPool struct {
+	queue        *Queue
+	handler      Handler
+	workers      int
+	pollInterval time.Duration
+}
+
+// NewWorkerPool creates a new WorkerPool with at least one worker
+func NewWorkerPool(queue *Queue, handler Handler, workers int) *WorkerPool {
+	if workers < 1 {
+		workers = 1
+	}
+
+	return &WorkerPool{
+		queue:        queue,
+		handler:      handler,
+		workers:      workers,
+		pollInterval: DefaultPollInterval,
+	}
+}
+
+// Run starts the workers and blocks until ctx is cancelled and every in-progress job has finished
+func (p *WorkerPool) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			p.work(ctx, worker)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func (p *WorkerPool) work(ctx context.Context, worker int) {
+	for {
+		if ctx.Err() != nil {
+			return
+		}
+
+		job, err := p.queue.Dequeue(ctx)
+		if err != nil && !errors.Is(err, context.Canceled) {
+			log.Printf("worker %d: dequeue failed: %v", worker, err)
+		}
+		if job == nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(p.pollInterval):
+			}
+			continue
+		}
+
+		p.process(ctx, worker, job)
+	}
+}
+
+func (p *WorkerPool) process(ctx context.Context, worker int, job *Job) {
+	// Jobs are bounded by the visibility timeout so a stuck handler can't hold a job another worker will pick up
+	jobCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), p.queue.opts.VisibilityTimeout)
+	defer cancel()
+
+	if err := p.handler(jobCtx, *job); err != nil {
+		log.Printf("worker %d: job %s (%s) attempt %d failed: %v", worker, job.ID, job.Type, job.Attempts, err)
+		if err := p.queue.Fail(jobCtx, job, err); err != nil {
+			log.Printf("worker %d: could not record failure of job %s: %v", worker, job.ID, err)
+		}
+		return
+	}
+
+	if err := p.queue.Ack(jobCtx, job); err != nil {
+		log.Printf("worker %d: could not ack job %s: %v", worker, job.ID, err)
+	}
+}
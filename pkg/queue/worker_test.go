@@ -0,0 +1,120 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// runPool starts the pool and returns a function that stops it and waits for Run to return
+func runPool(pool *WorkerPool) (stop func(), done <-chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	finished := make(chan struct{})
+	go func() {
+		pool.Run(ctx)
+		close(finished)
+	}()
+	return cancel, finished
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWorkerPoolProcessesJobs(t *testing.T) {
+	q := newTestQueue(t, Options{BaseBackoff: time.Millisecond})
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	processed := map[string]int{}
+	pool := NewWorkerPool(q, func(ctx context.Context, job Job) error {
+		mu.Lock()
+		defer mu.Unlock()
+		processed[job.ID]++
+		// Every job fails its first attempt
+		if job.Attempts == 1 {
+			return errors.New("provider unavailable")
+		}
+		return nil
+	}, 3)
+	pool.pollInterval = 5 * time.Millisecond
+
+	for i := 0; i < 5; i++ {
+		if _, err := q.Enqueue(ctx, "summarize", i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stop, done := runPool(pool)
+	waitFor(t, func() bool {
+		s, err := q.Stats(ctx)
+		return err == nil && s == (Stats{})
+	})
+	stop()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(processed) != 5 {
+		t.Errorf("%d jobs processed, want 5", len(processed))
+	}
+	for id, attempts := range processed {
+		if attempts != 2 {
+			t.Errorf("job %s ran %d times, want 2", id, attempts)
+		}
+	}
+}
+
+func TestWorkerPoolShutdownFinishesJobs(t *testing.T) {
+	q := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var jobErr error
+	pool := NewWorkerPool(q, func(ctx context.Context, job Job) error {
+		close(started)
+		<-release
+		// Shutting down doesn't cancel a job that is already running
+		jobErr = ctx.Err()
+		return nil
+	}, 2)
+	pool.pollInterval = 5 * time.Millisecond
+
+	if _, err := q.Enqueue(ctx, "summarize", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stop, done := runPool(pool)
+	<-started
+	stop()
+
+	select {
+	case <-done:
+		t.Fatal("Run returned while a job was running")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Run did not return after the job finished")
+	}
+
+	if jobErr != nil {
+		t.Errorf("the job's context was cancelled: %v", jobErr)
+	}
+	if s := stats(t, q); s != (Stats{}) {
+		t.Errorf("stats = %+v, want the job acked", s)
+	}
+}
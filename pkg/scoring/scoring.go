@@ -0,0 +1,196 @@
+package scoring
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	StatusHelpful          = "helpful"
+	StatusNotHelpful       = "not_helpful"
+	StatusNeedsMoreRatings = "needs_more_ratings"
+)
+
+// Rating is one rater's helpfulness rating of a note, scaled to [0, 1]
+type Rating struct {
+	RaterID string
+	NoteID  string
+	Value   float64
+}
+
+// Params configures the matrix factorization and the status thresholds
+type Params struct {
+	Epochs          int
+	LearningRate    float64
+	InterceptLambda float64
+	FactorLambda    float64
+	Seed            int64
+
+	// MinRatings is the number of ratings a note needs before it can leave needs_more_ratings
+	MinRatings int
+	// A note is helpful when its intercept is at least HelpfulIntercept and the magnitude of its
+	// factor is below MaxHelpfulFactor, so notes only one side of the factor axis likes never qualify
+	HelpfulIntercept float64
+	MaxHelpfulFactor float64
+	// A note is not helpful when its intercept is at most NotHelpfulIntercept - NotHelpfulFactorSlope*|factor|
+	NotHelpfulIntercept   float64
+	NotHelpfulFactorSlope float64
+}
+
+// DefaultParams returns the parameters used by the Community Notes scorer
+func DefaultParams() Params {
+	return Params{
+		Epochs:                200,
+		LearningRate:          0.05,
+		InterceptLambda:       0.15,
+		FactorLambda:          0.03,
+		Seed:                  42,
+		MinRatings:            5,
+		HelpfulIntercept:      0.40,
+		MaxHelpfulFactor:      0.50,
+		NotHelpfulIntercept:   -0.05,
+		NotHelpfulFactorSlope: 0.8,
+	}
+}
+
+// NoteScore is the outcome of scoring a single note
+type NoteScore struct {
+	NoteID    string  `json:"note_id"`
+	Intercept float64 `json:"intercept"`
+	Factor    float64 `json:"factor"`
+	Ratings   int     `json:"ratings"`
+	Status    string  `json:"status"`
+}
+
+// RaterScore is what the model learned about a single rater
+type RaterScore struct {
+	RaterID   string  `json:"rater_id"`
+	Intercept float64 `json:"intercept"`
+	Factor    float64 `json:"factor"`
+}
+
+// Result holds the learned model for every note and rater
+type Result struct {
+	GlobalIntercept float64      `json:"global_intercept"`
+	Notes           []NoteScore  `json:"notes"`
+	Raters          []RaterScore `json:"raters"`
+}
+
+// Score fits rating ≈ μ + rater intercept + note intercept + rater factor · note factor over the full
+// rater×note matrix. The factors capture the viewpoint raters usually agree along, so the note intercept
+// only rewards the helpfulness that raters from opposite sides of that viewpoint agree on.
+func Score(ratings []Rating, params Params) Result {
+	raterIndex := map[string]int{}
+	noteIndex := map[string]int{}
+	var raterIDs, noteIDs []string
+
+	type observation struct {
+		rater, note int
+		value       float64
+	}
+	observations := make([]observation, 0, len(ratings))
+
+	for _, rating := range ratings {
+		r, ok := raterIndex[rating.RaterID]
+		if !ok {
+			r = len(raterIDs)
+			raterIndex[rating.RaterID] = r
+			raterIDs = append(raterIDs, rating.RaterID)
+		}
+		n, ok := noteIndex[rating.NoteID]
+		if !ok {
+			n = len(noteIDs)
+			noteIndex[rating.NoteID] = n
+			noteIDs = append(noteIDs, rating.NoteID)
+		}
+		observations = append(observations, observation{rater: r, note: n, value: rating.Value})
+	}
+
+	random := rand.New(rand.NewSource(params.Seed))
+
+	var global float64
+	raterIntercepts := make([]float64, len(raterIDs))
+	noteIntercepts := make([]float64, len(noteIDs))
+	raterFactors := make([]float64, len(raterIDs))
+	noteFactors := make([]float64, len(noteIDs))
+	for i := range raterFactors {
+		raterFactors[i] = random.NormFloat64() * 0.1
+	}
+	for i := range noteFactors {
+		noteFactors[i] = random.NormFloat64() * 0.1
+	}
+
+	lr := params.LearningRate
+	for epoch := 0; epoch < params.Epochs; epoch++ {
+		random.Shuffle(len(observations), func(i, j int) {
+			observations[i], observations[j] = observations[j], observations[i]
+		})
+
+		for _, o := range observations {
+			fu, fn := raterFactors[o.rater], noteFactors[o.note]
+			prediction := global + raterIntercepts[o.rater] + noteIntercepts[o.note] + fu*fn
+			diff := o.value - prediction
+
+			global += lr * (diff - params.InterceptLambda*global)
+			raterIntercepts[o.rater] += lr * (diff - params.InterceptLambda*raterIntercepts[o.rater])
+			noteIntercepts[o.note] += lr * (diff - params.InterceptLambda*noteIntercepts[o.note])
+			raterFactors[o.rater] += lr * (diff*fn - params.FactorLambda*fu)
+			noteFactors[o.note] += lr * (diff*fu - params.FactorLambda*fn)
+		}
+	}
+
+	counts := make([]int, len(noteIDs))
+	for _, o := range observations {
+		counts[o.note]++
+	}
+
+	result := Result{GlobalIntercept: global}
+	for n, id := range noteIDs {
+		score := NoteScore{
+			NoteID:    id,
+			Intercept: noteIntercepts[n],
+			Factor:    noteFactors[n],
+			Ratings:   counts[n],
+		}
+		score.Status = params.status(score)
+		result.Notes = append(result.Notes, score)
+	}
+	for r, id := range raterIDs {
+		result.Raters = append(result.Raters, RaterScore{
+			RaterID:   id,
+			Intercept: raterIntercepts[r],
+			Factor:    raterFactors[r],
+		})
+	}
+
+	sort.Slice(result.Notes, func(i, j int) bool {
+		return result.Notes[i].NoteID < result.Notes[j].NoteID
+	})
+	sort.Slice(result.Raters, func(i, j int) bool {
+		return result.Raters[i].RaterID < result.Raters[j].RaterID
+	})
+
+	return result
+}
+
+func (p Params) status(score NoteScore) string {
+	if score.Ratings < p.MinRatings {
+		return StatusNeedsMoreRatings
+	}
+
+	factor := math.Abs(score.Factor)
+	switch {
+	case score.Intercept >= p.HelpfulIntercept && factor < p.MaxHelpfulFactor:
+		return StatusHelpful
+	case score.Intercept <= p.NotHelpfulIntercept-p.NotHelpfulFactorSlope*factor:
+		return StatusNotHelpful
+	default:
+		return StatusNeedsMoreRatings
+	}
+}
+
+// StarsToValue converts a 1-5 star rating to the [0, 1] scale used by the model
+func StarsToValue(stars int) float64 {
+	return float64(stars-1) / 4
+}
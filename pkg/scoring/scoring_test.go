@@ -0,0 +1,119 @@
+package scoring
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestScoreBridging(t *testing.T) {
+	for _, seed := range []int64{1, 2, 3, 7, 42} {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			fixture := syntheticRatings(seed)
+			result := Score(fixture.Ratings, DefaultParams())
+
+			rated := map[string]bool{}
+			for _, rating := range fixture.Ratings {
+				rated[rating.NoteID] = true
+			}
+			if len(result.Notes) != len(rated) {
+				t.Fatalf("scored %d notes, want %d", len(result.Notes), len(rated))
+			}
+
+			for _, note := range result.Notes {
+				if want := fixture.Expected[note.NoteID]; note.Status != want {
+					t.Errorf("%s: status %s (intercept %.3f, factor %.3f, %d ratings), want %s",
+						note.NoteID, note.Status, note.Intercept, note.Factor, note.Ratings, want)
+				}
+			}
+		})
+	}
+}
+
+func TestStarsToValue(t *testing.T) {
+	tests := []struct {
+		stars int
+		want  float64
+	}{
+		{1, 0},
+		{3, 0.5},
+		{5, 1},
+	}
+	for _, tt := range tests {
+		if got := StarsToValue(tt.stars); got != tt.want {
+			t.Errorf("StarsToValue(%d) = %v, want %v", tt.stars, got, tt.want)
+		}
+	}
+}
+
+// syntheticFixture is a generated rating matrix together with the status every note should end up with
+type syntheticFixture struct {
+	Ratings  []Rating
+	Expected map[string]string
+}
+
+// syntheticRatings generates a polarized rating matrix: raters are split into two camps that disagree on
+// partisan notes. Bridging notes are rated highly by both camps and are expected to be helpful, partisan
+// notes liked by only one camp need more ratings, notes nobody likes are not helpful and notes with too
+// few ratings need more ratings.
+func syntheticRatings(seed int64) syntheticFixture {
+	random := rand.New(rand.NewSource(seed))
+	fixture := syntheticFixture{Expected: map[string]string{}}
+
+	const ratersPerCamp = 20
+	camps := []string{"left", "right"}
+
+	clamp := func(value float64) float64 {
+		if value < 0 {
+			return 0
+		}
+		if value > 1 {
+			return 1
+		}
+		return value
+	}
+
+	// rate adds a rating from each rater with the given probability, using the camp's mean value plus noise
+	rate := func(note string, means map[string]float64, probability float64) {
+		for _, camp := range camps {
+			for i := 0; i < ratersPerCamp; i++ {
+				if random.Float64() > probability {
+					continue
+				}
+				fixture.Ratings = append(fixture.Ratings, Rating{
+					RaterID: fmt.Sprintf("%s-rater-%d", camp, i),
+					NoteID:  note,
+					Value:   clamp(means[camp] + random.NormFloat64()*0.1),
+				})
+			}
+		}
+	}
+
+	for i := 0; i < 4; i++ {
+		note := fmt.Sprintf("bridging-%d", i)
+		rate(note, map[string]float64{"left": 0.95, "right": 0.9}, 0.7)
+		fixture.Expected[note] = StatusHelpful
+	}
+
+	for i := 0; i < 6; i++ {
+		note := fmt.Sprintf("left-partisan-%d", i)
+		rate(note, map[string]float64{"left": 1, "right": 0}, 0.7)
+		fixture.Expected[note] = StatusNeedsMoreRatings
+
+		note = fmt.Sprintf("right-partisan-%d", i)
+		rate(note, map[string]float64{"left": 0, "right": 1}, 0.7)
+		fixture.Expected[note] = StatusNeedsMoreRatings
+	}
+
+	for i := 0; i < 3; i++ {
+		note := fmt.Sprintf("unhelpful-%d", i)
+		rate(note, map[string]float64{"left": 0.05, "right": 0.05}, 0.7)
+		fixture.Expected[note] = StatusNotHelpful
+	}
+
+	note := "sparse-0"
+	rate(note, map[string]float64{"left": 1, "right": 1}, 0.05)
+	fixture.Expected[note] = StatusNeedsMoreRatings
+
+	return fixture
+}
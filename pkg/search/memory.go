@@ -0,0 +1,194 @@
+package search
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+)
+
+// BM25 parameters
+const (
+	k1 = 1.2
+	b  = 0.75
+)
+
+type docKey struct {
+	kind string
+	id   string
+}
+
+type indexedDocument struct {
+	doc    Document
+	length int
+	terms  map[string]int
+}
+
+// MemoryIndex is an inverted index held in process memory and ranked with BM25. It is meant for tests and
+// single node deployments, every process has its own copy.
+type MemoryIndex struct {
+	mu       sync.RWMutex
+	docs     map[docKey]indexedDocument
+	postings map[string]map[docKey]int
+	// lengths and counts per kind, for the average document length
+	totalLength map[string]int
+	docCount    map[string]int
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		docs:        map[docKey]indexedDocument{},
+		postings:    map[string]map[docKey]int{},
+		totalLength: map[string]int{},
+		docCount:    map[string]int{},
+	}
+}
+
+func (m *MemoryIndex) Index(ctx context.Context, docs ...Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.add(docs)
+	return nil
+}
+
+// Replace builds a new index of the documents and swaps it in, so searches keep seeing the old index
+// until the new one is complete
+func (m *MemoryIndex) Replace(ctx context.Context, docs ...Document) error {
+	fresh := NewMemoryIndex()
+	fresh.add(docs)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.docs = fresh.docs
+	m.postings = fresh.postings
+	m.totalLength = fresh.totalLength
+	m.docCount = fresh.docCount
+	return nil
+}
+
+// add indexes the documents, replacing earlier versions. The caller holds the write lock.
+func (m *MemoryIndex) add(docs []Document) {
+	for _, doc := range docs {
+		key := docKey{kind: doc.Kind, id: doc.ID}
+		m.remove(key)
+
+		indexed := indexedDocument{doc: doc, terms: map[string]int{}}
+		for _, field := range doc.Fields {
+			for _, term := range Tokenize(field) {
+				indexed.terms[term]++
+				indexed.length++
+			}
+		}
+
+		for term, frequency := range indexed.terms {
+			if m.postings[term] == nil {
+				m.postings[term] = map[docKey]int{}
+			}
+			m.postings[term][key] = frequency
+		}
+		m.docs[key] = indexed
+		m.totalLength[doc.Kind] += indexed.length
+		m.docCount[doc.Kind]++
+	}
+}
+
+// remove drops a document from the index. The caller holds the write lock.
+func (m *MemoryIndex) remove(key docKey) {
+	existing, ok := m.docs[key]
+	if !ok {
+		return
+	}
+
+	for term := range existing.terms {
+		delete(m.postings[term], key)
+		if len(m.postings[term]) == 0 {
+			delete(m.postings, term)
+		}
+	}
+	m.totalLength[key.kind] -= existing.length
+	m.docCount[key.kind]--
+	delete(m.docs, key)
+}
+
+// Search scores every document of the query's kind that contains at least one query word and passes the filters
+func (m *MemoryIndex) Search(ctx context.Context, query Query, params pagination.Params) (pagination.Page[Hit], error) {
+	terms := uniqueTerms(Tokenize(query.Text))
+	if len(terms) == 0 {
+		return pagination.Page[Hit]{}, ErrEmptyQuery
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	scores := map[docKey]float64{}
+	count := float64(m.docCount[query.Kind])
+	if count > 0 {
+		averageLength := float64(m.totalLength[query.Kind]) / count
+
+		for _, term := range terms {
+			var frequency float64
+			for key := range m.postings[term] {
+				if key.kind == query.Kind {
+					frequency++
+				}
+			}
+			if frequency == 0 {
+				continue
+			}
+			idf := math.Log(1 + (count-frequency+0.5)/(frequency+0.5))
+
+			for key, termFrequency := range m.postings[term] {
+				indexed := m.docs[key]
+				if !query.Matches(indexed.doc) {
+					continue
+				}
+				tf := float64(termFrequency)
+				norm := 1 - b + b*float64(indexed.length)/averageLength
+				scores[key] += idf * tf * (k1 + 1) / (tf + k1*norm)
+			}
+		}
+	}
+
+	hits := make([]Hit, 0, len(scores))
+	for key, score := range scores {
+		hits = append(hits, Hit{Kind: key.kind, ID: key.id, Score: score, CreatedAt: m.docs[key].doc.CreatedAt})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		return hits[i].before(hits[j])
+	})
+
+	params.Limit = pagination.ClampLimit(params.Limit)
+	page := pagination.Page[Hit]{Items: []Hit{}}
+	if params.WithTotal {
+		total := int64(len(hits))
+		page.Total = &total
+	}
+
+	if params.After != nil {
+		after := Hit{ID: params.After.ID, Score: params.After.Rank, CreatedAt: params.After.Time}
+		hits = hits[sort.Search(len(hits), func(i int) bool { return after.before(hits[i]) }):]
+	}
+	if len(hits) > params.Limit {
+		hits = hits[:params.Limit]
+		page.NextCursor = hits[len(hits)-1].Cursor().Encode()
+	}
+	page.Items = append(page.Items, hits...)
+
+	return page, nil
+}
+
+func uniqueTerms(terms []string) []string {
+	seen := map[string]bool{}
+	unique := terms[:0]
+	for _, term := range terms {
+		if !seen[term] {
+			seen[term] = true
+			unique = append(unique, term)
+		}
+	}
+	return unique
+}
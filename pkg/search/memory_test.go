@@ -0,0 +1,209 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+)
+
+func hitIDs(results pagination.Page[Hit]) []string {
+	ids := make([]string, len(results.Items))
+	for i, hit := range results.Items {
+		ids[i] = hit.ID
+	}
+	return ids
+}
+
+func sameIDs(got []string, want ...string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func newTestIndex(t *testing.T, docs ...Document) *MemoryIndex {
+	t.Helper()
+	index := NewMemoryIndex()
+	if err := index.Index(context.Background(), docs...); err != nil {
+		t.Fatal(err)
+	}
+	return index
+}
+
+// runSearch returns the first page of hits with the total
+func runSearch(t *testing.T, index *MemoryIndex, query Query) pagination.Page[Hit] {
+	t.Helper()
+	results, err := index.Search(context.Background(), query, pagination.Params{WithTotal: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestMemoryIndexRanking(t *testing.T) {
+	index := newTestIndex(t,
+		Document{Kind: "summary", ID: "once", Fields: []string{"the vaccine was tested on adults in many countries"}},
+		Document{Kind: "summary", ID: "twice", Fields: []string{"the vaccine trial", "vaccine results in many countries"}},
+		Document{Kind: "summary", ID: "short", Fields: []string{"vaccine"}},
+		Document{Kind: "summary", ID: "unrelated", Fields: []string{"the election results"}},
+		Document{Kind: "request", ID: "other-kind", Fields: []string{"vaccine vaccine vaccine"}},
+	)
+
+	// Shorter documents rank above longer ones with the same term frequency, and more occurrences rank
+	// above fewer in documents of similar length
+	results := runSearch(t, index, Query{Kind: "summary", Text: "vaccine"})
+	if !sameIDs(hitIDs(results), "short", "twice", "once") {
+		t.Errorf("hits = %v, want short, twice, once", hitIDs(results))
+	}
+	if *results.Total != 3 {
+		t.Errorf("total = %d, want 3", *results.Total)
+	}
+
+	// A rare term outweighs a common one
+	results = runSearch(t, index, Query{Kind: "summary", Text: "the adults"})
+	if hitIDs(results)[0] != "once" {
+		t.Errorf("hits = %v, want once first", hitIDs(results))
+	}
+
+	// Every word counts once, however often the query repeats it
+	repeated := runSearch(t, index, Query{Kind: "summary", Text: "Vaccine, vaccine!"})
+	single := runSearch(t, index, Query{Kind: "summary", Text: "vaccine"})
+	if repeated.Items[0].Score != single.Items[0].Score {
+		t.Errorf("repeating a query word changed the score from %v to %v", single.Items[0].Score, repeated.Items[0].Score)
+	}
+}
+
+func TestMemoryIndexFilters(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	index := newTestIndex(t,
+		Document{Kind: "summary", ID: "a", Fields: []string{"flood"}, Status: "approved", AuthorID: "ann", Verified: true, CreatedAt: day},
+		Document{Kind: "summary", ID: "b", Fields: []string{"flood"}, Status: "pending", AuthorID: "ann", CreatedAt: day.Add(24 * time.Hour)},
+		Document{Kind: "summary", ID: "c", Fields: []string{"flood"}, Status: "approved", AuthorID: "bob", CreatedAt: day.Add(48 * time.Hour)},
+		Document{Kind: "request", ID: "d", Fields: []string{"flood"}, Status: "approved", AuthorID: "ann", CreatedAt: day},
+	)
+	verified, unverified := true, false
+
+	tests := []struct {
+		name  string
+		query Query
+		want  []string
+	}{
+		{"kind", Query{Kind: "request"}, []string{"d"}},
+		{"status", Query{Kind: "summary", Status: "approved"}, []string{"c", "a"}},
+		{"author", Query{Kind: "summary", AuthorID: "ann"}, []string{"b", "a"}},
+		{"verified", Query{Kind: "summary", Verified: &verified}, []string{"a"}},
+		{"unverified", Query{Kind: "summary", Verified: &unverified}, []string{"c", "b"}},
+		{"from is inclusive", Query{Kind: "summary", From: day.Add(24 * time.Hour)}, []string{"c", "b"}},
+		{"to is exclusive", Query{Kind: "summary", To: day.Add(24 * time.Hour)}, []string{"a"}},
+		{"combined", Query{Kind: "summary", Status: "approved", AuthorID: "bob"}, []string{"c"}},
+		{"no match", Query{Kind: "summary", Status: "rejected"}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.query.Text = "flood"
+			results := runSearch(t, index, tt.query)
+			// Equal scores are ordered newest first
+			if !sameIDs(hitIDs(results), tt.want...) {
+				t.Errorf("hits = %v, want %v", hitIDs(results), tt.want)
+			}
+			if *results.Total != int64(len(tt.want)) {
+				t.Errorf("total = %d, want %d", *results.Total, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestMemoryIndexPaging(t *testing.T) {
+	day := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	index := newTestIndex(t,
+		Document{Kind: "summary", ID: "a", Fields: []string{"rain"}, CreatedAt: day},
+		Document{Kind: "summary", ID: "b", Fields: []string{"rain"}, CreatedAt: day},
+		Document{Kind: "summary", ID: "c", Fields: []string{"rain"}, CreatedAt: day.Add(time.Hour)},
+		Document{Kind: "summary", ID: "d", Fields: []string{"rain rain"}, CreatedAt: day},
+	)
+	query := Query{Kind: "summary", Text: "rain"}
+
+	// Every page size splits the hits with equal scores differently
+	for limit := 1; limit <= 5; limit++ {
+		var ids []string
+		params := pagination.Params{Limit: limit}
+		for pages := 0; ; pages++ {
+			if pages > 5 {
+				t.Fatalf("no last page after %v", ids)
+			}
+			page, err := index.Search(context.Background(), query, params)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(page.Items) > limit {
+				t.Fatalf("page of %d hits, limit %d", len(page.Items), limit)
+			}
+			ids = append(ids, hitIDs(page)...)
+			if page.NextCursor == "" {
+				break
+			}
+			cursor, err := pagination.DecodeCursor(page.NextCursor)
+			if err != nil {
+				t.Fatal(err)
+			}
+			params.After = &cursor
+		}
+
+		// Equal scores are ordered newest first, then by ID
+		if !sameIDs(ids, "d", "c", "b", "a") {
+			t.Errorf("pages of %d: hits %v, want d, c, b, a", limit, ids)
+		}
+	}
+}
+
+func TestMemoryIndexEmptyQuery(t *testing.T) {
+	index := newTestIndex(t)
+	if _, err := index.Search(context.Background(), Query{Kind: "summary", Text: " ?! "}, pagination.Params{}); !errors.Is(err, ErrEmptyQuery) {
+		t.Errorf("searching for punctuation returned %v, want ErrEmptyQuery", err)
+	}
+}
+
+func TestMemoryIndexReindex(t *testing.T) {
+	index := newTestIndex(t,
+		Document{Kind: "summary", ID: "a", Fields: []string{"drought"}},
+		Document{Kind: "summary", ID: "b", Fields: []string{"drought"}},
+	)
+
+	if err := index.Index(context.Background(), Document{Kind: "summary", ID: "a", Fields: []string{"harvest"}}); err != nil {
+		t.Fatal(err)
+	}
+	if ids := hitIDs(runSearch(t, index, Query{Kind: "summary", Text: "drought"})); !sameIDs(ids, "b") {
+		t.Errorf("after reindexing a, drought hits = %v, want b", ids)
+	}
+	if ids := hitIDs(runSearch(t, index, Query{Kind: "summary", Text: "harvest"})); !sameIDs(ids, "a") {
+		t.Errorf("after reindexing a, harvest hits = %v, want a", ids)
+	}
+}
+
+func TestMemoryIndexReplace(t *testing.T) {
+	index := newTestIndex(t,
+		Document{Kind: "summary", ID: "kept", Fields: []string{"storm"}},
+		Document{Kind: "summary", ID: "deleted", Fields: []string{"storm"}},
+		Document{Kind: "request", ID: "deleted-request", Fields: []string{"storm"}},
+	)
+
+	if err := index.Replace(context.Background(), Document{Kind: "summary", ID: "kept", Fields: []string{"storm"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := hitIDs(runSearch(t, index, Query{Kind: "summary", Text: "storm"})); !sameIDs(ids, "kept") {
+		t.Errorf("summary hits = %v, want kept", ids)
+	}
+	if ids := hitIDs(runSearch(t, index, Query{Kind: "request", Text: "storm"})); len(ids) != 0 {
+		t.Errorf("request hits = %v, want none", ids)
+	}
+}
@@ -0,0 +1,102 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/pagination"
+)
+
+var ErrEmptyQuery = errors.New("search query has no searchable words")
+
+// Document is a searchable record. Fields hold the text that is matched against the query, the other
+// values are only used to filter results.
+type Document struct {
+	Kind      string
+	ID        string
+	Fields    []string
+	Status    string
+	AuthorID  string
+	Verified  bool
+	CreatedAt time.Time
+}
+
+// Query searches the documents of one kind. Empty filters match every document, and From and To bound
+// the creation time when they are not zero.
+type Query struct {
+	Kind     string
+	Text     string
+	Status   string
+	AuthorID string
+	Verified *bool
+	From     time.Time
+	To       time.Time
+}
+
+// Hit is a matching document. Hits are ordered by Score, then newest first with the ID breaking ties, the
+// order pagination.Order{Rank: "score", Time: "created_at"} sorts by.
+type Hit struct {
+	Kind      string
+	ID        string
+	Score     float64
+	CreatedAt time.Time
+}
+
+// Cursor is the position of the hit in the search results
+func (h Hit) Cursor() pagination.Cursor {
+	return pagination.Cursor{Rank: h.Score, Time: h.CreatedAt, ID: h.ID}
+}
+
+// before reports whether the hit comes before the other in the search results
+func (h Hit) before(other Hit) bool {
+	if h.Score != other.Score {
+		return h.Score > other.Score
+	}
+	if !h.CreatedAt.Equal(other.CreatedAt) {
+		return h.CreatedAt.After(other.CreatedAt)
+	}
+	return h.ID > other.ID
+}
+
+// Index ranks documents by their relevance to a query
+type Index interface {
+	// Index adds the documents, replacing any earlier version with the same kind and ID
+	Index(ctx context.Context, docs ...Document) error
+	// Replace swaps the whole index for the documents, dropping every document that is not among them
+	Replace(ctx context.Context, docs ...Document) error
+	// Search returns a page of the documents matching the query, best match first
+	Search(ctx context.Context, query Query, params pagination.Params) (pagination.Page[Hit], error)
+}
+
+// Tokenize lower cases text and splits it into words
+func Tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
+
+// Matches reports whether the document passes the query's filters
+func (q Query) Matches(doc Document) bool {
+	if doc.Kind != q.Kind {
+		return false
+	}
+	if q.Status != "" && doc.Status != q.Status {
+		return false
+	}
+	if q.AuthorID != "" && doc.AuthorID != q.AuthorID {
+		return false
+	}
+	if q.Verified != nil && doc.Verified != *q.Verified {
+		return false
+	}
+	if !q.From.IsZero() && doc.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !doc.CreatedAt.Before(q.To) {
+		return false
+	}
+	return true
+}
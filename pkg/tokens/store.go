@@ -0,0 +1,197 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidRefreshToken  = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused   = errors.New("refresh token was already used")
+	ErrRefreshTokenNotOwned = errors.New("refresh token belongs to another user")
+)
+
+// RefreshSession is stored in redis for every refresh token that has not been used yet
+type RefreshSession struct {
+	UserID     string    `json:"user_id"`
+	Generation int64     `json:"generation"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// Store keeps refresh tokens and the revocation list in redis.
+// Access tokens are revoked one at a time by jti, or all at once for a user by bumping the user's token generation;
+// tokens carry the generation they were issued with and are rejected once it is behind the current one.
+type Store struct {
+	redis *adapters.RedisClient
+}
+
+func NewStore(redisClient *adapters.RedisClient) *Store {
+	return &Store{redis: redisClient}
+}
+
+func generationKey(userID string) string {
+	return fmt.Sprintf("token:generation:%s", userID)
+}
+
+func revokedKey(jti string) string {
+	return fmt.Sprintf("token:revoked:%s", jti)
+}
+
+func refreshKey(hash string) string {
+	return fmt.Sprintf("token:refresh:%s", hash)
+}
+
+func usedRefreshKey(hash string) string {
+	return fmt.Sprintf("token:refresh:used:%s", hash)
+}
+
+// hashToken is used as the redis key of a refresh token so the raw token is never stored
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewTokenID returns a random id for the jti claim of an access token
+func NewTokenID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// Generation returns the user's current token generation
+func (s *Store) Generation(ctx context.Context, userID string) (int64, error) {
+	value, err := s.redis.Client().Get(ctx, generationKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+// RevokeUser invalidates every access and refresh token issued to the user so far
+func (s *Store) RevokeUser(ctx context.Context, userID string) error {
+	return s.redis.Client().Incr(ctx, generationKey(userID)).Err()
+}
+
+// RevokeAccessToken adds a single access token to the revocation list until it would have expired anyway
+func (s *Store) RevokeAccessToken(ctx context.Context, jti string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if jti == "" || ttl <= 0 {
+		return nil
+	}
+	return s.redis.Client().Set(ctx, revokedKey(jti), 1, ttl).Err()
+}
+
+// IsRevoked reports whether an access token was revoked by jti or by a later generation of the user's tokens
+func (s *Store) IsRevoked(ctx context.Context, userID string, jti string, generation int64) (bool, error) {
+	if jti != "" {
+		exists, err := s.redis.Client().Exists(ctx, revokedKey(jti)).Result()
+		if err != nil {
+			return false, err
+		}
+		if exists > 0 {
+			return true, nil
+		}
+	}
+
+	current, err := s.Generation(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return generation < current, nil
+}
+
+// IssueRefreshToken creates a new refresh token for the user
+func (s *Store) IssueRefreshToken(ctx context.Context, userID string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	generation, err := s.Generation(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	session := RefreshSession{
+		UserID:     userID,
+		Generation: generation,
+		ExpiresAt:  time.Now().Add(RefreshTokenTTL),
+	}
+	if err := s.redis.Set(ctx, refreshKey(hashToken(token)), session, RefreshTokenTTL); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// UseRefreshToken consumes a refresh token and returns its session. Each refresh token can be used once;
+// presenting a used one again means it leaked, so every token of the user is revoked.
+func (s *Store) UseRefreshToken(ctx context.Context, token string) (RefreshSession, error) {
+	hash := hashToken(token)
+
+	var session RefreshSession
+	if err := s.redis.GetDel(ctx, refreshKey(hash), &session); err != nil {
+		var userID string
+		if s.redis.Get(ctx, usedRefreshKey(hash), &userID) == nil {
+			if err := s.RevokeUser(ctx, userID); err != nil {
+				return RefreshSession{}, err
+			}
+			return RefreshSession{}, ErrRefreshTokenReused
+		}
+		return RefreshSession{}, ErrInvalidRefreshToken
+	}
+
+	if err := s.redis.Set(ctx, usedRefreshKey(hash), session.UserID, time.Until(session.ExpiresAt)); err != nil {
+		return RefreshSession{}, err
+	}
+
+	current, err := s.Generation(ctx, session.UserID)
+	if err != nil {
+		return RefreshSession{}, err
+	}
+	if session.Generation < current {
+		return RefreshSession{}, ErrInvalidRefreshToken
+	}
+
+	return session, nil
+}
+
+// RevokeRefreshToken deletes a refresh token of the user so it can no longer be used. Tokens that are
+// already gone are ignored; tokens of another user are left alone and reported with ErrRefreshTokenNotOwned.
+func (s *Store) RevokeRefreshToken(ctx context.Context, token string, userID string) error {
+	key := refreshKey(hashToken(token))
+
+	var session RefreshSession
+	if err := s.redis.Get(ctx, key, &session); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return nil
+		}
+		return err
+	}
+	if session.UserID != userID {
+		return ErrRefreshTokenNotOwned
+	}
+
+	return s.redis.Delete(ctx, key)
+}
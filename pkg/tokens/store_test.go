@@ -0,0 +1,164 @@
+package tokens
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/mwelwankuta/facebook-notes/pkg/adapters"
+)
+
+func newTestStore(t *testing.T) (*Store, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	return NewStore(adapters.NewRedisClient(server.Addr(), "", 0)), server
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	token, err := store.IssueRefreshToken(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := store.UseRefreshToken(ctx, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.UserID != "user" || session.Generation != 0 {
+		t.Errorf("session = %+v", session)
+	}
+
+	if _, err := store.UseRefreshToken(ctx, "unknown"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("an unknown token returned %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRefreshTokenReuse(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	stolen, err := store.IssueRefreshToken(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.UseRefreshToken(ctx, stolen); err != nil {
+		t.Fatal(err)
+	}
+	// The legitimate client rotated to this token
+	rotated, err := store.IssueRefreshToken(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := store.UseRefreshToken(ctx, stolen); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing a token returned %v, want ErrRefreshTokenReused", err)
+	}
+
+	// Reuse revokes everything the user was issued
+	if _, err := store.UseRefreshToken(ctx, rotated); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("the rotated token returned %v after a reuse, want ErrInvalidRefreshToken", err)
+	}
+	if revoked, err := store.IsRevoked(ctx, "user", "jti", 0); err != nil || !revoked {
+		t.Errorf("an access token issued before the reuse is revoked: %v, %v", revoked, err)
+	}
+}
+
+func TestRefreshTokenExpires(t *testing.T) {
+	store, server := newTestStore(t)
+	ctx := context.Background()
+
+	token, err := store.IssueRefreshToken(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.FastForward(RefreshTokenTTL + time.Second)
+
+	if _, err := store.UseRefreshToken(ctx, token); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("an expired token returned %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRevokeAccessToken(t *testing.T) {
+	store, server := newTestStore(t)
+	ctx := context.Background()
+
+	if err := store.RevokeAccessToken(ctx, "jti", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if revoked, err := store.IsRevoked(ctx, "user", "jti", 0); err != nil || !revoked {
+		t.Errorf("the revoked token is revoked: %v, %v", revoked, err)
+	}
+	if revoked, err := store.IsRevoked(ctx, "user", "other", 0); err != nil || revoked {
+		t.Errorf("another token is revoked: %v, %v", revoked, err)
+	}
+
+	// The revocation only lasts as long as the token would have
+	server.FastForward(time.Minute + time.Second)
+	if server.Exists(revokedKey("jti")) {
+		t.Error("the revocation outlived the token")
+	}
+
+	if err := store.RevokeAccessToken(ctx, "expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if server.Exists(revokedKey("expired")) {
+		t.Error("an expired token was added to the revocation list")
+	}
+}
+
+func TestRevokeUser(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	refreshToken, err := store.IssueRefreshToken(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeUser(ctx, "user"); err != nil {
+		t.Fatal(err)
+	}
+
+	generation, err := store.Generation(ctx, "user")
+	if err != nil || generation != 1 {
+		t.Fatalf("generation = %d, %v, want 1", generation, err)
+	}
+	if revoked, _ := store.IsRevoked(ctx, "user", "jti", 0); !revoked {
+		t.Error("a token of the previous generation is not revoked")
+	}
+	if revoked, _ := store.IsRevoked(ctx, "user", "jti", 1); revoked {
+		t.Error("a token of the current generation is revoked")
+	}
+	if revoked, _ := store.IsRevoked(ctx, "other", "jti", 0); revoked {
+		t.Error("another user's token is revoked")
+	}
+	if _, err := store.UseRefreshToken(ctx, refreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("a refresh token of the previous generation returned %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestRevokeRefreshToken(t *testing.T) {
+	store, _ := newTestStore(t)
+	ctx := context.Background()
+
+	token, err := store.IssueRefreshToken(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.RevokeRefreshToken(ctx, token, "other"); !errors.Is(err, ErrRefreshTokenNotOwned) {
+		t.Errorf("revoking another user's token returned %v, want ErrRefreshTokenNotOwned", err)
+	}
+	if err := store.RevokeRefreshToken(ctx, token, "user"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeRefreshToken(ctx, token, "user"); err != nil {
+		t.Errorf("revoking a revoked token returned %v", err)
+	}
+	if _, err := store.UseRefreshToken(ctx, token); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("a revoked token returned %v, want ErrInvalidRefreshToken", err)
+	}
+}
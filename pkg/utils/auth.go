@@ -2,29 +2,71 @@ package utils
 
 import (
 	"errors"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"github.com/mwelwankuta/facebook-notes/pkg/models"
 )
 
+// principalKey is the echo context key the auth middleware stores the Principal under
+const principalKey = "principal"
+
+var ErrNoPrincipal = errors.New("request is not authenticated")
+
 type JwtCustomClaims struct {
-	ID          string `json:"id"`
-	Role        string `json:"role"`
-	AccessToken string `json:"access_token"`
+	ID   string `json:"id"`
+	Role string `json:"role"`
+	// Generation is the user's token generation when the token was issued, see tokens.Store
+	Generation int64 `json:"gen"`
 	jwt.RegisteredClaims
 }
 
-func GetUserFromContext(c echo.Context) (models.User, error) {
-	token := c.Get("user").(*jwt.Token)
-	claims := token.Claims.(*JwtCustomClaims)
+// Principal is the authenticated caller of a request, taken from a verified access token
+type Principal struct {
+	UserID     string
+	Role       string
+	TokenID    string
+	Generation int64
+	ExpiresAt  time.Time
+}
+
+// NewPrincipal builds the principal for verified claims
+func NewPrincipal(claims *JwtCustomClaims) Principal {
+	principal := Principal{
+		UserID:     claims.ID,
+		Role:       claims.Role,
+		TokenID:    claims.RegisteredClaims.ID,
+		Generation: claims.Generation,
+	}
+	if claims.ExpiresAt != nil {
+		principal.ExpiresAt = claims.ExpiresAt.Time
+	}
+	return principal
+}
+
+// SetPrincipal stores the authenticated caller in the request context
+func SetPrincipal(c echo.Context, principal Principal) {
+	c.Set(principalKey, principal)
+}
 
-	if claims == nil {
-		return models.User{}, errors.New("invalid token claims")
+// GetPrincipal returns the authenticated caller, or ErrNoPrincipal when the auth middleware did not run
+func GetPrincipal(c echo.Context) (Principal, error) {
+	principal, ok := c.Get(principalKey).(Principal)
+	if !ok || principal.UserID == "" {
+		return Principal{}, ErrNoPrincipal
+	}
+	return principal, nil
+}
+
+func GetUserFromContext(c echo.Context) (models.User, error) {
+	principal, err := GetPrincipal(c)
+	if err != nil {
+		return models.User{}, err
 	}
 
 	return models.User{
-		ID:   claims.ID,
-		Role: claims.Role,
+		ID:   principal.UserID,
+		Role: principal.Role,
 	}, nil
 }
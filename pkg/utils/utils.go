@@ -1,7 +1,6 @@
 package utils
 
 import (
-	"strconv"
 	"time"
 
 	"github.com/go-playground/validator/v10"
@@ -22,56 +21,32 @@ func NewErrorResponse(message string) ErrorResponse {
 	return ErrorResponse{Error: message}
 }
 
-// GenerateJwtToken generates a jwt token
+// TokenSigner signs jwt claims, see jwks.KeySet
+type TokenSigner interface {
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// GenerateJwtToken generates a jwt token identified by tokenID and tied to the user's token generation
 // Middleware exists to automatically read the token from the request and verify it
-func GenerateJwtToken(secret string, user models.User, facebookToken string) (string, error) {
+func GenerateJwtToken(signer TokenSigner, user models.User, tokenID string, generation int64, expiresAt time.Time) (string, error) {
 	claims := &JwtCustomClaims{
 		user.ID,
 		user.Role,
-		facebookToken,
+		generation,
 		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour * 72)),
+			ID:        tokenID,
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	return token.SignedString([]byte(secret))
+	return signer.Sign(claims)
 }
 
 func EndpointNotImplemented(c echo.Context) error {
 	return c.String(404, "Endpoint not implemented")
 }
 
-// GetPaginationFromQuery extracts pagination parameters from the request query
-func GetPaginationFromQuery(c echo.Context) models.PaginateDto {
-	// Default values
-	defaultPage := 0
-	defaultLimit := 20
-
-	queryDto := models.GetPaginationFromQueryDto{
-		Offset: c.QueryParam("page"),
-		Limit:  c.QueryParam("limit"),
-	}
-
-	// Convert offset
-	pageNum, err := strconv.Atoi(queryDto.Offset)
-	if err != nil || queryDto.Offset == "" {
-		pageNum = defaultPage
-	}
-
-	// Convert limit
-	limitNum, err := strconv.Atoi(queryDto.Limit)
-	if err != nil || queryDto.Limit == "" {
-		limitNum = defaultLimit
-	}
-
-	return models.PaginateDto{
-		Offset: pageNum,
-		Limit:  limitNum,
-	}
-}
-
 // Validate validates a struct using validator.v10 package
 func Validate(s interface{}) error {
 	return validate.Struct(s)
@@ -0,0 +1,100 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T) *Cipher {
+	t.Helper()
+	cipher, err := NewCipher(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), KeySize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cipher
+}
+
+func TestNewCipher(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"not base64", "not a key!"},
+		{"too short", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), 16))},
+		{"too long", base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("k"), 64))},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewCipher(test.key); !errors.Is(err, ErrInvalidKey) {
+				t.Errorf("NewCipher returned %v, want ErrInvalidKey", err)
+			}
+		})
+	}
+}
+
+func TestSealOpen(t *testing.T) {
+	cipher := newTestCipher(t)
+
+	sealed, err := cipher.Seal([]byte("facebook-token"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "facebook-token") {
+		t.Error("the sealed value contains the plaintext")
+	}
+
+	opened, err := cipher.Open(sealed, []byte("user-1"))
+	if err != nil || string(opened) != "facebook-token" {
+		t.Errorf("Open = %q, %v", opened, err)
+	}
+
+	// Every seal uses a fresh nonce
+	again, err := cipher.Seal([]byte("facebook-token"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again == sealed {
+		t.Error("sealing the same plaintext twice gave the same ciphertext")
+	}
+}
+
+func TestOpenRejects(t *testing.T) {
+	cipher := newTestCipher(t)
+	sealed, err := cipher.Seal([]byte("facebook-token"), []byte("user-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(sealed)
+	raw[len(raw)-1] ^= 1
+
+	tests := []struct {
+		name           string
+		sealed         string
+		associatedData string
+	}{
+		{"another user's associated data", sealed, "user-2"},
+		{"no associated data", sealed, ""},
+		{"a modified ciphertext", base64.StdEncoding.EncodeToString(raw), "user-1"},
+		{"not base64", "not base64!", "user-1"},
+		{"shorter than a nonce", base64.StdEncoding.EncodeToString([]byte("short")), "user-1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := cipher.Open(test.sealed, []byte(test.associatedData)); !errors.Is(err, ErrInvalidCiphertext) {
+				t.Errorf("Open returned %v, want ErrInvalidCiphertext", err)
+			}
+		})
+	}
+
+	// A cipher with another key can't open it either
+	other, err := NewCipher(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("o"), KeySize)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(sealed, []byte("user-1")); !errors.Is(err, ErrInvalidCiphertext) {
+		t.Errorf("another key returned %v, want ErrInvalidCiphertext", err)
+	}
+}